@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/maps"
+)
+
+// serverConfig holds the settings the server reads from the environment at startup
+type serverConfig struct {
+	APIKey string
+	// StaticMapsAPIKey signs the static map image URL sent with route responses, which is omitted when it is
+	// empty. Clients see it, so it must not be APIKey and should be restricted to the Static Maps API.
+	StaticMapsAPIKey string
+	DatabasePath     string
+	// FrontendPath serves the frontend from disk, re-read on every request, instead of the embedded copy.
+	// Useful while editing the frontend.
+	FrontendPath string
+	Port         string
+	// RouteTimeout bounds planning a route, RequestTimeout bounds the other requests that call Google
+	RouteTimeout   time.Duration
+	RequestTimeout time.Duration
+	// CallTimeout bounds each Google call made without a deadline of its own
+	CallTimeout time.Duration
+	// Retry controls retrying Google calls that were rate limited or hit a server or network error
+	Retry maps.RetryPolicy
+	// LogLevel is the maps package log level, zero leaves the package default
+	LogLevel maps.LogLevel
+	// CacheTTL is how long cached superchargers and their restaurants are used before being fetched again
+	CacheTTL maps.CacheTTL
+	// RouteReuseMaxAge reuses routes stored by earlier searches between the same places, zero always routes afresh
+	RouteReuseMaxAge time.Duration
+	// RouteCacheMaxAge is how long clients and CDNs may cache route responses, counted from when the route
+	// was fetched since traffic data goes stale. Zero forbids caching them.
+	RouteCacheMaxAge time.Duration
+	// MinMatchConfidence is how confident, from 0 to 1, a place lookup must be to store it as a supercharger
+	MinMatchConfidence float64
+	// MaxRestaurantsPerSupercharger caps the restaurants stored and returned for each supercharger, zero keeps them all
+	MaxRestaurantsPerSupercharger int
+	// MinCachedPerCircle is how many cached superchargers a circle needs for prefer_cached to skip its search
+	MinCachedPerCircle int
+	// SeparateRejectedPlaces records places that aren't superchargers by ID only, keeping them out of the superchargers table
+	SeparateRejectedPlaces bool
+	// MaxConcurrentRoutes limits how many routes the whole server plans at once, zero is unlimited.
+	// Each route fans out into many Google calls, so a burst could otherwise exhaust file descriptors and QPS.
+	MaxConcurrentRoutes int
+	// RouteQueueTimeout is how long a route request waits for a free slot before getting a 503, zero rejects it at once
+	RouteQueueTimeout time.Duration
+	// TrustForwardedFor logs the client address from X-Forwarded-For instead of the connection. Only set it
+	// behind a proxy that sets the header, since clients can send anything in it.
+	TrustForwardedFor bool
+	// PlaceLocationRateLimit is how many /places/{id}/location requests each client may make a minute, zero is
+	// unlimited. The endpoint needs no authentication and an uncached place costs a place details call.
+	PlaceLocationRateLimit int
+	// LogMapsCalls writes a maps_call_logs row for every Places, Routes and autocomplete call, for spend analysis
+	LogMapsCalls bool
+
+	// envErrors are environment values that couldn't be parsed, reported by ValidateConfig
+	envErrors []string
+}
+
+// loadServerConfig reads the server configuration from the environment, falling back to defaults
+func loadServerConfig() *serverConfig {
+	cfg := &serverConfig{
+		APIKey:           os.Getenv("MAPS_API_KEY"),
+		StaticMapsAPIKey: os.Getenv("STATIC_MAPS_API_KEY"),
+		DatabasePath:     "db/passengerprincess.db",
+		FrontendPath:     os.Getenv("FRONTEND_PATH"),
+		Port:             "8040",
+		RouteTimeout:     30 * time.Second,
+		RequestTimeout:   10 * time.Second,
+		CallTimeout:      maps.DefaultCallTimeout,
+		Retry:            maps.DefaultRetryPolicy(),
+		CacheTTL:         maps.DefaultCacheTTL(),
+	}
+	cfg.MinMatchConfidence = cfg.floatEnv("MIN_MATCH_CONFIDENCE", maps.DefaultMinMatchConfidence)
+
+	if port := os.Getenv("PORT"); port != "" {
+		cfg.Port = port
+	}
+	cfg.RouteTimeout = cfg.durationEnv("ROUTE_TIMEOUT", cfg.RouteTimeout)
+	cfg.RequestTimeout = cfg.durationEnv("REQUEST_TIMEOUT", cfg.RequestTimeout)
+	cfg.CallTimeout = cfg.durationEnv("MAPS_CALL_TIMEOUT", cfg.CallTimeout)
+	cfg.Retry.MaxAttempts = cfg.intEnv("MAPS_MAX_ATTEMPTS", cfg.Retry.MaxAttempts)
+	cfg.Retry.BaseDelay = cfg.durationEnv("MAPS_RETRY_BASE_DELAY", cfg.Retry.BaseDelay)
+	cfg.Retry.MaxDelay = cfg.durationEnv("MAPS_RETRY_MAX_DELAY", cfg.Retry.MaxDelay)
+	cfg.CacheTTL.Supercharger = cfg.durationEnv("SUPERCHARGER_CACHE_TTL", cfg.CacheTTL.Supercharger)
+	cfg.CacheTTL.Restaurants = cfg.durationEnv("RESTAURANT_CACHE_TTL", cfg.CacheTTL.Restaurants)
+	cfg.RouteReuseMaxAge = cfg.durationEnv("ROUTE_REUSE_MAX_AGE", 0)
+	cfg.RouteCacheMaxAge = cfg.durationEnv("ROUTE_CACHE_MAX_AGE", 2*time.Minute)
+	cfg.SeparateRejectedPlaces = cfg.boolEnv("SEPARATE_REJECTED_PLACES", false)
+	cfg.MinCachedPerCircle = cfg.intEnv("MIN_CACHED_PER_CIRCLE", maps.DefaultMinCachedPerCircle)
+	cfg.MaxRestaurantsPerSupercharger = cfg.intEnv("MAX_RESTAURANTS_PER_SUPERCHARGER", maps.DefaultMaxRestaurantsPerSupercharger)
+	cfg.MaxConcurrentRoutes = cfg.intEnv("MAX_CONCURRENT_ROUTES", 8)
+	cfg.RouteQueueTimeout = cfg.durationEnv("ROUTE_QUEUE_TIMEOUT", 5*time.Second)
+	cfg.TrustForwardedFor = cfg.boolEnv("TRUST_FORWARDED_FOR", false)
+	cfg.LogMapsCalls = cfg.boolEnv("LOG_MAPS_CALLS", true)
+	cfg.PlaceLocationRateLimit = cfg.intEnv("PLACE_LOCATION_RATE_LIMIT", 30)
+
+	// Timing spans are logged at debug level, set MAPS_LOG_LEVEL=debug to see them
+	if levelName := os.Getenv("MAPS_LOG_LEVEL"); levelName != "" {
+		level, err := maps.ParseLogLevel(levelName)
+		if err != nil {
+			cfg.envErrors = append(cfg.envErrors, fmt.Sprintf("MAPS_LOG_LEVEL: %v", err))
+		}
+		cfg.LogLevel = level
+	}
+
+	return cfg
+}
+
+// durationEnv parses a duration such as "45s" from the named variable, recording an error if it is invalid
+func (c *serverConfig) durationEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		c.envErrors = append(c.envErrors, fmt.Sprintf("%s: %q is not a duration like \"30s\"", name, value))
+		return fallback
+	}
+	return d
+}
+
+// floatEnv parses a number from the named variable, recording an error if it is invalid
+func (c *serverConfig) floatEnv(name string, fallback float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		c.envErrors = append(c.envErrors, fmt.Sprintf("%s: %q is not a number", name, value))
+		return fallback
+	}
+	return f
+}
+
+// intEnv parses a whole number from the named variable, recording an error if it is invalid
+func (c *serverConfig) intEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		c.envErrors = append(c.envErrors, fmt.Sprintf("%s: %q is not a whole number", name, value))
+		return fallback
+	}
+	return n
+}
+
+// boolEnv parses a boolean such as "true" or "1" from the named variable, recording an error if it is invalid
+func (c *serverConfig) boolEnv(name string, fallback bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		c.envErrors = append(c.envErrors, fmt.Sprintf("%s: %q is not a boolean like \"true\"", name, value))
+		return fallback
+	}
+	return b
+}
+
+// ValidateConfig checks everything the server needs before it starts listening, so problems that
+// would otherwise only show up on the first request are reported together at startup.
+func ValidateConfig(cfg *serverConfig) error {
+	problems := append([]string(nil), cfg.envErrors...)
+
+	if cfg.APIKey == "" {
+		problems = append(problems, "MAPS_API_KEY is not set")
+	}
+
+	if cfg.StaticMapsAPIKey != "" && cfg.StaticMapsAPIKey == cfg.APIKey {
+		problems = append(problems, "STATIC_MAPS_API_KEY must not be MAPS_API_KEY, it is sent to clients")
+	}
+
+	if cfg.FrontendPath != "" {
+		if htmlContent, err := os.ReadFile(cfg.FrontendPath); err != nil {
+			problems = append(problems, fmt.Sprintf("FRONTEND_PATH: %s can't be read: %v", cfg.FrontendPath, err))
+		} else if _, err := template.New("frontend").Parse(string(htmlContent)); err != nil {
+			problems = append(problems, fmt.Sprintf("FRONTEND_PATH: %s is not a valid template: %v", cfg.FrontendPath, err))
+		}
+	}
+
+	if err := checkWritable(cfg.DatabasePath); err != nil {
+		problems = append(problems, fmt.Sprintf("database %s is not writable: %v", cfg.DatabasePath, err))
+	}
+
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("PORT: %q is not a valid port", cfg.Port))
+	}
+
+	// Route planning makes many Google calls, too short a deadline fails every request
+	if cfg.RouteTimeout < 5*time.Second || cfg.RouteTimeout > 5*time.Minute {
+		problems = append(problems, fmt.Sprintf("ROUTE_TIMEOUT: %v must be between 5s and 5m", cfg.RouteTimeout))
+	}
+	if cfg.RequestTimeout < time.Second || cfg.RequestTimeout > time.Minute {
+		problems = append(problems, fmt.Sprintf("REQUEST_TIMEOUT: %v must be between 1s and 1m", cfg.RequestTimeout))
+	}
+
+	if cfg.CallTimeout < time.Second || cfg.CallTimeout > time.Minute {
+		problems = append(problems, fmt.Sprintf("MAPS_CALL_TIMEOUT: %v must be between 1s and 1m", cfg.CallTimeout))
+	}
+
+	// zero means never expire, but a negative TTL would refetch on every request
+	if cfg.CacheTTL.Supercharger < 0 {
+		problems = append(problems, fmt.Sprintf("SUPERCHARGER_CACHE_TTL: %v must not be negative", cfg.CacheTTL.Supercharger))
+	}
+	if cfg.CacheTTL.Restaurants < 0 {
+		problems = append(problems, fmt.Sprintf("RESTAURANT_CACHE_TTL: %v must not be negative", cfg.CacheTTL.Restaurants))
+	}
+
+	if cfg.RouteReuseMaxAge < 0 {
+		problems = append(problems, fmt.Sprintf("ROUTE_REUSE_MAX_AGE: %v must not be negative", cfg.RouteReuseMaxAge))
+	}
+	if cfg.MinMatchConfidence <= 0 || cfg.MinMatchConfidence > 1 {
+		problems = append(problems, fmt.Sprintf("MIN_MATCH_CONFIDENCE: %v must be above 0 and at most 1", cfg.MinMatchConfidence))
+	}
+	if cfg.MinCachedPerCircle < 1 {
+		problems = append(problems, fmt.Sprintf("MIN_CACHED_PER_CIRCLE: %d must be at least 1", cfg.MinCachedPerCircle))
+	}
+	if cfg.MaxRestaurantsPerSupercharger < 0 {
+		problems = append(problems, fmt.Sprintf("MAX_RESTAURANTS_PER_SUPERCHARGER: %d must not be negative", cfg.MaxRestaurantsPerSupercharger))
+	}
+	if cfg.RouteCacheMaxAge < 0 {
+		problems = append(problems, fmt.Sprintf("ROUTE_CACHE_MAX_AGE: %v must not be negative", cfg.RouteCacheMaxAge))
+	}
+	if cfg.Retry.MaxAttempts < 1 {
+		problems = append(problems, fmt.Sprintf("MAPS_MAX_ATTEMPTS: %d must be at least 1", cfg.Retry.MaxAttempts))
+	}
+	if cfg.Retry.BaseDelay < 0 || cfg.Retry.MaxDelay < cfg.Retry.BaseDelay {
+		problems = append(problems, fmt.Sprintf("MAPS_RETRY_BASE_DELAY: %v must be between 0 and MAPS_RETRY_MAX_DELAY (%v)", cfg.Retry.BaseDelay, cfg.Retry.MaxDelay))
+	}
+	if cfg.PlaceLocationRateLimit < 0 {
+		problems = append(problems, fmt.Sprintf("PLACE_LOCATION_RATE_LIMIT: %d must not be negative", cfg.PlaceLocationRateLimit))
+	}
+	if cfg.MaxConcurrentRoutes < 0 {
+		problems = append(problems, fmt.Sprintf("MAX_CONCURRENT_ROUTES: %d must not be negative", cfg.MaxConcurrentRoutes))
+	}
+	if cfg.RouteQueueTimeout < 0 || cfg.RouteQueueTimeout > cfg.RouteTimeout {
+		problems = append(problems, fmt.Sprintf("ROUTE_QUEUE_TIMEOUT: %v must be between 0 and ROUTE_TIMEOUT", cfg.RouteQueueTimeout))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d configuration problem(s):\n  - %s", len(problems), strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// checkWritable checks the database file, or the directory it will be created in, can be written
+func checkWritable(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".writecheck-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
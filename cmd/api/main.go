@@ -4,24 +4,39 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"text/template"
 	"time"
+	_ "time/tzdata" // supercharger arrival times need timezone data, which the container lacks
 
+	"github.com/brensch/passengerprincess/frontend"
 	"github.com/brensch/passengerprincess/pkg/db"
 	"github.com/brensch/passengerprincess/pkg/maps"
+	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-// Global variable for the Google Maps API key.
-var googleAPIKey = os.Getenv("MAPS_API_KEY")
+// settings is the server configuration, loaded and validated at startup
+var settings = loadServerConfig()
+
+// adminToken is the shared secret required in the X-Admin-Token header for admin endpoints.
+// Admin endpoints are disabled when it is empty.
+var adminToken = os.Getenv("ADMIN_TOKEN")
 
 // gzipResponseWriter wraps http.ResponseWriter to enable gzip compression
 type gzipResponseWriter struct {
@@ -33,6 +48,16 @@ func (g *gzipResponseWriter) Write(data []byte) (int, error) {
 	return g.Writer.Write(data)
 }
 
+// Flush pushes any compressed data through to the client so streamed responses arrive promptly
+func (g *gzipResponseWriter) Flush() {
+	if gz, ok := g.Writer.(*gzip.Writer); ok {
+		gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // withGzip is a middleware that enables gzip compression for responses
 func withGzip(fn http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -49,6 +74,18 @@ func withGzip(fn http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// requireAdmin is a middleware that rejects requests without the admin token
+func requireAdmin(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			writeJSONError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fn(w, r)
+	}
+}
+
 // generateSessionToken creates a random session token for Google Places Autocomplete
 func generateSessionToken() (string, error) {
 	bytes := make([]byte, 16)
@@ -58,68 +95,207 @@ func generateSessionToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// savedTripTTL is how long a shared trip link stays valid
+const savedTripTTL = 30 * 24 * time.Hour
+
+// tripSlugLength is the length of a saved trip slug, 6 random bytes in URL safe base64
+const tripSlugLength = 8
+
+// generateTripSlug creates a random slug for a saved trip link
+func generateTripSlug() (string, error) {
+	bytes := make([]byte, 6)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// isValidTripSlug checks a slug has the shape generateTripSlug produces
+func isValidTripSlug(slug string) bool {
+	if len(slug) != tripSlugLength {
+		return false
+	}
+	for _, c := range slug {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// pruneSavedTrips deletes expired saved trips every interval until the process exits
+func pruneSavedTrips(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		deleted, err := db.GetDefaultService().SavedTrip.DeleteExpired(time.Now())
+		if err != nil {
+			log.Printf("Error pruning saved trips: %v", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Pruned %d expired saved trips", deleted)
+		}
+	}
+}
+
+// shutdownTimeout is how long requests in flight get to finish once the server is told to stop
+const shutdownTimeout = 30 * time.Second
+
 func main() {
-	// Check if the API key is set.
-	if googleAPIKey == "" {
-		googleAPIKey = "YOUR_GOOGLE_MAPS_API_KEY" // Fallback for local testing
-		log.Println("WARNING: MAPS_API_KEY environment variable not set. Using placeholder.")
+	// Report every configuration problem at once rather than failing on the first request
+	if err := ValidateConfig(settings); err != nil {
+		log.Fatalf("FATAL: Invalid configuration, %v", err)
 	}
-	if googleAPIKey == "YOUR_GOOGLE_MAPS_API_KEY" {
-		log.Fatal("FATAL: Please replace 'YOUR_GOOGLE_MAPS_API_KEY' with your actual Google Maps API key.")
+
+	if settings.LogLevel != 0 {
+		maps.SetLogLevel(settings.LogLevel)
+	}
+	maps.SetCallTimeout(settings.CallTimeout)
+	maps.SetRetryPolicy(settings.Retry)
+
+	// Identify our traffic in Google's API dashboards
+	if userAgent := os.Getenv("MAPS_USER_AGENT"); userAgent != "" {
+		maps.SetUserAgent(userAgent)
 	}
 
 	// Initialize database
 	config := &db.Config{
-		DatabasePath: "db/passengerprincess.db",
+		DatabasePath: settings.DatabasePath,
 		LogLevel:     logger.Warn,
 	}
 	if err := db.Initialize(config); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	// Register handlers.
-	http.HandleFunc("/", withGzip(serveFrontend)) // Serve the HTML file at the root
-	http.HandleFunc("/autocomplete", withGzip(autocompleteHandler))
-	http.HandleFunc("/route", withGzip(routeHandler))
-	http.HandleFunc("/superchargers/viewport", withGzip(viewportHandler))
+	// Cache hits are buffered so recording them doesn't add a write to every cached read
+	cacheHitBuffer := db.NewCacheHitBuffer(db.GetDefaultService().CacheHit, 500, 30*time.Second)
+	maps.SetCacheHitBuffer(cacheHitBuffer)
+	var callLogBuffer *db.MapsCallLogBuffer
+	if settings.LogMapsCalls {
+		// Buffered like cache hits so logging doesn't add a write to every Google call
+		callLogBuffer = db.NewMapsCallLogBuffer(db.GetDefaultService().MapsCallLog, 200, 30*time.Second)
+		maps.SetCallLog(callLogBuffer)
+	}
+
+	go pruneSavedTrips(time.Hour)
+
+	// Stop on Ctrl-C or a deploy's SIGTERM, letting requests in flight finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Start the server.
-	port := "8040"
+	port := settings.Port
+	server := &http.Server{Addr: ":" + port, Handler: newRouter()}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
 	log.Printf("Server starting...")
 	log.Printf("Access the web interface at http://localhost:%s/", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		log.Printf("Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Warning: server did not shut down cleanly: %v", err)
+		}
+	}
+
+	// Flush buffered writes explicitly, log.Fatalf and signals skip deferred calls
+	if err := cacheHitBuffer.Close(); err != nil {
+		log.Printf("Warning: failed to flush cache hits: %v", err)
+	}
+	if callLogBuffer != nil {
+		if err := callLogBuffer.Close(); err != nil {
+			log.Printf("Warning: failed to flush maps call logs: %v", err)
+		}
+	}
+	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
 
+// newRouter registers every endpoint with the methods it accepts. Requests with other methods get
+// a 405 from the mux, so handlers don't check the method themselves.
+func newRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", withGzip(serveFrontend)) // Serve the HTML file at the root only, so unknown paths 404
+	mux.HandleFunc("GET /openapi.json", withGzip(openAPIHandler))
+	mux.HandleFunc("GET /autocomplete", withGzip(autocompleteHandler))
+	mux.HandleFunc("GET /route", withGzip(routeHandler))
+	mux.HandleFunc("GET /route.geojson", withGzip(routeGeoJSONHandler))
+	mux.HandleFunc("GET /route.gpx", withGzip(routeGPXHandler))
+	mux.HandleFunc("GET /superchargers/viewport", withGzip(viewportHandler))
+	mux.HandleFunc("GET /superchargers/nearest", withGzip(nearestHandler))
+	mux.HandleFunc("GET /superchargers/all.geojson", withGzip(superchargersGeoJSONHandler))
+	mux.HandleFunc("GET /superchargers/{placeId}", withGzip(superchargerHandler))
+	mux.HandleFunc("POST /trips", withGzip(createTripHandler))
+	mux.HandleFunc("GET /trips/{slug}", withGzip(tripHandler))
+	mux.HandleFunc("GET /places/{id}/location", withGzip(withRateLimit(placeLocationLimiter, placeLocationHandler)))
+	if adminToken != "" {
+		mux.HandleFunc("GET /admin/stats", withGzip(requireAdmin(adminStatsHandler)))
+		mux.HandleFunc("GET /admin/logs/maps", withGzip(requireAdmin(adminMapsLogsHandler)))
+		mux.HandleFunc("GET /admin/coverage", withGzip(requireAdmin(adminCoverageHandler)))
+	} else {
+		log.Println("ADMIN_TOKEN not set, admin endpoints disabled")
+	}
+	return mux
+}
+
 // writeJSONError sends a JSON-formatted error message.
 func writeJSONError(w http.ResponseWriter, message string, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+	json.NewEncoder(w).Encode(errorResponse{Error: message})
 }
 
-// serveFrontend serves the frontend HTML file with API key templating
-func serveFrontend(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+// writeJSON encodes v before anything is written, so an encoding failure gets a proper 500 rather than a
+// truncated body behind a success status. Content-Type defaults to application/json if not already set.
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		// freshness headers describe the result that couldn't be sent
+		w.Header().Del("Cache-Control")
+		w.Header().Del("Age")
+		writeJSONError(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 
-	// Read the frontend HTML file
-	htmlContent, err := os.ReadFile("frontend/index.html")
-	if err != nil {
-		log.Printf("Error reading frontend file: %v", err)
-		writeJSONError(w, "Could not load frontend", http.StatusInternalServerError)
-		return
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
 	}
+	w.WriteHeader(statusCode)
+	w.Write(append(data, '\n'))
+}
 
-	// Parse the template and inject the API key
-	tmpl, err := template.New("frontend").Parse(string(htmlContent))
-	if err != nil {
-		log.Printf("Error parsing frontend template: %v", err)
-		writeJSONError(w, "Could not parse frontend", http.StatusInternalServerError)
-		return
+// embeddedFrontend is the frontend template bundled into the binary
+var embeddedFrontend = template.Must(template.New("frontend").Parse(frontend.IndexHTML))
+
+// serveFrontend serves the frontend HTML file with API key templating
+func serveFrontend(w http.ResponseWriter, r *http.Request) {
+	tmpl := embeddedFrontend
+	if settings.FrontendPath != "" {
+		// Read the frontend HTML file from disk so edits show up without a rebuild
+		htmlContent, err := os.ReadFile(settings.FrontendPath)
+		if err != nil {
+			log.Printf("Error reading frontend file: %v", err)
+			writeJSONError(w, "Could not load frontend", http.StatusInternalServerError)
+			return
+		}
+
+		// Parse the template and inject the API key
+		tmpl, err = template.New("frontend").Parse(string(htmlContent))
+		if err != nil {
+			log.Printf("Error parsing frontend template: %v", err)
+			writeJSONError(w, "Could not parse frontend", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// Set content type to HTML
@@ -129,7 +305,7 @@ func serveFrontend(w http.ResponseWriter, r *http.Request) {
 	data := struct {
 		APIKey string
 	}{
-		APIKey: googleAPIKey,
+		APIKey: settings.APIKey,
 	}
 
 	if err := tmpl.Execute(w, data); err != nil {
@@ -141,11 +317,6 @@ func serveFrontend(w http.ResponseWriter, r *http.Request) {
 
 // autocompleteHandler handles place autocomplete requests
 func autocompleteHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	partial := strings.TrimSpace(r.URL.Query().Get("partial"))
 	if partial == "" {
 		writeJSONError(w, "partial parameter is required", http.StatusBadRequest)
@@ -166,65 +337,661 @@ func autocompleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), settings.RequestTimeout)
 	defer cancel()
 
 	// Get autocomplete suggestions with session token
-	suggestions, err := maps.GetAutocompleteSuggestions(ctx, googleAPIKey, partial, sessionToken)
+	suggestions, err := maps.GetAutocompleteSuggestions(ctx, settings.APIKey, partial, sessionToken)
 	if err != nil {
 		log.Printf("Error getting autocomplete suggestions: %v", err)
 		writeJSONError(w, "Failed to get autocomplete suggestions", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"predictions":   suggestions,
-		"session_token": sessionToken,
+	writeJSON(w, http.StatusOK, autocompleteResponse{
+		Predictions:  suggestions,
+		SessionToken: sessionToken,
 	})
 }
 
-// routeHandler handles route planning requests with superchargers
-func routeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// maxWalkingTopN caps how many restaurants per supercharger walking distances are requested for
+const maxWalkingTopN = 10
+
+// emptyResultMode is how route and viewport responses signal that no superchargers were found
+type emptyResultMode string
+
+const (
+	emptyAsArray     emptyResultMode = "array"      // 200 with an empty list, the default
+	emptyAsReport    emptyResultMode = "report"     // 200 with feasible and message fields
+	emptyAsNoContent emptyResultMode = "no_content" // 204 with no body
+)
+
+// parseEmptyMode reads the empty parameter, defaulting to an empty list
+func parseEmptyMode(query url.Values) (emptyResultMode, error) {
+	switch mode := emptyResultMode(strings.TrimSpace(query.Get("empty"))); mode {
+	case "":
+		return emptyAsArray, nil
+	case emptyAsArray, emptyAsReport, emptyAsNoContent:
+		return mode, nil
+	default:
+		return "", errors.New("Invalid empty parameter, must be 'array', 'report' or 'no_content'")
 	}
+}
 
-	origin := strings.TrimSpace(r.URL.Query().Get("origin"))
-	destination := strings.TrimSpace(r.URL.Query().Get("destination"))
+// routeRequest holds the parsed parameters of a route planning request
+type routeRequest struct {
+	origin      string
+	destination string
+	config      *maps.SearchConfig
+	flat        bool
+	emptyMode   emptyResultMode
+	simplified  bool
+}
 
-	if origin == "" || destination == "" {
-		writeJSONError(w, "Both origin and destination parameters are required", http.StatusBadRequest)
-		return
+// parseRouteRequest validates route planning parameters. Errors are meant to be shown to the client.
+func parseRouteRequest(query url.Values) (*routeRequest, error) {
+	req := &routeRequest{
+		origin:      strings.TrimSpace(query.Get("origin")),
+		destination: strings.TrimSpace(query.Get("destination")),
+		config:      maps.DefaultSearchConfig(),
 	}
+	req.config.CacheTTL = settings.CacheTTL
+	req.config.RouteReuseMaxAge = settings.RouteReuseMaxAge
+	req.config.SeparateRejectedPlaces = settings.SeparateRejectedPlaces
+	req.config.MinMatchConfidence = settings.MinMatchConfidence
+	req.config.MaxRestaurantsPerSupercharger = settings.MaxRestaurantsPerSupercharger
+	req.config.MinCachedPerCircle = settings.MinCachedPerCircle
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if req.origin == "" || req.destination == "" {
+		return nil, errors.New("Both origin and destination parameters are required")
+	}
+
+	switch sortBy := strings.TrimSpace(query.Get("sort")); sortBy {
+	case "", string(maps.SortByDistanceAlongRoute):
+	case string(maps.SortByArrivalTime):
+		req.config.SortBy = maps.SortByArrivalTime
+	case string(maps.SortByFoodRating):
+		req.config.SortBy = maps.SortByFoodRating
+	default:
+		return nil, errors.New("Invalid sort parameter, must be 'distance', 'eta' or 'food'")
+	}
+
+	// Clients that format times themselves can ask for a machine readable arrival_time
+	switch etaFormat := strings.TrimSpace(query.Get("eta_format")); etaFormat {
+	case "", string(maps.ETAFormatKitchen):
+	case string(maps.ETAFormatRFC3339), string(maps.ETAFormatEpochMS):
+		req.config.ETAFormat = maps.ETAFormat(etaFormat)
+	default:
+		return nil, errors.New("Invalid eta_format parameter, must be 'kitchen', 'rfc3339' or 'epoch_ms'")
+	}
+
+	// Skipping live traffic uses a cheaper routing SKU
+	switch traffic := strings.TrimSpace(query.Get("traffic")); traffic {
+	case "", "optimal":
+	case "aware":
+		req.config.RouteOptions.RoutingPreference = maps.RoutingTrafficAware
+	case "unaware":
+		req.config.RouteOptions.RoutingPreference = maps.RoutingTrafficUnaware
+	default:
+		return nil, errors.New("Invalid traffic parameter, must be 'optimal', 'aware' or 'unaware'")
+	}
+
+	// By default routes fall back to cheaper preferences where traffic data is unavailable
+	if fallbackStr := query.Get("traffic_fallback"); fallbackStr != "" {
+		fallback, err := strconv.ParseBool(fallbackStr)
+		if err != nil {
+			return nil, errors.New("Invalid traffic_fallback parameter")
+		}
+		if !fallback {
+			req.config.RouteOptions.Fallbacks = []maps.RoutingPreference{}
+		}
+	}
+
+	switch mode := strings.TrimSpace(query.Get("mode")); mode {
+	case "", "drive":
+	case "two_wheeler":
+		req.config.RouteOptions.TravelMode = maps.TravelModeTwoWheeler
+	default:
+		return nil, errors.New("Invalid mode parameter, must be 'drive' or 'two_wheeler'")
+	}
+
+	// Roads to stay off, e.g. avoid=tolls,ferries
+	if avoidStr := strings.TrimSpace(query.Get("avoid")); avoidStr != "" {
+		for _, feature := range strings.Split(avoidStr, ",") {
+			switch strings.TrimSpace(feature) {
+			case "tolls":
+				req.config.RouteOptions.AvoidTolls = true
+			case "highways":
+				req.config.RouteOptions.AvoidHighways = true
+			case "ferries":
+				req.config.RouteOptions.AvoidFerries = true
+			default:
+				return nil, fmt.Errorf("Invalid avoid parameter %q, must be a comma separated list of 'tolls', 'highways' and 'ferries'", feature)
+			}
+		}
+	}
+
+	// Turn-by-turn directions for a directions panel, without a separate Directions call
+	if stepsStr := query.Get("steps"); stepsStr != "" {
+		steps, err := strconv.ParseBool(stepsStr)
+		if err != nil {
+			return nil, errors.New("Invalid steps parameter")
+		}
+		req.config.RouteOptions.IncludeSteps = steps
+	}
+
+	// Raw coordinates are larger than the encoded polyline, so they're only sent when asked for
+	switch encoding := strings.TrimSpace(query.Get("polyline")); encoding {
+	case "", "encoded":
+	case "geojson":
+		req.config.RouteOptions.PolylineEncoding = maps.PolylineEncodingGeoJSON
+	default:
+		return nil, errors.New("Invalid polyline parameter, must be 'encoded' or 'geojson'")
+	}
+
+	// Simplified polylines let zoomed out maps draw less, but most clients don't want the extra bytes
+	if simplifiedStr := query.Get("simplified"); simplifiedStr != "" {
+		simplified, err := strconv.ParseBool(simplifiedStr)
+		if err != nil {
+			return nil, errors.New("Invalid simplified parameter")
+		}
+		req.simplified = simplified
+	}
+
+	// Vehicle range is optional and only affects charger scoring
+	if rangeStr := query.Get("range_km"); rangeStr != "" {
+		rangeKm, err := strconv.ParseFloat(rangeStr, 64)
+		if err != nil || rangeKm <= 0 {
+			return nil, errors.New("Invalid range_km parameter")
+		}
+		req.config.Score.RangeMeters = rangeKm * 1000
+	}
+
+	// Skipping restaurants avoids a places search per charger
+	if restaurantsStr := query.Get("restaurants"); restaurantsStr != "" {
+		fetchRestaurants, err := strconv.ParseBool(restaurantsStr)
+		if err != nil {
+			return nil, errors.New("Invalid restaurants parameter")
+		}
+		req.config.FetchRestaurants = fetchRestaurants
+	}
+
+	// Charger ratings are billed at a higher rate so they're only fetched when asked for
+	if reviewsStr := query.Get("reviews"); reviewsStr != "" {
+		fetchReviews, err := strconv.ParseBool(reviewsStr)
+		if err != nil {
+			return nil, errors.New("Invalid reviews parameter")
+		}
+		req.config.FetchReviews = fetchReviews
+	}
+
+	// Searching in the local language and region finds chargers more reliably outside the US
+	req.config.Locale = maps.Locale{
+		LanguageCode: strings.TrimSpace(query.Get("language")),
+		RegionCode:   strings.TrimSpace(query.Get("region")),
+	}
+
+	// During a trip the client sends where the driver is so ETAs count from there
+	currentLatStr, currentLngStr := query.Get("current_lat"), query.Get("current_lng")
+	if currentLatStr != "" || currentLngStr != "" {
+		currentLat, err := strconv.ParseFloat(currentLatStr, 64)
+		if err != nil {
+			return nil, errors.New("Invalid current_lat parameter")
+		}
+		currentLng, err := strconv.ParseFloat(currentLngStr, 64)
+		if err != nil {
+			return nil, errors.New("Invalid current_lng parameter")
+		}
+		req.config.CurrentPosition = &maps.Center{Latitude: currentLat, Longitude: currentLng}
+	}
+
+	if radiusStr := query.Get("restaurant_radius_m"); radiusStr != "" {
+		radius, err := strconv.ParseFloat(radiusStr, 64)
+		// the Places API rejects radii over 50km
+		if err != nil || radius <= 0 || radius > 50000 {
+			return nil, errors.New("Invalid restaurant_radius_m parameter")
+		}
+		req.config.RestaurantRadiusMeters = radius
+	}
+
+	// Capping circles widens the search radius on long routes, trading precision for fewer billed searches
+	if maxCirclesStr := query.Get("max_circles"); maxCirclesStr != "" {
+		maxCircles, err := strconv.Atoi(maxCirclesStr)
+		if err != nil || maxCircles < 1 {
+			return nil, errors.New("Invalid max_circles parameter")
+		}
+		req.config.MaxCircles = maxCircles
+	}
+
+	// Very long routes can be searched a page of circles at a time, resuming from next_continuation
+	if perPageStr := query.Get("circles_per_page"); perPageStr != "" {
+		perPage, err := strconv.Atoi(perPageStr)
+		if err != nil || perPage < 1 {
+			return nil, errors.New("Invalid circles_per_page parameter")
+		}
+		req.config.CirclesPerPage = perPage
+	}
+	if token := query.Get("continuation"); token != "" {
+		continueFrom, err := maps.ParseContinuation(token)
+		if err != nil {
+			return nil, errors.New("Invalid continuation parameter")
+		}
+		req.config.ContinueFrom = continueFrom
+	}
+
+	// Walking distances cost a Route Matrix call per charger so they're only fetched when asked for
+	if walkingStr := query.Get("walking_top_n"); walkingStr != "" {
+		walkingTopN, err := strconv.Atoi(walkingStr)
+		if err != nil || walkingTopN < 0 || walkingTopN > maxWalkingTopN {
+			return nil, fmt.Errorf("Invalid walking_top_n parameter, must be between 0 and %d", maxWalkingTopN)
+		}
+		req.config.WalkingDistanceTopN = walkingTopN
+	}
+
+	// On multi-day trips the client sends the chargers it already used so they aren't suggested again
+	if excludeStr := query.Get("exclude"); excludeStr != "" {
+		for _, placeID := range strings.Split(excludeStr, ",") {
+			placeID = strings.TrimSpace(placeID)
+			if !maps.IsValidPlaceID(placeID) {
+				return nil, errors.New("Invalid exclude parameter, must be a comma separated list of place IDs")
+			}
+			req.config.ExcludePlaceIDs = append(req.config.ExcludePlaceIDs, placeID)
+		}
+	}
+
+	// Well cached corridors can skip most circle searches, at the risk of missing newly opened chargers
+	if preferStr := query.Get("prefer_cached"); preferStr != "" {
+		preferCached, err := strconv.ParseBool(preferStr)
+		if err != nil {
+			return nil, errors.New("Invalid prefer_cached parameter")
+		}
+		req.config.PreferCached = preferCached
+	}
+
+	// The flat shape lists each restaurant once instead of under every nearby charger
+	if flatStr := query.Get("flat"); flatStr != "" {
+		flat, err := strconv.ParseBool(flatStr)
+		if err != nil {
+			return nil, errors.New("Invalid flat parameter")
+		}
+		req.flat = flat
+	}
+
+	emptyMode, err := parseEmptyMode(query)
+	if err != nil {
+		return nil, err
+	}
+	req.emptyMode = emptyMode
+
+	return req, nil
+}
+
+// routeSlots holds a token for each route being planned, limiting them to MaxConcurrentRoutes across the
+// whole server. It is nil when the number is unlimited.
+var routeSlots = newRouteSlots(settings.MaxConcurrentRoutes)
+
+func newRouteSlots(n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+	return make(chan struct{}, n)
+}
+
+// acquireRouteSlot waits up to RouteQueueTimeout for a route slot, returning the function that frees it.
+// It returns false if the server stayed busy for the whole wait.
+func acquireRouteSlot() (func(), bool) {
+	if routeSlots == nil {
+		return func() {}, true
+	}
+	release := func() { <-routeSlots }
+
+	select {
+	case routeSlots <- struct{}{}:
+		return release, true
+	default:
+	}
+	if settings.RouteQueueTimeout <= 0 {
+		return nil, false
+	}
+
+	timer := time.NewTimer(settings.RouteQueueTimeout)
+	defer timer.Stop()
+	select {
+	case routeSlots <- struct{}{}:
+		return release, true
+	case <-timer.C:
+		return nil, false
+	}
+}
+
+// errRouteSlotsBusy is returned by planRoute when every route slot stayed busy for the queue timeout
+var errRouteSlotsBusy = errors.New("server is busy planning other routes, try again shortly")
+
+// planRoute finds the superchargers for a parsed route request, stopping early if ctx, the client's
+// request context, is cancelled. If it fails the error response has already been written and the error
+// is returned for logging.
+func planRoute(ctx context.Context, w http.ResponseWriter, req *routeRequest) (*maps.SuperchargersOnRouteResult, error) {
+	release, ok := acquireRouteSlot()
+	if !ok {
+		log.Printf("Warning: rejecting route request, all %d route slots busy", cap(routeSlots))
+		w.Header().Set("Retry-After", strconv.Itoa(max(int(settings.RouteQueueTimeout.Seconds()), 1)))
+		writeJSONError(w, "Server is busy planning other routes, try again shortly", http.StatusServiceUnavailable)
+		return nil, errRouteSlotsBusy
+	}
+	defer release()
+
+	// Create context with timeout, so a client that disconnects also stops Google calls and their retries
+	ctx, cancel := context.WithTimeout(ctx, settings.RouteTimeout)
 	defer cancel()
 
 	// Get database service
 	service := db.GetDefaultService()
 
 	// Get route with superchargers
-	result, err := maps.GetSuperchargersOnRoute(ctx, service, googleAPIKey, origin, destination)
+	result, err := maps.GetSuperchargersOnRoute(ctx, service, settings.APIKey, req.origin, req.destination, req.config)
 	if err != nil {
 		log.Printf("Error getting superchargers on route: %v", err)
+		if errors.Is(err, maps.ErrRouteTooLong) || errors.Is(err, maps.ErrEmptyRoute) {
+			writeJSONError(w, err.Error(), http.StatusUnprocessableEntity)
+			return nil, err
+		}
 		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	if settings.StaticMapsAPIKey != "" {
+		result.StaticMapURL = maps.StaticMapURL(result.Route, result.Superchargers, maps.DefaultStaticMapSize, settings.StaticMapsAPIKey)
+	}
+	if req.simplified {
+		result.SimplifiedPolylines, err = result.Route.SimplifiedPolylines(maps.DefaultSimplifyTolerances)
+		if err != nil {
+			// the full polyline is still there, so the route is usable without them
+			log.Printf("Warning: failed to simplify route polyline: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// clientIP returns the address a request came from, taken from X-Forwarded-For when the proxy is trusted
+func clientIP(r *http.Request) string {
+	if settings.TrustForwardedFor {
+		// the first entry is the client, later ones are proxies it passed through
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			client, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(client)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// logRouteCall records a /route request for analysing popular routes and failures. The write happens in
+// the background so the response never waits on it, and failing to write is only logged.
+func logRouteCall(r *http.Request, callErr error) {
+	entry := &db.RouteCallLog{
+		Timestamp:   time.Now(),
+		Origin:      r.URL.Query().Get("origin"),
+		Destination: r.URL.Query().Get("destination"),
+		IPAddress:   clientIP(r),
+	}
+	if callErr != nil {
+		entry.Error = callErr.Error()
+	}
+
+	go func() {
+		if err := db.GetDefaultService().RouteCallLog.Create(entry); err != nil {
+			log.Printf("Warning: failed to log route call: %v", err)
+		}
+	}()
+}
+
+// serveRoute parses and plans the route of a request, logging the call, and passes the result to respond.
+// Failures are written to w before respond would be called.
+func serveRoute(w http.ResponseWriter, r *http.Request, respond func(*routeRequest, *maps.SuperchargersOnRouteResult)) {
+	var callErr error
+	defer func() { logRouteCall(r, callErr) }()
+
+	req, err := parseRouteRequest(r.URL.Query())
+	if err != nil {
+		callErr = err
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := planRoute(r.Context(), w, req)
+	if err != nil {
+		callErr = err
+		return
+	}
+	setRouteFreshness(w, result.Route.FetchedAt)
+	respond(req, result)
+}
+
+// routeHandler handles route planning requests with superchargers
+func routeHandler(w http.ResponseWriter, r *http.Request) {
+	serveRoute(w, r, func(req *routeRequest, result *maps.SuperchargersOnRouteResult) {
+		if len(result.Superchargers) == 0 && result.NextContinuation == "" && req.emptyMode == emptyAsNoContent {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var response interface{} = result
+		if req.flat {
+			response = result.Flatten()
+		}
+		if req.emptyMode == emptyAsReport {
+			report := newFeasibility(len(result.Superchargers), "Route found but no superchargers are along it")
+			if flat, ok := response.(*maps.FlatSuperchargersOnRouteResult); ok {
+				response = flatRouteReport{flat, report}
+			} else {
+				response = routeReport{result, report}
+			}
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+// routeGeoJSONHandler plans a route like routeHandler but returns it as a GeoJSON FeatureCollection, for
+// loading into GIS tools and map libraries
+func routeGeoJSONHandler(w http.ResponseWriter, r *http.Request) {
+	serveRoute(w, r, func(req *routeRequest, result *maps.SuperchargersOnRouteResult) {
+		data, err := maps.MarshalRouteGeoJSON(result)
+		if err != nil {
+			log.Printf("Error encoding route GeoJSON: %v", err)
+			w.Header().Del("Cache-Control")
+			w.Header().Del("Age")
+			writeJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	})
+}
+
+// routeGPXHandler plans a route like routeHandler but returns it as a GPX track with a waypoint for each
+// supercharger, for importing into navigation apps
+func routeGPXHandler(w http.ResponseWriter, r *http.Request) {
+	serveRoute(w, r, func(req *routeRequest, result *maps.SuperchargersOnRouteResult) {
+		data, err := maps.MarshalRouteGPX(result)
+		if err != nil {
+			log.Printf("Error encoding route GPX: %v", err)
+			w.Header().Del("Cache-Control")
+			w.Header().Del("Age")
+			writeJSONError(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gpx+xml")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	})
+}
+
+// setRouteFreshness lets clients and CDNs cache a route response until its traffic data is
+// RouteCacheMaxAge old. Age reports how old the route already is, which matters for stored routes.
+func setRouteFreshness(w http.ResponseWriter, fetchedAt time.Time) {
+	if settings.RouteCacheMaxAge <= 0 {
+		w.Header().Set("Cache-Control", "no-store")
+		return
+	}
+	age := max(time.Since(fetchedAt), 0)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(settings.RouteCacheMaxAge.Seconds())))
+	w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+}
+
+// createTripHandler plans a route and saves the result under a short slug so it can be shared.
+// It takes the same query parameters as /route.
+func createTripHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req, err := parseRouteRequest(query)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The result is planned here rather than taken from the client so shared links can't be forged
+	result, err := planRoute(r.Context(), w, req)
+	if err != nil {
+		return
+	}
+
+	var snapshot []byte
+	if req.flat {
+		snapshot, err = json.Marshal(result.Flatten())
+	} else {
+		snapshot, err = json.Marshal(result)
+	}
+	if err != nil {
+		log.Printf("Error encoding trip: %v", err)
+		writeJSONError(w, "Failed to save trip", http.StatusInternalServerError)
+		return
+	}
+
+	slug, err := generateTripSlug()
+	if err != nil {
+		log.Printf("Error generating trip slug: %v", err)
+		writeJSONError(w, "Failed to save trip", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	trip := &db.SavedTrip{
+		Slug:        slug,
+		Origin:      req.origin,
+		Destination: req.destination,
+		Params:      query.Encode(),
+		Result:      string(snapshot),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(savedTripTTL),
+	}
+	if err := db.GetDefaultService().SavedTrip.Create(trip); err != nil {
+		log.Printf("Error saving trip: %v", err)
+		writeJSONError(w, "Failed to save trip", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, trip)
+}
+
+// tripHandler returns the route result stored for a saved trip
+func tripHandler(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	if !isValidTripSlug(slug) {
+		writeJSONError(w, "Invalid trip slug", http.StatusBadRequest)
+		return
+	}
+
+	trip, err := db.GetDefaultService().SavedTrip.GetBySlug(slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeJSONError(w, "Trip not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting trip %s: %v", slug, err)
+		writeJSONError(w, "Failed to get trip", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	io.WriteString(w, trip.Result)
 }
 
-// viewportHandler handles requests for superchargers within a viewport
-func viewportHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+const (
+	// defaultTopRestaurants is how many restaurants the supercharger popup shows
+	defaultTopRestaurants = 5
+	// maxTopRestaurants caps the top parameter of /superchargers/{placeId}
+	maxTopRestaurants = 50
+)
+
+// superchargerHandler handles requests for a single supercharger and its restaurants
+func superchargerHandler(w http.ResponseWriter, r *http.Request) {
+	placeID := r.PathValue("placeId")
+	if !maps.IsValidPlaceID(placeID) {
+		writeJSONError(w, "Invalid place ID", http.StatusBadRequest)
+		return
+	}
+
+	// the popup only shows the best few restaurants, so rank and cap them here rather than in the browser
+	top := defaultTopRestaurants
+	if topStr := r.URL.Query().Get("top"); topStr != "" {
+		n, err := strconv.Atoi(topStr)
+		if err != nil || n < 1 || n > maxTopRestaurants {
+			writeJSONError(w, fmt.Sprintf("Invalid top parameter, must be between 1 and %d", maxTopRestaurants), http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+	var minRating float64
+	if ratingStr := r.URL.Query().Get("min_rating"); ratingStr != "" {
+		rating, err := strconv.ParseFloat(ratingStr, 64)
+		if err != nil || rating < 0 || rating > 5 {
+			writeJSONError(w, "Invalid min_rating parameter, must be between 0 and 5", http.StatusBadRequest)
+			return
+		}
+		minRating = rating
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), settings.RequestTimeout)
+	defer cancel()
+
+	// Get database service
+	service := db.GetDefaultService()
+
+	config := maps.DefaultSearchConfig()
+	config.CacheTTL = settings.CacheTTL
+	config.SeparateRejectedPlaces = settings.SeparateRejectedPlaces
+	config.MinMatchConfidence = settings.MinMatchConfidence
+	config.MaxRestaurantsPerSupercharger = settings.MaxRestaurantsPerSupercharger
+	supercharger, restaurants, err := maps.GetSuperchargerWithCache(ctx, service, settings.APIKey, placeID, config)
+	if err != nil {
+		log.Printf("Error getting supercharger %s: %v", placeID, err)
+		writeJSONError(w, "Failed to get supercharger", http.StatusInternalServerError)
 		return
 	}
 
+	topRestaurants := []db.RestaurantWithDistance{}
+	if supercharger.IsSupercharger {
+		topRestaurants, err = service.Supercharger.GetTopRestaurants(placeID, top, minRating)
+		if err != nil {
+			log.Printf("Error getting top restaurants for %s: %v", placeID, err)
+			writeJSONError(w, "Failed to get supercharger", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, superchargerResponse{
+		Supercharger:   supercharger,
+		Restaurants:    restaurants,
+		TopRestaurants: topRestaurants,
+	})
+}
+
+// viewportHandler handles requests for superchargers within a viewport
+func viewportHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse viewport bounds from query parameters
 	minLatStr := r.URL.Query().Get("min_lat")
 	maxLatStr := r.URL.Query().Get("max_lat")
@@ -260,9 +1027,21 @@ func viewportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	emptyMode, err := parseEmptyMode(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get database service
 	service := db.GetDefaultService()
 
+	// Dense areas can hold thousands of chargers, so clients may ask for them as a stream
+	if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		streamViewport(w, service, minLat, maxLat, minLng, maxLng)
+		return
+	}
+
 	// Get superchargers within the viewport bounds
 	superchargers, err := service.Supercharger.GetByLocation(minLat, maxLat, minLng, maxLng)
 	if err != nil {
@@ -271,8 +1050,386 @@ func viewportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"superchargers": superchargers,
+	if len(superchargers) == 0 && emptyMode == emptyAsNoContent {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	response := viewportResponse{Superchargers: superchargers}
+	if emptyMode == emptyAsReport {
+		report := newFeasibility(len(superchargers), "No cached superchargers in this area")
+		response.feasibility = &report
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// placeLocationLimiter limits how often each client can call /places/{id}/location, nil when unlimited
+var placeLocationLimiter = newClientRateLimiter(settings.PlaceLocationRateLimit, time.Minute)
+
+// placeLocationHandler returns just a place's coordinates, e.g. to centre the map on an autocomplete
+// result without planning a route
+func placeLocationHandler(w http.ResponseWriter, r *http.Request) {
+	placeID := r.PathValue("id")
+	if !maps.IsValidPlaceID(placeID) {
+		writeJSONError(w, "Invalid place ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), settings.RequestTimeout)
+	defer cancel()
+
+	location, err := maps.ResolvePlaceLocation(ctx, db.GetDefaultService(), settings.APIKey, placeID)
+	if errors.Is(err, maps.ErrPlaceNotFound) {
+		writeJSONError(w, "Place not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, maps.ErrPlaceHasNoLocation) {
+		writeJSONError(w, "Place has no location", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error resolving location of place %s: %v", placeID, err)
+		writeJSONError(w, "Failed to get place location", http.StatusInternalServerError)
+		return
+	}
+
+	// places don't move, so clients can hold on to the answer
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	writeJSON(w, http.StatusOK, placeLocationResponse{PlaceID: placeID, Location: *location})
+}
+
+// Limits on how many superchargers /superchargers/nearest returns
+const (
+	defaultNearestLimit = 10
+	maxNearestLimit     = 100
+)
+
+// nearestHandler returns the superchargers closest to a point, for finding chargers without planning a route
+func nearestHandler(w http.ResponseWriter, r *http.Request) {
+	lat, err := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		writeJSONError(w, "Invalid lat parameter, must be between -90 and 90", http.StatusBadRequest)
+		return
+	}
+	lng, err := strconv.ParseFloat(r.URL.Query().Get("lng"), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		writeJSONError(w, "Invalid lng parameter, must be between -180 and 180", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultNearestLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 || n > maxNearestLimit {
+			writeJSONError(w, fmt.Sprintf("Invalid limit parameter, must be between 1 and %d", maxNearestLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	// with the driver's remaining range, chargers out of reach are dropped and those only just in reach flagged
+	var rangeMeters float64
+	if rangeStr := r.URL.Query().Get("range_km"); rangeStr != "" {
+		rangeKm, err := strconv.ParseFloat(rangeStr, 64)
+		if err != nil || rangeKm <= 0 {
+			writeJSONError(w, "Invalid range_km parameter, must be a positive number", http.StatusBadRequest)
+			return
+		}
+		rangeMeters = rangeKm * 1000
+	}
+
+	nearest, err := db.GetDefaultService().Supercharger.GetNearest(lat, lng, limit)
+	if err != nil {
+		log.Printf("Error getting nearest superchargers: %v", err)
+		writeJSONError(w, "Failed to get superchargers", http.StatusInternalServerError)
+		return
+	}
+
+	var superchargers []maps.ReachableSupercharger
+	if rangeMeters > 0 {
+		candidates := make([]db.Supercharger, len(nearest))
+		for i, sc := range nearest {
+			candidates[i] = sc.Supercharger
+		}
+		superchargers = maps.FilterByRange(candidates, maps.Center{Latitude: lat, Longitude: lng}, rangeMeters)
+	} else {
+		superchargers = make([]maps.ReachableSupercharger, len(nearest))
+		for i, sc := range nearest {
+			superchargers[i] = maps.ReachableSupercharger{Supercharger: sc.Supercharger, Distance: sc.Distance}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, nearestResponse{Superchargers: superchargers})
+}
+
+// viewportBatchSize is how many superchargers are read from the database per flush when streaming
+const viewportBatchSize = 200
+
+// streamViewport writes superchargers within the bounds as newline-delimited JSON, one per line,
+// flushing after each database batch so the client can render them progressively
+func streamViewport(w http.ResponseWriter, service *db.Service, minLat, maxLat, minLng, maxLng float64) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	written := false
+
+	err := service.Supercharger.ForEachInLocation(minLat, maxLat, minLng, maxLng, viewportBatchSize, func(batch []db.Supercharger) error {
+		for i := range batch {
+			if err := encoder.Encode(&batch[i]); err != nil {
+				return err
+			}
+			written = true
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error streaming superchargers by location: %v", err)
+		if !written {
+			writeJSONError(w, "Failed to get superchargers", http.StatusInternalServerError)
+			return
+		}
+		// once a line has gone out the status is already sent, so end with an error line clients can check for.
+		// If the connection itself broke this can't be delivered either.
+		encoder.Encode(errorResponse{Error: "Failed to get superchargers"})
+	}
+}
+
+// geoJSONFeature is a supercharger as a GeoJSON point feature
+type geoJSONFeature struct {
+	Type     string `json:"type"`
+	Geometry struct {
+		Type        string     `json:"type"`
+		Coordinates [2]float64 `json:"coordinates"` // longitude, latitude as GeoJSON requires
+	} `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// superchargersGeoJSONHandler streams every confirmed supercharger as a GeoJSON FeatureCollection.
+// updated_since (RFC 3339) limits it to superchargers updated since then for incremental syncs.
+func superchargersGeoJSONHandler(w http.ResponseWriter, r *http.Request) {
+	var updatedSince time.Time
+	if sinceStr := r.URL.Query().Get("updated_since"); sinceStr != "" {
+		var err error
+		updatedSince, err = time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			writeJSONError(w, "Invalid updated_since parameter, must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Get database service
+	service := db.GetDefaultService()
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	flusher, _ := w.(http.Flusher)
+	written := false
+
+	err := service.Supercharger.ForEachConfirmed(updatedSince, viewportBatchSize, func(batch []db.Supercharger) error {
+		for _, sc := range batch {
+			feature := geoJSONFeature{Type: "Feature"}
+			feature.Geometry.Type = "Point"
+			feature.Geometry.Coordinates = [2]float64{sc.Longitude, sc.Latitude}
+			feature.Properties = map[string]interface{}{
+				"place_id":     sc.PlaceID,
+				"name":         sc.Name,
+				"address":      sc.Address,
+				"time_zone":    sc.TimeZone,
+				"last_updated": sc.LastUpdated,
+			}
+			data, err := json.Marshal(feature)
+			if err != nil {
+				return err
+			}
+
+			prefix := ","
+			if !written {
+				prefix = `{"type":"FeatureCollection","features":[`
+				written = true
+			}
+			if _, err := io.WriteString(w, prefix); err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error streaming superchargers as GeoJSON: %v", err)
+		// once features have gone out the status is already sent, so the collection is left unterminated
+		if !written {
+			writeJSONError(w, "Failed to get superchargers", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !written {
+		io.WriteString(w, `{"type":"FeatureCollection","features":[`)
+	}
+	io.WriteString(w, "]}\n")
+}
+
+// adminCoverageHandler returns the convex hull of cached superchargers as a GeoJSON polygon feature,
+// showing roughly where there is charger data. The geometry is null until three chargers enclose an area.
+func adminCoverageHandler(w http.ResponseWriter, r *http.Request) {
+	// Get database service
+	service := db.GetDefaultService()
+
+	hull, err := service.Supercharger.CoverageHull()
+	if err != nil {
+		log.Printf("Error computing coverage hull: %v", err)
+		writeJSONError(w, "Failed to get coverage", http.StatusInternalServerError)
+		return
+	}
+
+	var geometry interface{}
+	if len(hull) >= 3 {
+		// GeoJSON rings are closed by repeating the first point
+		ring := make([][2]float64, 0, len(hull)+1)
+		for _, point := range append(hull, hull[0]) {
+			ring = append(ring, [2]float64{point.Longitude, point.Latitude})
+		}
+		geometry = map[string]interface{}{
+			"type":        "Polygon",
+			"coordinates": [][][2]float64{ring},
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"type":     "Feature",
+		"geometry": geometry,
+		"properties": map[string]interface{}{
+			"hull_points": len(hull),
+		},
+	})
+}
+
+// adminStatsHandler returns an overview of what's cached in the database
+func adminStatsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get database service
+	service := db.GetDefaultService()
+
+	superchargers, err := service.Supercharger.Count()
+	if err != nil {
+		log.Printf("Error counting superchargers: %v", err)
+		writeJSONError(w, "Failed to get stats", http.StatusInternalServerError)
+		return
+	}
+
+	confirmed, err := service.Supercharger.CountConfirmed()
+	if err != nil {
+		log.Printf("Error counting confirmed superchargers: %v", err)
+		writeJSONError(w, "Failed to get stats", http.StatusInternalServerError)
+		return
+	}
+
+	restaurants, err := service.Restaurant.Count()
+	if err != nil {
+		log.Printf("Error counting restaurants: %v", err)
+		writeJSONError(w, "Failed to get stats", http.StatusInternalServerError)
+		return
+	}
+
+	mappings, err := service.Supercharger.CountRestaurantMappings()
+	if err != nil {
+		log.Printf("Error counting restaurant mappings: %v", err)
+		writeJSONError(w, "Failed to get stats", http.StatusInternalServerError)
+		return
+	}
+
+	hitRates, err := service.CacheHit.GetHitRates()
+	if err != nil {
+		log.Printf("Error getting cache hit rates: %v", err)
+		writeJSONError(w, "Failed to get stats", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"superchargers":           superchargers,
+		"confirmed_superchargers": confirmed,
+		"restaurants":             restaurants,
+		"restaurant_mappings":     mappings,
+		"cache_hit_rates":         hitRates,
+		"maps_calls":              maps.Stats(),
+	})
+}
+
+// Page sizes for admin log listings
+const (
+	defaultLogPageSize = 50
+	maxLogPageSize     = 500
+)
+
+// adminMapsLogsHandler lists maps call logs, filtered by sku, from, to and has_error and paged with limit and offset
+func adminMapsLogsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := db.MapsCallLogFilter{SKU: strings.TrimSpace(query.Get("sku"))}
+
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeJSONError(w, fmt.Sprintf("Invalid %s parameter, must be an RFC 3339 time", bound.name), http.StatusBadRequest)
+			return
+		}
+		*bound.target = t
+	}
+
+	if hasErrorStr := query.Get("has_error"); hasErrorStr != "" {
+		hasError, err := strconv.ParseBool(hasErrorStr)
+		if err != nil {
+			writeJSONError(w, "Invalid has_error parameter", http.StatusBadRequest)
+			return
+		}
+		filter.HasError = &hasError
+	}
+
+	limit := defaultLogPageSize
+	if limitStr := query.Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxLogPageSize {
+			writeJSONError(w, fmt.Sprintf("Invalid limit parameter, must be between 1 and %d", maxLogPageSize), http.StatusBadRequest)
+			return
+		}
+	}
+
+	offset := 0
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		var err error
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			writeJSONError(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	logs, total, err := db.GetDefaultService().MapsCallLog.Find(filter, limit, offset)
+	if err != nil {
+		log.Printf("Error listing maps call logs: %v", err)
+		writeJSONError(w, "Failed to list logs", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"logs":     logs,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+		"has_more": int64(offset+len(logs)) < total,
 	})
 }
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+	"github.com/brensch/passengerprincess/pkg/maps"
+)
+
+// openAPISpec is the encoded /openapi.json document, built once since it only depends on the response types
+var openAPISpec = mustBuildOpenAPISpec()
+
+// openAPIHandler serves the OpenAPI 3 description of the public endpoints, for generating client types
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(openAPISpec)
+}
+
+func mustBuildOpenAPISpec() []byte {
+	spec, err := json.Marshal(buildOpenAPISpec())
+	if err != nil {
+		log.Fatalf("Failed to encode OpenAPI spec: %v", err)
+	}
+	return spec
+}
+
+// buildOpenAPISpec describes the public endpoints. Parameters are listed by hand, while the response schemas
+// are generated from the types the handlers encode so they can't drift from what is sent.
+func buildOpenAPISpec() map[string]interface{} {
+	schemas := newSchemaRegistry()
+	errorContent := jsonContent(schemas.ref(reflect.TypeOf(errorResponse{})))
+	errorResponses := func(statuses map[string]string) map[string]interface{} {
+		responses := map[string]interface{}{}
+		for status, description := range statuses {
+			responses[status] = map[string]interface{}{"description": description, "content": errorContent}
+		}
+		return responses
+	}
+	withResponses := func(responses map[string]interface{}, more map[string]interface{}) map[string]interface{} {
+		for status, response := range more {
+			responses[status] = response
+		}
+		return responses
+	}
+
+	routeResponses := withResponses(errorResponses(map[string]string{
+		"400": "Invalid parameters",
+		"422": "No route between origin and destination",
+		"500": "Route planning failed",
+		"503": "Too many routes are being planned, retry after the Retry-After header",
+	}), map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "The route and the superchargers along it. The shape depends on the flat and empty parameters.",
+			"content": jsonContent(map[string]interface{}{
+				"oneOf": []interface{}{
+					schemas.ref(reflect.TypeOf(maps.SuperchargersOnRouteResult{})),
+					schemas.ref(reflect.TypeOf(maps.FlatSuperchargersOnRouteResult{})),
+					schemas.ref(reflect.TypeOf(routeReport{})),
+					schemas.ref(reflect.TypeOf(flatRouteReport{})),
+				},
+			}),
+		},
+		"204": map[string]interface{}{"description": "No superchargers were found and empty=no_content"},
+	})
+
+	viewportResponses := withResponses(errorResponses(map[string]string{
+		"400": "Missing or invalid bounds",
+		"500": "Failed to read superchargers",
+	}), map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "Cached superchargers within the bounds. With Accept: application/x-ndjson they are streamed one per line instead.",
+			"content": map[string]interface{}{
+				"application/json":     map[string]interface{}{"schema": schemas.ref(reflect.TypeOf(viewportResponse{}))},
+				"application/x-ndjson": map[string]interface{}{"schema": schemas.ref(reflect.TypeOf(db.Supercharger{}))},
+			},
+		},
+		"204": map[string]interface{}{"description": "No superchargers were found and empty=no_content"},
+	})
+
+	emptyParam := queryParam("empty", "How to respond when no superchargers are found", false,
+		enumSchema(string(emptyAsArray), string(emptyAsReport), string(emptyAsNoContent)))
+
+	routeParams := []interface{}{
+		queryParam("origin", "Start address or place", true, stringSchema()),
+		queryParam("destination", "End address or place", true, stringSchema()),
+		queryParam("sort", "Supercharger order", false, enumSchema(
+			string(maps.SortByDistanceAlongRoute), string(maps.SortByArrivalTime), string(maps.SortByFoodRating))),
+		queryParam("eta_format", "How arrival times are written", false, enumSchema(
+			string(maps.ETAFormatKitchen), string(maps.ETAFormatRFC3339), string(maps.ETAFormatEpochMS))),
+		queryParam("traffic", "Routing preference, cheaper without live traffic", false, enumSchema("optimal", "aware", "unaware")),
+		queryParam("traffic_fallback", "Fall back to cheaper preferences where traffic data is unavailable", false, typeSchema("boolean")),
+		queryParam("mode", "Travel mode", false, enumSchema("drive", "two_wheeler")),
+		queryParam("avoid", "Comma separated roads to avoid: tolls, highways, ferries", false, stringSchema()),
+		queryParam("steps", "Include turn-by-turn steps", false, typeSchema("boolean")),
+		queryParam("polyline", "Route path encoding", false, enumSchema("encoded", "geojson")),
+		queryParam("range_km", "Vehicle range, used for scoring chargers", false, typeSchema("number")),
+		queryParam("restaurants", "Fetch restaurants near each charger", false, typeSchema("boolean")),
+		queryParam("reviews", "Fetch charger ratings", false, typeSchema("boolean")),
+		queryParam("language", "Language code for place searches", false, stringSchema()),
+		queryParam("region", "Region code for place searches", false, stringSchema()),
+		queryParam("current_lat", "Driver latitude, so ETAs count from there", false, typeSchema("number")),
+		queryParam("current_lng", "Driver longitude, so ETAs count from there", false, typeSchema("number")),
+		queryParam("restaurant_radius_m", "Restaurant search radius in meters, up to 50000", false, typeSchema("number")),
+		queryParam("max_circles", "Cap on search circles, widening their radius", false, typeSchema("integer")),
+		queryParam("circles_per_page", "Search circles per page of results", false, typeSchema("integer")),
+		queryParam("continuation", "The next_continuation of the previous page", false, stringSchema()),
+		queryParam("walking_top_n", "Restaurants per charger to get walking distances for", false, typeSchema("integer")),
+		queryParam("exclude", "Comma separated place IDs of chargers to leave out", false, stringSchema()),
+		queryParam("prefer_cached", "Use cached superchargers where they cover the route, only searching the gaps", false, typeSchema("boolean")),
+	}
+
+	paths := map[string]interface{}{
+		"/autocomplete": map[string]interface{}{
+			"get": operation("autocomplete", "Suggest places matching partly typed text",
+				[]interface{}{
+					queryParam("partial", "The text typed so far", true, stringSchema()),
+					queryParam("session_token", "The token from the previous response, generated when absent", false, stringSchema()),
+				},
+				withResponses(errorResponses(map[string]string{
+					"400": "Missing partial parameter",
+					"500": "Autocomplete failed",
+				}), map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "Place predictions",
+						"content":     jsonContent(schemas.ref(reflect.TypeOf(autocompleteResponse{}))),
+					},
+				})),
+		},
+		"/route": map[string]interface{}{
+			"get": operation("planRoute", "Plan a route and find the superchargers and restaurants along it",
+				append(routeParams[:len(routeParams):len(routeParams)],
+					queryParam("simplified", "Add the path simplified for lower zoom levels, keyed by tolerance in meters", false, typeSchema("boolean")),
+					queryParam("flat", "Deduplicate restaurants into a top level map", false, typeSchema("boolean")),
+					emptyParam,
+				),
+				routeResponses),
+		},
+		"/route.geojson": map[string]interface{}{
+			"get": operation("planRouteGeoJSON", "Plan a route and return it with its superchargers and restaurants as GeoJSON",
+				routeParams,
+				withResponses(errorResponses(map[string]string{
+					"400": "Invalid parameters",
+					"422": "No route between origin and destination",
+					"500": "Route planning failed",
+					"503": "Too many routes are being planned, retry after the Retry-After header",
+				}), map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "A FeatureCollection of the route LineString and supercharger and restaurant Points, told apart by their kind property",
+						"content": map[string]interface{}{
+							"application/geo+json": map[string]interface{}{"schema": typeSchema("object")},
+						},
+					},
+				})),
+		},
+		"/route.gpx": map[string]interface{}{
+			"get": operation("planRouteGPX", "Plan a route and return it as a GPX track with its superchargers as waypoints",
+				routeParams,
+				withResponses(errorResponses(map[string]string{
+					"400": "Invalid parameters",
+					"422": "No route between origin and destination",
+					"500": "Route planning failed",
+					"503": "Too many routes are being planned, retry after the Retry-After header",
+				}), map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "A GPX 1.1 document with the route as a track and a waypoint per supercharger listing its restaurants",
+						"content": map[string]interface{}{
+							"application/gpx+xml": map[string]interface{}{"schema": stringSchema()},
+						},
+					},
+				})),
+		},
+		"/superchargers/viewport": map[string]interface{}{
+			"get": operation("superchargersInViewport", "List cached superchargers within map bounds",
+				[]interface{}{
+					queryParam("min_lat", "Southern bound", true, typeSchema("number")),
+					queryParam("max_lat", "Northern bound", true, typeSchema("number")),
+					queryParam("min_lng", "Western bound, greater than max_lng when the viewport crosses the antimeridian", true, typeSchema("number")),
+					queryParam("max_lng", "Eastern bound", true, typeSchema("number")),
+					emptyParam,
+				},
+				viewportResponses),
+		},
+		"/superchargers/nearest": map[string]interface{}{
+			"get": operation("nearestSuperchargers", "List the cached superchargers closest to a point",
+				[]interface{}{
+					queryParam("lat", "Latitude, from -90 to 90", true, typeSchema("number")),
+					queryParam("lng", "Longitude, from -180 to 180", true, typeSchema("number")),
+					queryParam("limit", "How many superchargers to return, from 1 to 100", false, typeSchema("integer")),
+					queryParam("range_km", "Remaining range in km, leaving out superchargers beyond it in a straight line and flagging those that may be out of reach by road", false, typeSchema("number")),
+				},
+				withResponses(errorResponses(map[string]string{
+					"400": "Missing or invalid coordinates, limit or range",
+					"500": "Failed to read superchargers",
+				}), map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "Superchargers nearest first, with their distance in meters",
+						"content":     jsonContent(schemas.ref(reflect.TypeOf(nearestResponse{}))),
+					},
+				})),
+		},
+		"/places/{id}/location": map[string]interface{}{
+			"get": operation("getPlaceLocation", "Get just the coordinates of a place, such as an autocomplete result",
+				[]interface{}{
+					map[string]interface{}{"name": "id", "in": "path", "required": true, "schema": stringSchema()},
+				},
+				withResponses(errorResponses(map[string]string{
+					"400": "Invalid place ID",
+					"404": "Place not found or has no location",
+					"429": "Too many requests from this client",
+					"500": "Failed to get place location",
+				}), map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "The place's coordinates",
+						"content":     jsonContent(schemas.ref(reflect.TypeOf(placeLocationResponse{}))),
+					},
+				})),
+		},
+		"/superchargers/{placeId}": map[string]interface{}{
+			"get": operation("getSupercharger", "Get a supercharger and its restaurants",
+				[]interface{}{
+					map[string]interface{}{"name": "placeId", "in": "path", "required": true, "schema": stringSchema()},
+					queryParam("top", "How many top restaurants to return", false, typeSchema("integer")),
+					queryParam("min_rating", "Lowest rating of the top restaurants, from 0 to 5", false, typeSchema("number")),
+				},
+				withResponses(errorResponses(map[string]string{
+					"400": "Invalid place ID or parameters",
+					"500": "Failed to get supercharger",
+				}), map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "The supercharger with its restaurants",
+						"content":     jsonContent(schemas.ref(reflect.TypeOf(superchargerResponse{}))),
+					},
+				})),
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "PassengerPrincess API",
+			"version": "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas.schemas},
+	}
+}
+
+func operation(id, summary string, parameters []interface{}, responses map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"operationId": id,
+		"summary":     summary,
+		"parameters":  parameters,
+		"responses":   responses,
+	}
+}
+
+func queryParam(name, description string, required bool, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"required":    required,
+		"schema":      schema,
+	}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+func typeSchema(name string) map[string]interface{} {
+	return map[string]interface{}{"type": name}
+}
+
+func stringSchema() map[string]interface{} {
+	return typeSchema("string")
+}
+
+func enumSchema(values ...string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "enum": values}
+}
+
+// schemaRegistry generates JSON schemas from Go types the way encoding/json would encode them, collecting
+// each named struct once as a component that others reference
+type schemaRegistry struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: map[string]interface{}{}, names: map[reflect.Type]string{}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ref returns the schema of t, registering named structs as components and referring to them
+func (s *schemaRegistry) ref(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name, ok := s.names[t]
+		if !ok {
+			name = s.componentName(t)
+			s.names[t] = name
+			// placeholder first so recursive types refer back rather than loop
+			s.schemas[name] = nil
+			s.schemas[name] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return s.object(t)
+	case reflect.Bool:
+		return typeSchema("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return typeSchema("integer")
+	case reflect.Float32, reflect.Float64:
+		return typeSchema("number")
+	case reflect.String:
+		return stringSchema()
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.ref(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.ref(t.Elem())}
+	default:
+		// interfaces can hold anything
+		return map[string]interface{}{}
+	}
+}
+
+// componentName names a struct's component after its type, exported for the benefit of generated
+// clients and qualified by package if another package's type already has the name
+func (s *schemaRegistry) componentName(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := s.schemas[name]; taken {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	return name
+}
+
+// object builds the schema of a struct. Fields of embedded structs are promoted as encoding/json does,
+// with the struct's own fields taking precedence. Fields without omitempty are always present so are
+// required, unless they come from an embedded pointer that may be nil.
+func (s *schemaRegistry) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := map[string]bool{}
+	s.addFields(t, properties, required, true)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		names := make([]string, 0, len(required))
+		for name := range required {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		schema["required"] = names
+	}
+	return schema
+}
+
+func (s *schemaRegistry) addFields(t reflect.Type, properties map[string]interface{}, required map[string]bool, present bool) {
+	var direct []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			// promoted fields are shallower than anything they're embedded in, so add them first to be overridden
+			s.addFields(fieldType, properties, required, present && field.Type.Kind() != reflect.Pointer)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		direct = append(direct, field)
+	}
+
+	for _, field := range direct {
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.ref(field.Type)
+		delete(required, name)
+		if present && !hasOption(options, "omitempty") {
+			required[name] = true
+		}
+	}
+}
+
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
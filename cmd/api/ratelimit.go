@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// clientRateLimiter allows each client up to limit requests per window, counted in fixed windows.
+// It is meant for unauthenticated endpoints that can trigger billed Google calls.
+type clientRateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[string]int
+}
+
+// newClientRateLimiter creates a limiter, or returns nil if limit is zero or less so the endpoint is unlimited
+func newClientRateLimiter(limit int, window time.Duration) *clientRateLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &clientRateLimiter{limit: limit, window: window, counts: make(map[string]int)}
+}
+
+// allow counts a request from client, reporting whether it is within the limit and, if not, how long
+// until the client may try again
+func (l *clientRateLimiter) allow(client string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.windowStart) >= l.window {
+		// starting over each window also forgets clients that have gone away
+		l.windowStart = now
+		l.counts = make(map[string]int)
+	}
+	if l.counts[client] >= l.limit {
+		return false, l.windowStart.Add(l.window).Sub(now)
+	}
+	l.counts[client]++
+	return true, 0
+}
+
+// withRateLimit rejects requests beyond the limiter's limit for their client with a 429
+func withRateLimit(limiter *clientRateLimiter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := limiter.allow(clientIP(r)); !ok {
+			seconds := int(retryAfter.Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			writeJSONError(w, "Too many requests, try again later", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
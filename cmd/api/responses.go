@@ -0,0 +1,74 @@
+package main
+
+import (
+	"github.com/brensch/passengerprincess/pkg/db"
+	"github.com/brensch/passengerprincess/pkg/maps"
+)
+
+// Response bodies of the public endpoints. Handlers encode these rather than ad hoc maps so that
+// /openapi.json, which is generated from them, always describes what is actually sent.
+
+// errorResponse is the body of every JSON error
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// autocompleteResponse is the body of GET /autocomplete
+type autocompleteResponse struct {
+	Predictions []maps.AutocompletePrediction `json:"predictions"`
+	// SessionToken groups autocomplete requests into one billing session, to be passed back on the next request
+	SessionToken string `json:"session_token"`
+}
+
+// feasibility is added to responses in report mode so that finding no superchargers can't be
+// mistaken by the client for a failed or unprocessed request
+type feasibility struct {
+	Feasible bool   `json:"feasible"`
+	Message  string `json:"message,omitempty"`
+}
+
+// newFeasibility reports whether any superchargers were found, explaining with message when none were
+func newFeasibility(found int, message string) feasibility {
+	if found > 0 {
+		return feasibility{Feasible: true}
+	}
+	return feasibility{Message: message}
+}
+
+// routeReport is the body of GET /route with empty=report
+type routeReport struct {
+	*maps.SuperchargersOnRouteResult
+	feasibility
+}
+
+// flatRouteReport is the body of GET /route with flat=true and empty=report
+type flatRouteReport struct {
+	*maps.FlatSuperchargersOnRouteResult
+	feasibility
+}
+
+// superchargerResponse is the body of GET /superchargers/{placeId}
+type superchargerResponse struct {
+	Supercharger   *db.Supercharger            `json:"supercharger"`
+	Restaurants    []db.RestaurantWithDistance `json:"restaurants"`
+	TopRestaurants []db.RestaurantWithDistance `json:"top_restaurants"`
+}
+
+// viewportResponse is the body of GET /superchargers/viewport. feasibility is only set with empty=report.
+type viewportResponse struct {
+	Superchargers []db.Supercharger `json:"superchargers"`
+	*feasibility
+}
+
+// nearestResponse is the body of GET /superchargers/nearest
+type nearestResponse struct {
+	// Superchargers are nearest first, with their distance in meters. With range_km those out of range are
+	// left out and those that may be out of range by road are flagged borderline.
+	Superchargers []maps.ReachableSupercharger `json:"superchargers"`
+}
+
+// placeLocationResponse is the body of GET /places/{id}/location
+type placeLocationResponse struct {
+	PlaceID  string      `json:"place_id"`
+	Location maps.Center `json:"location"`
+}
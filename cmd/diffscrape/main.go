@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+	"github.com/brensch/passengerprincess/pkg/maps"
+	"gorm.io/gorm/logger"
+)
+
+// diffscrape compares the superchargers in two copies of the database, such as snapshots taken before and
+// after re-scraping a region, and prints the chargers added, removed and moved between them as JSON.
+func main() {
+	oldPath := flag.String("old", "", "path to the earlier SQLite database")
+	newPath := flag.String("new", "", "path to the later SQLite database")
+	threshold := flag.Float64("moved-threshold", maps.DefaultMovedThresholdMeters, "meters a supercharger must shift to be reported as moved")
+	flag.Parse()
+
+	if *oldPath == "" || *newPath == "" {
+		log.Fatal("both -old and -new must be set")
+	}
+
+	before, err := loadSuperchargers(*oldPath)
+	if err != nil {
+		log.Fatalf("Failed to load %s: %v", *oldPath, err)
+	}
+	after, err := loadSuperchargers(*newPath)
+	if err != nil {
+		log.Fatalf("Failed to load %s: %v", *newPath, err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(maps.DiffScrapes(before, after, *threshold)); err != nil {
+		log.Fatalf("Failed to write diff: %v", err)
+	}
+}
+
+// loadSuperchargers reads every confirmed supercharger from the database at path
+func loadSuperchargers(path string) ([]db.Supercharger, error) {
+	// Initialize would create an empty database rather than fail
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	if err := db.Initialize(&db.Config{
+		DatabasePath: path,
+		LogLevel:     logger.Warn,
+	}); err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var superchargers []db.Supercharger
+	err := db.GetDefaultService().Supercharger.ForEachConfirmed(time.Time{}, 1000, func(batch []db.Supercharger) error {
+		superchargers = append(superchargers, batch...)
+		return nil
+	})
+	return superchargers, err
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+	"github.com/brensch/passengerprincess/pkg/maps"
+	"gorm.io/gorm/logger"
+)
+
+// maintain vacuums and analyzes the SQLite database to reclaim space and keep query plans fresh.
+// Run it after bulk imports or pruning, ideally while the API is idle.
+func main() {
+	dbPath := flag.String("db", "db/passengerprincess.db", "path to the SQLite database")
+	fillAddresses := flag.Int("fill-addresses", 0, "reverse geocode up to this many superchargers with no address, using MAPS_API_KEY")
+	routeRetention := flag.Duration("route-retention", 30*24*time.Hour, "delete stored routes older than this")
+	routeLogRetention := flag.Duration("route-log-retention", 90*24*time.Hour, "delete /route request logs older than this")
+	mapsLogRetention := flag.Duration("maps-log-retention", 90*24*time.Hour, "delete Google API call logs older than this")
+	placeLocationRetention := flag.Duration("place-location-retention", 90*24*time.Hour, "delete place locations looked up for /places/{id}/location longer ago than this")
+	moveRejected := flag.Bool("move-rejected", false, "move places cached as non-supercharger rows into the rejected place table")
+	maxRestaurants := flag.Int("max-restaurants-per-supercharger", 0, "trim superchargers with more restaurants than this down to the best ones, 0 to skip")
+	geocodeConcurrency := flag.Int("geocode-concurrency", 4, "number of reverse geocoding calls to make at once")
+	flag.Parse()
+
+	apiKey := os.Getenv("MAPS_API_KEY")
+	if *fillAddresses > 0 && apiKey == "" {
+		log.Fatal("MAPS_API_KEY must be set to fill missing addresses")
+	}
+
+	before, err := os.Stat(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to stat database: %v", err)
+	}
+
+	if err := db.Initialize(&db.Config{
+		DatabasePath: *dbPath,
+		LogLevel:     logger.Warn,
+	}); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	service := db.GetDefaultService()
+
+	// Drop expired trips and old routes first so vacuum reclaims their space
+	deleted, err := service.SavedTrip.DeleteExpired(time.Now())
+	if err != nil {
+		log.Fatalf("Failed to prune saved trips: %v", err)
+	}
+	log.Printf("Pruned %d expired saved trips", deleted)
+
+	deleted, err = service.RouteAnalysis.DeleteOlderThan(time.Now().Add(-*routeRetention))
+	if err != nil {
+		log.Fatalf("Failed to prune stored routes: %v", err)
+	}
+	log.Printf("Pruned %d stored routes", deleted)
+
+	if err := service.RouteCallLog.DeleteOlderThan(time.Now().Add(-*routeLogRetention)); err != nil {
+		log.Fatalf("Failed to prune route call logs: %v", err)
+	}
+
+	if err := service.MapsCallLog.DeleteOlderThan(time.Now().Add(-*mapsLogRetention)); err != nil {
+		log.Fatalf("Failed to prune maps call logs: %v", err)
+	}
+
+	deleted, err = service.PlaceLocation.DeleteOlderThan(time.Now().Add(-*placeLocationRetention))
+	if err != nil {
+		log.Fatalf("Failed to prune place locations: %v", err)
+	}
+	log.Printf("Pruned %d place locations", deleted)
+
+	if *moveRejected {
+		moved, err := service.MoveRejectedPlaces()
+		if err != nil {
+			log.Fatalf("Failed to move rejected places: %v", err)
+		}
+		log.Printf("Moved %d rejected places out of the superchargers table", moved)
+	}
+
+	if *maxRestaurants > 0 {
+		trimmed, err := service.Supercharger.TrimRestaurantMappings(*maxRestaurants)
+		if err != nil {
+			log.Fatalf("Failed to trim restaurant mappings: %v", err)
+		}
+		log.Printf("Trimmed %d restaurant mappings from over-associated superchargers", trimmed)
+	}
+
+	if *fillAddresses > 0 {
+		filled, err := maps.BatchFillMissingAddresses(context.Background(), service, apiKey, *geocodeConcurrency, *fillAddresses)
+		if err != nil {
+			log.Fatalf("Failed to fill missing addresses: %v", err)
+		}
+		log.Printf("Filled %d missing supercharger addresses", filled)
+	}
+
+	if err := service.Maintain(); err != nil {
+		log.Fatalf("Maintenance failed: %v", err)
+	}
+
+	after, err := os.Stat(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to stat database: %v", err)
+	}
+	log.Printf("Maintenance complete: %d bytes -> %d bytes", before.Size(), after.Size())
+}
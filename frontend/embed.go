@@ -0,0 +1,9 @@
+// Package frontend bundles the web interface into the binary so the server doesn't depend on its working directory.
+package frontend
+
+import _ "embed"
+
+// IndexHTML is the web interface template. The API key is filled in with text/template.
+//
+//go:embed index.html
+var IndexHTML string
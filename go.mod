@@ -3,6 +3,7 @@ module github.com/brensch/passengerprincess
 go 1.24.0
 
 require (
+	golang.org/x/sync v0.17.0
 	gorm.io/driver/sqlite v1.6.0
 	gorm.io/gorm v1.30.5
 )
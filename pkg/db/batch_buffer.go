@@ -0,0 +1,109 @@
+package db
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// batchBuffer collects items in memory and writes them in batches with flush, so recording one doesn't
+// add a synchronous write to the caller. Items are flushed every interval, or sooner once size are
+// pending. If key is set, an item replaces any pending item with the same key.
+type batchBuffer[T any] struct {
+	size  int
+	key   func(T) string
+	flush func([]T) error
+	// what is named in the warning logged when a background flush fails
+	what string
+
+	mu      sync.Mutex
+	pending []T
+	// index is each pending item's position by key, when key is set
+	index map[string]int
+
+	full chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newBatchBuffer creates a buffer and starts its background flusher. Call Close to stop it.
+func newBatchBuffer[T any](size int, interval time.Duration, what string, key func(T) string, flush func([]T) error) *batchBuffer[T] {
+	b := &batchBuffer[T]{
+		size:  size,
+		key:   key,
+		flush: flush,
+		what:  what,
+		index: make(map[string]int),
+		full:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+
+	b.wg.Add(1)
+	go b.run(interval)
+
+	return b
+}
+
+// add queues an item. It never touches the database.
+func (b *batchBuffer[T]) add(item T) {
+	b.mu.Lock()
+	if b.key == nil {
+		b.pending = append(b.pending, item)
+	} else if i, ok := b.index[b.key(item)]; ok {
+		b.pending[i] = item
+	} else {
+		b.index[b.key(item)] = len(b.pending)
+		b.pending = append(b.pending, item)
+	}
+	full := len(b.pending) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		// wake the flusher without waiting if it's already been woken
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush writes all pending items now
+func (b *batchBuffer[T]) Flush() error {
+	b.mu.Lock()
+	items := b.pending
+	b.pending = nil
+	b.index = make(map[string]int)
+	b.mu.Unlock()
+
+	if len(items) == 0 {
+		return nil
+	}
+	return b.flush(items)
+}
+
+// Close stops the background flusher and writes any remaining items
+func (b *batchBuffer[T]) Close() error {
+	close(b.done)
+	b.wg.Wait()
+	return b.Flush()
+}
+
+// run flushes on every tick or when the buffer fills, until Close is called
+func (b *batchBuffer[T]) run(interval time.Duration) {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.full:
+		case <-b.done:
+			return
+		}
+		if err := b.Flush(); err != nil {
+			log.Printf("Warning: failed to flush %s: %v", b.what, err)
+		}
+	}
+}
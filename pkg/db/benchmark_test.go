@@ -0,0 +1,60 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm/logger"
+)
+
+const benchmarkRows = 1000
+
+// newBenchmarkService creates a fresh file-backed database for a single benchmark run
+func newBenchmarkService(b *testing.B) *Service {
+	err := Initialize(&Config{
+		DatabasePath: filepath.Join(b.TempDir(), "bench.db"),
+		LogLevel:     logger.Silent,
+	})
+	if err != nil {
+		b.Fatalf("Failed to initialize database: %v", err)
+	}
+	b.Cleanup(func() { Close() })
+	return GetDefaultService()
+}
+
+func benchmarkSuperchargers(run, n int) []Supercharger {
+	superchargers := make([]Supercharger, n)
+	for i := range superchargers {
+		superchargers[i] = Supercharger{
+			PlaceID:        fmt.Sprintf("bench_%d_%d", run, i),
+			Name:           "Bench Supercharger",
+			Latitude:       float64(i % 90),
+			Longitude:      float64(i % 180),
+			IsSupercharger: true,
+		}
+	}
+	return superchargers
+}
+
+func BenchmarkSuperchargerCreateBatch(b *testing.B) {
+	service := newBenchmarkService(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := service.Supercharger.CreateBatch(benchmarkSuperchargers(i, benchmarkRows)); err != nil {
+			b.Fatalf("CreateBatch failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkSuperchargerCreateUnbatched(b *testing.B) {
+	service := newBenchmarkService(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, sc := range benchmarkSuperchargers(i, benchmarkRows) {
+			if err := service.Supercharger.Create(&sc); err != nil {
+				b.Fatalf("Create failed: %v", err)
+			}
+		}
+	}
+}
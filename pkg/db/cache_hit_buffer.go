@@ -0,0 +1,24 @@
+package db
+
+import "time"
+
+// CacheHitBuffer collects cache hits in memory and writes them with CacheHitRepository.UpsertBatch,
+// so recording a hit doesn't add a synchronous write to every cached read. Hits are flushed every
+// interval, or sooner once size distinct objects are pending. Only the latest hit per object is kept.
+type CacheHitBuffer struct {
+	*batchBuffer[CacheHit]
+}
+
+// NewCacheHitBuffer creates a buffer and starts its background flusher. Call Close to stop it.
+func NewCacheHitBuffer(repo *CacheHitRepository, size int, interval time.Duration) *CacheHitBuffer {
+	key := func(hit CacheHit) string { return hit.ObjectID }
+	return &CacheHitBuffer{newBatchBuffer(size, interval, "cache hits", key, repo.UpsertBatch)}
+}
+
+// Record queues a cache hit. It never touches the database.
+func (b *CacheHitBuffer) Record(hit CacheHit) {
+	if hit.LastUpdated.IsZero() {
+		hit.LastUpdated = time.Now()
+	}
+	b.add(hit)
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Cursor marks where a keyset page ended, by the place ID of its last row. Pages are read with
+// GetAllAfter, which seeks straight to the cursor rather than scanning past an offset, so deep pages of
+// large tables cost the same as the first. The zero Cursor is the start of the table.
+type Cursor struct {
+	PlaceID string
+}
+
+// IsZero reports whether the cursor is at the start of the table, which as a returned cursor means there
+// are no more pages
+func (c Cursor) IsZero() bool {
+	return c.PlaceID == ""
+}
+
+// String encodes the cursor as an opaque token for clients to hand back, empty for the zero Cursor
+func (c Cursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(c.PlaceID))
+}
+
+// ParseCursor reads a token from Cursor.String. An empty token is the zero Cursor.
+func ParseCursor(token string) (Cursor, error) {
+	placeID, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", token)
+	}
+	return Cursor{PlaceID: string(placeID)}, nil
+}
+
+// afterCursor scopes a query on a place table to the page of up to limit rows after the cursor
+func afterCursor(db *gorm.DB, cursor Cursor, limit int) *gorm.DB {
+	query := db.Order("place_id ASC")
+	if !cursor.IsZero() {
+		query = query.Where("place_id > ?", cursor.PlaceID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	return query
+}
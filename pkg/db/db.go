@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"gorm.io/driver/sqlite"
@@ -14,6 +15,13 @@ import (
 // DB is the global database instance
 var DB *gorm.DB
 
+var (
+	// initMu guards DB and initializedConfig
+	initMu sync.Mutex
+	// initializedConfig is the config DB was opened with
+	initializedConfig Config
+)
+
 // Config holds database configuration
 type Config struct {
 	DatabasePath string
@@ -28,14 +36,69 @@ func DefaultConfig() *Config {
 	}
 }
 
-// Initialize sets up the database connection and runs migrations
+// Initialize sets up the global database connection and runs migrations. Calling it again with the
+// same config does nothing, and calling it with a different one is an error until Close is called.
 func Initialize(config *Config) error {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
-	var err error
+	initMu.Lock()
+	defer initMu.Unlock()
+
+	if DB != nil {
+		if *config == initializedConfig {
+			return nil
+		}
+		return fmt.Errorf("database already initialized with %s, close it before opening %s", initializedConfig.DatabasePath, config.DatabasePath)
+	}
+
+	db, err := open(config)
+	if err != nil {
+		return err
+	}
+	DB = db
+	initializedConfig = *config
+
+	return nil
+}
+
+// InitializeNew opens and migrates a database without touching the global DB, for callers that want
+// their own connection. Close the returned service when done with it.
+func InitializeNew(config *Config) (*Service, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	db, err := open(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewService(db), nil
+}
+
+// NewInMemoryService opens a fresh, migrated in-memory database without touching the global DB, so tests
+// using it are isolated from each other and can run in parallel. The data is gone once the service is closed.
+func NewInMemoryService() (*Service, error) {
+	db, err := open(&Config{DatabasePath: ":memory:", LogLevel: logger.Silent})
+	if err != nil {
+		return nil, err
+	}
+
+	// every connection to :memory: is a separate empty database, so keep the one that was migrated
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetConnMaxLifetime(0)
+
+	return NewService(db), nil
+}
 
+// open connects to the database and runs migrations
+func open(config *Config) (*gorm.DB, error) {
 	// Configure GORM logger
 	gormConfig := &gorm.Config{
 		Logger: logger.New(
@@ -47,29 +110,54 @@ func Initialize(config *Config) error {
 	}
 
 	// Open database connection
-	DB, err = gorm.Open(sqlite.Open(config.DatabasePath), gormConfig)
+	db, err := gorm.Open(sqlite.Open(config.DatabasePath), gormConfig)
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if err := migrate(db); err != nil {
+		if sqlDB, dbErr := db.DB(); dbErr == nil {
+			sqlDB.Close()
+		}
+		return nil, err
 	}
 
+	log.Println("Database initialized and migrated successfully")
+
+	return db, nil
+}
+
+// migrate configures SQLite and brings the schema up to date
+func migrate(db *gorm.DB) error {
 	// Configure SQLite settings
-	if err := configureSQLite(config); err != nil {
+	if err := configureSQLite(db); err != nil {
 		return fmt.Errorf("failed to configure SQLite: %w", err)
 	}
 
+	// superchargers cached before restaurant tracking existed already have their restaurants
+	hadRestaurantUpdate := !db.Migrator().HasTable(&Supercharger{}) || db.Migrator().HasColumn(&Supercharger{}, "LastRestaurantUpdate")
+
 	// Auto-migrate the schema
-	if err := autoMigrate(); err != nil {
+	if err := autoMigrate(db); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	log.Println("Database initialized and migrated successfully")
+	if err := backfillTypes(db); err != nil {
+		return fmt.Errorf("failed to backfill place types: %w", err)
+	}
+
+	if !hadRestaurantUpdate {
+		if err := db.Exec("UPDATE superchargers SET last_restaurant_update = last_updated WHERE is_supercharger = true").Error; err != nil {
+			return fmt.Errorf("failed to backfill restaurant update times: %w", err)
+		}
+	}
 
 	return nil
 }
 
 // configureSQLite applies SQLite-specific settings
-func configureSQLite(config *Config) error {
-	sqlDB, err := DB.DB()
+func configureSQLite(db *gorm.DB) error {
+	sqlDB, err := db.DB()
 	if err != nil {
 		return err
 	}
@@ -98,19 +186,37 @@ func configureSQLite(config *Config) error {
 }
 
 // autoMigrate runs automatic migrations for all models
-func autoMigrate() error {
-	return DB.AutoMigrate(
+func autoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(
 		&Restaurant{},
 		&Supercharger{},
 		&RestaurantSuperchargerMapping{},
 		&MapsCallLog{},
 		&CacheHit{},
 		&RouteCallLog{},
+		&SavedTrip{},
+		&RouteAnalysis{},
+		&RejectedPlace{},
+		&FetchStats{},
+		&PlaceLocation{},
 	)
 }
 
-// Close closes the database connection
+// backfillTypes gives rows created before types were stored an empty array
+func backfillTypes(db *gorm.DB) error {
+	for _, table := range []string{"superchargers", "restaurants"} {
+		if err := db.Exec("UPDATE " + table + " SET types = '[]' WHERE types IS NULL").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the global database connection so Initialize can be called again
 func Close() error {
+	initMu.Lock()
+	defer initMu.Unlock()
+
 	if DB == nil {
 		return nil
 	}
@@ -120,6 +226,8 @@ func Close() error {
 		return err
 	}
 
+	DB = nil
+	initializedConfig = Config{}
 	return sqlDB.Close()
 }
 
@@ -1,48 +1,110 @@
 package db
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"math"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-func TestInitialize(t *testing.T) {
-	// Create database file in test-databases directory
-	timestamp := time.Now().Format("20060102_150405")
-	dbFile := filepath.Join("test-databases", fmt.Sprintf("TestInitialize_%s.db", timestamp))
+// newTestDB opens an isolated in-memory database, closed when the test ends
+func newTestDB(t *testing.T) *Service {
+	t.Helper()
+	service, err := NewInMemoryService()
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { service.Close() })
+	return service
+}
 
-	// Ensure the directory exists
-	os.MkdirAll("test-databases", 0755)
+func TestInitializeTwice(t *testing.T) {
+	config := &Config{
+		DatabasePath: filepath.Join(t.TempDir(), "test.db"),
+		LogLevel:     logger.Error,
+	}
+	if err := Initialize(config); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { Close() })
+	global := DB
 
-	err := Initialize(&Config{
-		DatabasePath: dbFile,
+	if err := Initialize(config); err != nil || DB != global {
+		t.Errorf("Expected initializing with the same config to keep the database, got %v", err)
+	}
+	other := &Config{
+		DatabasePath: filepath.Join(t.TempDir(), "other.db"),
 		LogLevel:     logger.Error,
-	})
+	}
+	if err := Initialize(other); err == nil || DB != global {
+		t.Errorf("Expected initializing with a different config to fail, got %v", err)
+	}
+
+	// an isolated service leaves the global alone
+	service, err := InitializeNew(other)
 	if err != nil {
-		t.Fatalf("Failed to initialize database: %v", err)
+		t.Fatalf("InitializeNew failed: %v", err)
 	}
-	defer Close()
+	defer service.Close()
+	if DB != global {
+		t.Error("InitializeNew replaced the global database")
+	}
+	if err := service.Supercharger.Create(&Supercharger{PlaceID: "isolated", IsSupercharger: true}); err != nil {
+		t.Fatalf("Failed to create supercharger: %v", err)
+	}
+	if _, err := GetDefaultService().Supercharger.GetByID("isolated"); err == nil {
+		t.Error("Expected the isolated database not to share rows with the global one")
+	}
+
+	// once closed it can be opened somewhere else
+	if err := Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := Initialize(other); err != nil {
+		t.Fatalf("Expected initializing after Close to succeed, got %v", err)
+	}
+	if _, err := GetDefaultService().Supercharger.GetByID("isolated"); err != nil {
+		t.Errorf("Expected the reopened database to have the isolated row: %v", err)
+	}
+}
 
-	t.Logf("Database created at: %s", dbFile)
+func TestNewInMemoryService(t *testing.T) {
+	t.Parallel()
+	first, second := newTestDB(t), newTestDB(t)
+
+	if err := first.Supercharger.Create(&Supercharger{PlaceID: "memory_sc", IsSupercharger: true}); err != nil {
+		t.Fatalf("Failed to create supercharger: %v", err)
+	}
+	if _, err := first.Supercharger.GetByID("memory_sc"); err != nil {
+		t.Errorf("Expected the supercharger in the service it was written to: %v", err)
+	}
+	if _, err := second.Supercharger.GetByID("memory_sc"); err == nil {
+		t.Error("Expected in-memory services not to share data")
+	}
+	if first.db == DB || second.db == DB {
+		t.Error("Expected in-memory services not to use the global DB")
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	service := newTestDB(t)
 
 	// Check if tables exist
-	if !DB.Migrator().HasTable(&Supercharger{}) {
+	if !service.db.Migrator().HasTable(&Supercharger{}) {
 		t.Error("Supercharger table not created")
 	}
-	if !DB.Migrator().HasTable(&Restaurant{}) {
+	if !service.db.Migrator().HasTable(&Restaurant{}) {
 		t.Error("Restaurant table not created")
 	}
-	if !DB.Migrator().HasTable("restaurant_superchargers") {
+	if !service.db.Migrator().HasTable(&RestaurantSuperchargerMapping{}) {
 		t.Error("Join table not created")
 	}
 
-	// Test repositories
-	service := GetDefaultService()
-
 	// Test Supercharger
 	sc := &Supercharger{
 		PlaceID:   "test_sc",
@@ -51,7 +113,7 @@ func TestInitialize(t *testing.T) {
 		Latitude:  37.7749,
 		Longitude: -122.4194,
 	}
-	err = service.Supercharger.Create(sc)
+	err := service.Supercharger.Create(sc)
 	if err != nil {
 		t.Fatalf("Failed to create supercharger: %v", err)
 	}
@@ -91,48 +153,46 @@ func TestInitialize(t *testing.T) {
 	}
 
 	// Test association
-	err = service.Restaurant.AssociateWithSupercharger("test_rest", "test_sc")
+	err = service.Supercharger.SetRestaurantsForSupercharger("test_sc", []RestaurantWithDistance{
+		{Restaurant: *retrievedRest, Distance: 50},
+	}, "", 0)
 	if err != nil {
 		t.Fatalf("Failed to associate: %v", err)
 	}
 
-	restWithSCs, err := service.Restaurant.GetByIDWithSuperchargers("test_rest")
+	mapped, err := service.Supercharger.GetRestaurantsForSupercharger("test_sc")
 	if err != nil {
-		t.Fatalf("Failed to get restaurant with superchargers: %v", err)
+		t.Fatalf("Failed to get restaurants for supercharger: %v", err)
 	}
-	if len(restWithSCs.Superchargers) != 1 || restWithSCs.Superchargers[0].PlaceID != "test_sc" {
+	if len(mapped) != 1 || mapped[0].PlaceID != "test_rest" || mapped[0].Distance != 50 {
 		t.Error("Association not working correctly")
 	}
-}
-
-func TestSuperchargerRepository(t *testing.T) {
-	// Create database file in test-databases directory
-	timestamp := time.Now().Format("20060102_150405")
-	dbFile := filepath.Join("test-databases", fmt.Sprintf("TestSuperchargerRepository_%s.db", timestamp))
-
-	// Ensure the directory exists
-	os.MkdirAll("test-databases", 0755)
 
-	err := Initialize(&Config{
-		DatabasePath: dbFile,
-		LogLevel:     logger.Error,
-	})
+	// fetching the restaurant again with a rating updates the stored row
+	rated := *retrievedRest
+	rated.Rating, rated.UserRatingsTotal = 4.2, 87
+	err = service.Supercharger.SetRestaurantsForSupercharger("test_sc", []RestaurantWithDistance{
+		{Restaurant: rated, Distance: 50},
+	}, "", 0)
 	if err != nil {
-		t.Fatalf("Failed to initialize database: %v", err)
+		t.Fatalf("Failed to associate: %v", err)
 	}
-	defer Close()
-
-	t.Logf("Database created at: %s", dbFile)
+	retrievedRest, err = service.Restaurant.GetByID("test_rest")
+	if err != nil || retrievedRest.Rating != 4.2 || retrievedRest.UserRatingsTotal != 87 {
+		t.Errorf("Expected the refetched rating to be stored, got %+v: %v", retrievedRest, err)
+	}
+}
 
-	service := GetDefaultService()
+func TestSuperchargerRepository(t *testing.T) {
+	service := newTestDB(t)
 
 	// Create test data
 	scs := []Supercharger{
-		{PlaceID: "sc1", Name: "SC1", Address: "Addr1", Latitude: 1, Longitude: 1},
-		{PlaceID: "sc2", Name: "SC2", Address: "Addr2", Latitude: 2, Longitude: 2},
+		{PlaceID: "sc1", Name: "SC1", Address: "Addr1", Latitude: 1, Longitude: 1, IsSupercharger: true},
+		{PlaceID: "sc2", Name: "SC2", Address: "Addr2", Latitude: 2, Longitude: 2, IsSupercharger: true},
 	}
 
-	err = service.Supercharger.CreateBatch(scs)
+	err := service.Supercharger.CreateBatch(scs)
 	if err != nil {
 		t.Fatalf("Failed to create batch superchargers: %v", err)
 	}
@@ -146,10 +206,10 @@ func TestSuperchargerRepository(t *testing.T) {
 		t.Error("GetByID failed")
 	}
 
-	// Test GetAll
-	all, err := service.Supercharger.GetAll(10, 0)
-	if err != nil || len(all) != 2 {
-		t.Fatalf("Failed to get all superchargers: %v", err)
+	// Test Count
+	count, err := service.Supercharger.Count()
+	if err != nil || count != 2 {
+		t.Fatalf("Failed to count superchargers: %v", err)
 	}
 
 	// Test GetByLocation
@@ -160,25 +220,7 @@ func TestSuperchargerRepository(t *testing.T) {
 }
 
 func TestRestaurantRepository(t *testing.T) {
-	// Create database file in test-databases directory
-	timestamp := time.Now().Format("20060102_150405")
-	dbFile := filepath.Join("test-databases", fmt.Sprintf("TestRestaurantRepository_%s.db", timestamp))
-
-	// Ensure the directory exists
-	os.MkdirAll("test-databases", 0755)
-
-	err := Initialize(&Config{
-		DatabasePath: dbFile,
-		LogLevel:     logger.Error,
-	})
-	if err != nil {
-		t.Fatalf("Failed to initialize database: %v", err)
-	}
-	defer Close()
-
-	t.Logf("Database created at: %s", dbFile)
-
-	service := GetDefaultService()
+	service := newTestDB(t)
 
 	// Create test data
 	rests := []Restaurant{
@@ -209,7 +251,7 @@ func TestRestaurantRepository(t *testing.T) {
 	}
 
 	for _, r := range rests {
-		err = service.Restaurant.Create(&r)
+		err := service.Restaurant.Create(&r)
 		if err != nil {
 			t.Fatalf("Failed to create restaurant: %v", err)
 		}
@@ -224,10 +266,10 @@ func TestRestaurantRepository(t *testing.T) {
 		t.Error("GetByID failed")
 	}
 
-	// Test Search
-	results, err := service.Restaurant.Search("Rest", 10)
-	if err != nil || len(results) != 2 {
-		t.Fatalf("Failed to search restaurants: %v", err)
+	// Test GetByLocation
+	located, err := service.Restaurant.GetByLocation(0, 3, 0, 3)
+	if err != nil || len(located) != 2 {
+		t.Fatalf("Failed to get restaurants by location: %v", err)
 	}
 
 	// Test Count
@@ -236,3 +278,992 @@ func TestRestaurantRepository(t *testing.T) {
 		t.Fatalf("Failed to count restaurants: %v", err)
 	}
 }
+
+func TestRestaurantSearch(t *testing.T) {
+	service := newTestDB(t)
+
+	rests := []Restaurant{
+		{PlaceID: "s_substring", Name: "Blue Cafe", Rating: 4.9, UserRatingsTotal: 900},
+		{PlaceID: "s_prefix_low", Name: "Cafe Rouge", Rating: 3.5, UserRatingsTotal: 100},
+		{PlaceID: "s_prefix_high", Name: "Cafe Nero", Rating: 4.5, UserRatingsTotal: 50},
+		{PlaceID: "s_prefix_tie", Name: "Cafe Milano", Rating: 4.5, UserRatingsTotal: 200},
+		{PlaceID: "s_exact", Name: "CAFE", Rating: 3.0},
+		{PlaceID: "s_display", Name: "Joe's", DisplayName: "Joe's Café and Cafe Bar", Rating: 4.0},
+		{PlaceID: "s_other", Name: "Burger Barn", Rating: 5.0},
+		{PlaceID: "s_wildcard", Name: "100% Burgers", Rating: 4.0},
+	}
+	for i := range rests {
+		if err := service.Restaurant.Create(&rests[i]); err != nil {
+			t.Fatalf("Failed to create restaurant: %v", err)
+		}
+	}
+
+	results, err := service.Restaurant.Search("cafe", 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.PlaceID)
+	}
+	want := "[s_exact s_prefix_tie s_prefix_high s_prefix_low s_substring s_display]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("Expected %s, got %v", want, got)
+	}
+
+	limited, err := service.Restaurant.Search("cafe", 2)
+	if err != nil || len(limited) != 2 || limited[0].PlaceID != "s_exact" {
+		t.Errorf("Expected the top 2 matches, got %v: %v", limited, err)
+	}
+
+	// wildcards in the term are matched literally
+	literal, err := service.Restaurant.Search("0%", 10)
+	if err != nil || len(literal) != 1 || literal[0].PlaceID != "s_wildcard" {
+		t.Errorf("Expected only the literal match, got %v: %v", literal, err)
+	}
+
+	if _, err := service.Restaurant.Search("  ", 10); err == nil {
+		t.Error("Expected an error for an empty term")
+	}
+}
+
+func TestRestaurantGetAllMatching(t *testing.T) {
+	service := newTestDB(t)
+
+	rests := []Restaurant{
+		{PlaceID: "f1", Name: "Cafe", Latitude: 1, Longitude: 1, Rating: 3.5, PrimaryType: "cafe"},
+		{PlaceID: "f2", Name: "Diner", Latitude: 2, Longitude: 2, Rating: 4.5, PrimaryType: "restaurant"},
+		{PlaceID: "f3", Name: "Bistro", Latitude: 10, Longitude: 10, Rating: 4.8, PrimaryType: "restaurant"},
+	}
+	for _, r := range rests {
+		if err := service.Restaurant.Create(&r); err != nil {
+			t.Fatalf("Failed to create restaurant: %v", err)
+		}
+	}
+
+	all, err := service.Restaurant.GetAll(10, 0)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("Expected 3 restaurants unfiltered, got %d (err: %v)", len(all), err)
+	}
+
+	byType, err := service.Restaurant.GetAllMatching(PlaceFilter{PrimaryType: "restaurant"}, 10, 0)
+	if err != nil || len(byType) != 2 {
+		t.Fatalf("Expected 2 restaurants by type, got %d (err: %v)", len(byType), err)
+	}
+
+	byRating, err := service.Restaurant.GetAllMatching(PlaceFilter{MinRating: 4.6}, 10, 0)
+	if err != nil || len(byRating) != 1 || byRating[0].PlaceID != "f3" {
+		t.Fatalf("Expected only f3 above rating 4.6, got %v (err: %v)", byRating, err)
+	}
+
+	inBox, err := service.Restaurant.GetAllMatching(PlaceFilter{
+		PrimaryType: "restaurant",
+		HasBounds:   true,
+		MinLat:      0, MaxLat: 5, MinLng: 0, MaxLng: 5,
+	}, 10, 0)
+	if err != nil || len(inBox) != 1 || inBox[0].PlaceID != "f2" {
+		t.Fatalf("Expected only f2 in bounding box, got %v (err: %v)", inBox, err)
+	}
+}
+
+func TestGetAllAfterCursor(t *testing.T) {
+	service := newTestDB(t)
+
+	scs := make([]Supercharger, 25)
+	for i := range scs {
+		scs[i] = Supercharger{PlaceID: fmt.Sprintf("page_%02d", i)}
+	}
+	if err := service.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create superchargers: %v", err)
+	}
+
+	var seen []string
+	var cursor Cursor
+	for pages := 0; ; pages++ {
+		page, next, err := service.Supercharger.GetAllAfter(cursor, 10)
+		if err != nil {
+			t.Fatalf("GetAllAfter failed: %v", err)
+		}
+		if next.IsZero() {
+			if len(page) != 0 || pages != 3 {
+				t.Errorf("Expected 3 pages before an empty one, got %d then %d superchargers", pages, len(page))
+			}
+			break
+		}
+		for _, sc := range page {
+			seen = append(seen, sc.PlaceID)
+		}
+		// round trip through the token a client would hold
+		if cursor, err = ParseCursor(next.String()); err != nil {
+			t.Fatalf("ParseCursor failed: %v", err)
+		}
+	}
+	if len(seen) != 25 || seen[0] != "page_00" || seen[24] != "page_24" {
+		t.Errorf("Expected all 25 superchargers in order, got %v", seen)
+	}
+
+	for _, id := range []string{"page_b", "page_a", "page_c"} {
+		if err := service.Restaurant.Create(&Restaurant{PlaceID: id}); err != nil {
+			t.Fatalf("Failed to create restaurant: %v", err)
+		}
+	}
+	restaurants, next, err := service.Restaurant.GetAllAfter(Cursor{PlaceID: "page_a"}, 0)
+	if err != nil || len(restaurants) != 2 || restaurants[0].PlaceID != "page_b" || next.PlaceID != "page_c" {
+		t.Errorf("Expected page_b and page_c after page_a, got %v ending %q: %v", restaurants, next.PlaceID, err)
+	}
+
+	if _, err := ParseCursor("not a cursor!"); err == nil {
+		t.Error("Expected an invalid cursor to be rejected")
+	}
+	if cursor, err := ParseCursor(""); err != nil || !cursor.IsZero() {
+		t.Errorf("Expected an empty token to be the zero cursor, got %v: %v", cursor, err)
+	}
+}
+
+func TestSuperchargerCreateBatchLarge(t *testing.T) {
+	service := newTestDB(t)
+
+	// Enough rows that a single INSERT would exceed SQLite's variable limit
+	scs := make([]Supercharger, 1000)
+	for i := range scs {
+		scs[i] = Supercharger{PlaceID: fmt.Sprintf("batch_%d", i), Name: "Batch", IsSupercharger: true}
+	}
+
+	if err := service.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create batch superchargers: %v", err)
+	}
+
+	var count int64
+	if err := service.db.Model(&Supercharger{}).Count(&count).Error; err != nil || count != 1000 {
+		t.Fatalf("Expected 1000 superchargers, got %d (err: %v)", count, err)
+	}
+}
+
+func TestSuperchargerBatchGetByIDs(t *testing.T) {
+	service := newTestDB(t)
+
+	found, err := service.Supercharger.BatchGetByIDs(nil)
+	if err != nil || len(found) != 0 {
+		t.Fatalf("Expected nothing for no IDs, got %v (err: %v)", found, err)
+	}
+
+	// more than one IN clause's worth, plus IDs that aren't stored
+	scs := make([]Supercharger, 600)
+	ids := make([]string, 0, len(scs)+2)
+	for i := range scs {
+		scs[i] = Supercharger{PlaceID: fmt.Sprintf("bget_%d", i), Name: "Batch", IsSupercharger: true}
+		ids = append(ids, scs[i].PlaceID)
+	}
+	if err := service.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create batch superchargers: %v", err)
+	}
+	ids = append(ids, "missing_1", "missing_2")
+
+	found, err = service.Supercharger.BatchGetByIDs(ids)
+	if err != nil {
+		t.Fatalf("BatchGetByIDs failed: %v", err)
+	}
+	if len(found) != 600 {
+		t.Errorf("Expected 600 superchargers, got %d", len(found))
+	}
+	if sc := found["bget_599"]; sc == nil || sc.PlaceID != "bget_599" {
+		t.Errorf("Expected bget_599 keyed by its ID, got %v", sc)
+	}
+	if _, ok := found["missing_1"]; ok {
+		t.Error("Expected missing IDs to be absent from the map")
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	service := newTestDB(t)
+
+	restaurants := []RestaurantWithDistance{
+		{Restaurant: Restaurant{PlaceID: "inv_r1", Name: "Rest1"}, Distance: 100},
+	}
+	for _, sc := range []Supercharger{
+		{PlaceID: "inv_sc1", Latitude: 1, Longitude: 1, IsSupercharger: true},
+		{PlaceID: "inv_sc2", Latitude: 2, Longitude: 2, IsSupercharger: true},
+		{PlaceID: "inv_sc3", Latitude: 50, Longitude: 50, IsSupercharger: true},
+	} {
+		if err := service.Supercharger.AddSuperchargerWithRestaurants(&sc, restaurants); err != nil {
+			t.Fatalf("Failed to add supercharger: %v", err)
+		}
+	}
+
+	// Single supercharger
+	if err := service.InvalidateSupercharger("inv_sc1"); err != nil {
+		t.Fatalf("Failed to invalidate supercharger: %v", err)
+	}
+	if _, err := service.Supercharger.GetByID("inv_sc1"); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected invalidated supercharger to be gone, got %v", err)
+	}
+	if mapped, _ := service.Supercharger.GetRestaurantsForSupercharger("inv_sc1"); len(mapped) != 0 {
+		t.Errorf("Expected mappings to be removed, got %d", len(mapped))
+	}
+	if err := service.InvalidateSupercharger("inv_sc1"); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected not found for an uncached supercharger, got %v", err)
+	}
+
+	// Region
+	removed, err := service.InvalidateRegion(0, 10, 0, 10)
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 supercharger removed from region, got %d (err: %v)", removed, err)
+	}
+	if _, err := service.Supercharger.GetByID("inv_sc3"); err != nil {
+		t.Errorf("Expected supercharger outside the region to remain, got %v", err)
+	}
+
+	// Restaurants are shared between superchargers so they're kept
+	if _, err := service.Restaurant.GetByID("inv_r1"); err != nil {
+		t.Errorf("Expected restaurant to remain, got %v", err)
+	}
+}
+
+func TestPlaceTypes(t *testing.T) {
+	service := newTestDB(t)
+
+	withTypes := &Supercharger{PlaceID: "types_sc1", Types: []string{"electric_vehicle_charging_station", "point_of_interest"}}
+	if err := service.Supercharger.Create(withTypes); err != nil {
+		t.Fatalf("Failed to create supercharger: %v", err)
+	}
+	withoutTypes := &Supercharger{PlaceID: "types_sc2"}
+	if err := service.Supercharger.Create(withoutTypes); err != nil {
+		t.Fatalf("Failed to create supercharger: %v", err)
+	}
+
+	retrieved, err := service.Supercharger.GetByID("types_sc1")
+	if err != nil {
+		t.Fatalf("Failed to get supercharger: %v", err)
+	}
+	if len(retrieved.Types) != 2 || retrieved.Types[0] != "electric_vehicle_charging_station" {
+		t.Errorf("Types did not round trip, got %v", retrieved.Types)
+	}
+
+	// Missing types are stored as an empty array, not null
+	var raw string
+	if err := service.db.Raw("SELECT types FROM superchargers WHERE place_id = ?", "types_sc2").Scan(&raw).Error; err != nil {
+		t.Fatalf("Failed to read raw types: %v", err)
+	}
+	if raw != "[]" {
+		t.Errorf("Expected empty array for missing types, got %q", raw)
+	}
+
+}
+
+func TestLastUpdatedSetOnCreate(t *testing.T) {
+	service := newTestDB(t)
+
+	// the caller's copy is stamped rather than relying on the database default
+	supercharger := &Supercharger{PlaceID: "stamp_sc1"}
+	if err := service.Supercharger.Create(supercharger); err != nil {
+		t.Fatalf("Failed to create supercharger: %v", err)
+	}
+	if supercharger.LastUpdated.IsZero() || time.Since(supercharger.LastUpdated) > time.Minute {
+		t.Errorf("Expected LastUpdated to be set on create, got %v", supercharger.LastUpdated)
+	}
+	batch := []Supercharger{{PlaceID: "stamp_sc2"}}
+	if err := service.Supercharger.CreateBatch(batch); err != nil {
+		t.Fatalf("Failed to create superchargers: %v", err)
+	}
+	if batch[0].LastUpdated.IsZero() {
+		t.Error("Expected LastUpdated to be set on batch create")
+	}
+	restaurant := &Restaurant{PlaceID: "stamp_r1"}
+	if err := service.Restaurant.Create(restaurant); err != nil {
+		t.Fatalf("Failed to create restaurant: %v", err)
+	}
+	if restaurant.LastUpdated.IsZero() {
+		t.Error("Expected restaurant LastUpdated to be set on create")
+	}
+
+	// an explicit time, such as an old one in tests of expiry, is kept
+	old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	if err := service.Supercharger.Create(&Supercharger{PlaceID: "stamp_sc3", LastUpdated: old}); err != nil {
+		t.Fatalf("Failed to create supercharger: %v", err)
+	}
+	stored, err := service.Supercharger.GetByID("stamp_sc3")
+	if err != nil || !stored.LastUpdated.Equal(old) {
+		t.Errorf("Expected LastUpdated %v to be kept, got %v: %v", old, stored, err)
+	}
+}
+
+func TestSuperchargerForEachInLocation(t *testing.T) {
+	service := newTestDB(t)
+
+	scs := make([]Supercharger, 25)
+	for i := range scs {
+		scs[i] = Supercharger{PlaceID: fmt.Sprintf("stream_%02d", i), Latitude: 37, Longitude: -122, IsSupercharger: true}
+	}
+	// outside the box
+	scs = append(scs, Supercharger{PlaceID: "stream_far", Latitude: 10, Longitude: 10, IsSupercharger: true})
+	if err := service.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create superchargers: %v", err)
+	}
+
+	var batches, total int
+	err := service.Supercharger.ForEachInLocation(36, 38, -123, -121, 10, func(batch []Supercharger) error {
+		batches++
+		total += len(batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachInLocation failed: %v", err)
+	}
+	if batches != 3 || total != 25 {
+		t.Errorf("Expected 25 superchargers in 3 batches, got %d in %d", total, batches)
+	}
+}
+
+func TestGetByLocationAcrossAntimeridian(t *testing.T) {
+	service := newTestDB(t)
+
+	// Fiji straddles the antimeridian, with Nairobi far away on the same latitudes
+	scs := []Supercharger{
+		{PlaceID: "suva", IsSupercharger: true, Latitude: -18.1, Longitude: 178.4},
+		{PlaceID: "taveuni", IsSupercharger: true, Latitude: -16.9, Longitude: -179.9},
+		{PlaceID: "nairobi", IsSupercharger: true, Latitude: -1.3, Longitude: 36.8},
+	}
+	if err := service.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create superchargers: %v", err)
+	}
+	if err := service.Restaurant.Create(&Restaurant{PlaceID: "r_taveuni", Latitude: -16.8, Longitude: -179.95}); err != nil {
+		t.Fatalf("Failed to create restaurant: %v", err)
+	}
+
+	// a viewport from 177E east across the antimeridian to 179W
+	located, err := service.Supercharger.GetByLocation(-20, 0, 177, -179)
+	if err != nil {
+		t.Fatalf("GetByLocation failed: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, sc := range located {
+		ids[sc.PlaceID] = true
+	}
+	if len(located) != 2 || !ids["suva"] || !ids["taveuni"] {
+		t.Errorf("Expected both sides of the antimeridian and nothing else, got %v", ids)
+	}
+
+	restaurants, err := service.Restaurant.GetByLocation(-20, 0, 177, -179)
+	if err != nil || len(restaurants) != 1 {
+		t.Errorf("Expected the restaurant east of the antimeridian, got %v (err: %v)", restaurants, err)
+	}
+}
+
+func TestSuperchargerGetNearest(t *testing.T) {
+	service := newTestDB(t)
+
+	scs := []Supercharger{
+		{PlaceID: "near_1", Latitude: 37.01, Longitude: -122, IsSupercharger: true},
+		{PlaceID: "near_2", Latitude: 37.1, Longitude: -122, IsSupercharger: true},
+		// far beyond the first search radius
+		{PlaceID: "near_3", Latitude: 40, Longitude: -122, IsSupercharger: true},
+		{PlaceID: "near_rejected", Latitude: 37, Longitude: -122, IsSupercharger: false},
+		// either side of the antimeridian
+		{PlaceID: "near_east", Latitude: 0, Longitude: 179.9, IsSupercharger: true},
+		{PlaceID: "near_west", Latitude: 0, Longitude: -179.9, IsSupercharger: true},
+	}
+	if err := service.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create superchargers: %v", err)
+	}
+
+	nearest, err := service.Supercharger.GetNearest(37, -122, 3)
+	if err != nil {
+		t.Fatalf("GetNearest failed: %v", err)
+	}
+	if len(nearest) != 3 || nearest[0].PlaceID != "near_1" || nearest[1].PlaceID != "near_2" || nearest[2].PlaceID != "near_3" {
+		t.Fatalf("Expected near_1, near_2 and near_3 in order, got %v", nearest)
+	}
+	if nearest[0].Distance < 1000 || nearest[0].Distance > 1200 {
+		t.Errorf("Expected near_1 about 1.1km away, got %vm", nearest[0].Distance)
+	}
+
+	across, err := service.Supercharger.GetNearest(0, 179.99, 2)
+	if err != nil {
+		t.Fatalf("GetNearest failed: %v", err)
+	}
+	if len(across) != 2 || across[0].PlaceID != "near_east" || across[1].PlaceID != "near_west" {
+		t.Errorf("Expected both chargers across the antimeridian, got %v", across)
+	}
+
+	// more than exist searches the whole Earth, including over the pole
+	all, err := service.Supercharger.GetNearest(89.9, 0, 10)
+	if err != nil || len(all) != 5 {
+		t.Errorf("Expected all 5 confirmed superchargers, got %d: %v", len(all), err)
+	}
+
+	if _, err := service.Supercharger.GetNearest(37, -122, 0); err == nil {
+		t.Error("Expected an error for a zero limit")
+	}
+}
+
+func TestMaintain(t *testing.T) {
+	service := newTestDB(t)
+
+	scs := make([]Supercharger, 500)
+	for i := range scs {
+		scs[i] = Supercharger{PlaceID: fmt.Sprintf("maintain_%d", i), Latitude: 1, Longitude: 1, IsSupercharger: true}
+	}
+	if err := service.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create superchargers: %v", err)
+	}
+	if _, err := service.InvalidateRegion(0, 2, 0, 2); err != nil {
+		t.Fatalf("Failed to invalidate region: %v", err)
+	}
+
+	if err := service.Maintain(); err != nil {
+		t.Fatalf("Maintain failed: %v", err)
+	}
+
+	// VACUUM isn't allowed inside a transaction
+	err := service.Transaction(func(tx *Service) error {
+		return tx.Maintain()
+	})
+	if err == nil {
+		t.Error("Expected Maintain to fail inside a transaction")
+	}
+}
+
+func TestSuperchargerDensityGrid(t *testing.T) {
+	service := newTestDB(t)
+
+	scs := []Supercharger{
+		{PlaceID: "density_1", Latitude: 37.1, Longitude: -121.9, IsSupercharger: true},
+		{PlaceID: "density_2", Latitude: 37.2, Longitude: -121.8, IsSupercharger: true},
+		{PlaceID: "density_3", Latitude: 37.7, Longitude: -121.2, IsSupercharger: true},
+		{PlaceID: "density_4", Latitude: 37.1, Longitude: -121.9, IsSupercharger: false}, // not a supercharger
+		{PlaceID: "density_5", Latitude: 40.0, Longitude: -121.9, IsSupercharger: true},  // outside the box
+	}
+	if err := service.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create superchargers: %v", err)
+	}
+
+	cells, err := service.Supercharger.DensityGrid(37, 38, -122, -121, 0.5)
+	if err != nil {
+		t.Fatalf("DensityGrid failed: %v", err)
+	}
+	if len(cells) != 2 {
+		t.Fatalf("Expected 2 occupied cells, got %d: %+v", len(cells), cells)
+	}
+	if cells[0].Count != 2 || cells[0].MinLat != 37 || cells[0].MaxLng != -121.5 {
+		t.Errorf("Unexpected first cell: %+v", cells[0])
+	}
+	if cells[1].Count != 1 || cells[1].MinLat != 37.5 || cells[1].MinLng != -121.5 {
+		t.Errorf("Unexpected second cell: %+v", cells[1])
+	}
+
+	if _, err := service.Supercharger.DensityGrid(37, 38, -122, -121, 0); err == nil {
+		t.Error("Expected error for zero cell size")
+	}
+}
+
+func TestStatsCounts(t *testing.T) {
+	service := newTestDB(t)
+
+	restaurants := []RestaurantWithDistance{
+		{Restaurant: Restaurant{PlaceID: "stats_r1"}, Distance: 100},
+		{Restaurant: Restaurant{PlaceID: "stats_r2"}, Distance: 200},
+	}
+	if err := service.Supercharger.AddSuperchargerWithRestaurants(&Supercharger{PlaceID: "stats_sc1", IsSupercharger: true}, restaurants); err != nil {
+		t.Fatalf("Failed to add supercharger: %v", err)
+	}
+	if err := service.Supercharger.Create(&Supercharger{PlaceID: "stats_sc2", IsSupercharger: false}); err != nil {
+		t.Fatalf("Failed to create supercharger: %v", err)
+	}
+
+	if count, err := service.Supercharger.Count(); err != nil || count != 2 {
+		t.Errorf("Expected 2 superchargers, got %d (err: %v)", count, err)
+	}
+	if count, err := service.Supercharger.CountConfirmed(); err != nil || count != 1 {
+		t.Errorf("Expected 1 confirmed supercharger, got %d (err: %v)", count, err)
+	}
+	if count, err := service.Supercharger.CountRestaurantMappings(); err != nil || count != 2 {
+		t.Errorf("Expected 2 mappings, got %d (err: %v)", count, err)
+	}
+
+	for i, hit := range []bool{true, true, false, true} {
+		if err := service.CacheHit.Create(&CacheHit{ObjectID: fmt.Sprintf("stats_hit_%d", i), Hit: hit, Type: "place_details"}); err != nil {
+			t.Fatalf("Failed to create cache hit: %v", err)
+		}
+	}
+	if err := service.CacheHit.Create(&CacheHit{ObjectID: "stats_route", Hit: false, Type: "route"}); err != nil {
+		t.Fatalf("Failed to create cache hit: %v", err)
+	}
+
+	rates, err := service.CacheHit.GetHitRates()
+	if err != nil {
+		t.Fatalf("GetHitRates failed: %v", err)
+	}
+	if rates["place_details"] != 0.75 || rates["route"] != 0 || len(rates) != 2 {
+		t.Errorf("Unexpected hit rates: %v", rates)
+	}
+}
+
+func TestCacheHitBuffer(t *testing.T) {
+	service := newTestDB(t)
+
+	// an existing entry is updated rather than duplicated
+	if err := service.CacheHit.Create(&CacheHit{ObjectID: "buffer_0", Hit: false, Type: "supercharger"}); err != nil {
+		t.Fatalf("Failed to create cache hit: %v", err)
+	}
+
+	buffer := NewCacheHitBuffer(service.CacheHit, 1000, time.Hour)
+	for i := 0; i < 100; i++ {
+		buffer.Record(CacheHit{ObjectID: fmt.Sprintf("buffer_%d", i%50), Hit: true, Type: "supercharger"})
+	}
+
+	var count int64
+	service.db.Model(&CacheHit{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected nothing written before flushing, got %d rows", count)
+	}
+
+	if err := buffer.Close(); err != nil {
+		t.Fatalf("Failed to close buffer: %v", err)
+	}
+
+	service.db.Model(&CacheHit{}).Count(&count)
+	if count != 50 {
+		t.Errorf("Expected 50 cache hits, got %d", count)
+	}
+	rate, err := service.CacheHit.GetHitRate("supercharger")
+	if err != nil || rate != 1 {
+		t.Errorf("Expected every entry to be a hit, got %v (err: %v)", rate, err)
+	}
+
+	// filling the buffer flushes without waiting for the interval
+	buffer = NewCacheHitBuffer(service.CacheHit, 10, time.Hour)
+	defer buffer.Close()
+	for i := 0; i < 10; i++ {
+		buffer.Record(CacheHit{ObjectID: fmt.Sprintf("full_%d", i), Hit: true, Type: "route"})
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		service.db.Model(&CacheHit{}).Where("type = ?", "route").Count(&count)
+		if count == 10 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count != 10 {
+		t.Errorf("Expected full buffer to flush 10 hits, got %d", count)
+	}
+}
+
+func TestSavedTrips(t *testing.T) {
+	service := newTestDB(t)
+	now := time.Now()
+
+	trips := []SavedTrip{
+		{Slug: "current1", Origin: "A", Destination: "B", Result: `{"superchargers":[]}`, ExpiresAt: now.Add(time.Hour)},
+		{Slug: "expired1", Origin: "C", Destination: "D", Result: `{"superchargers":[]}`, ExpiresAt: now.Add(-time.Hour)},
+	}
+	for i := range trips {
+		if err := service.SavedTrip.Create(&trips[i]); err != nil {
+			t.Fatalf("Failed to create trip: %v", err)
+		}
+	}
+
+	trip, err := service.SavedTrip.GetBySlug("current1")
+	if err != nil {
+		t.Fatalf("Failed to get trip: %v", err)
+	}
+	if trip.Origin != "A" || trip.Result != `{"superchargers":[]}` {
+		t.Errorf("Unexpected trip: %+v", trip)
+	}
+
+	// Expired trips are hidden before they are pruned
+	if _, err := service.SavedTrip.GetBySlug("expired1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected expired trip to be not found, got %v", err)
+	}
+
+	deleted, err := service.SavedTrip.DeleteExpired(now)
+	if err != nil {
+		t.Fatalf("Failed to delete expired trips: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 expired trip deleted, got %d", deleted)
+	}
+	if _, err := service.SavedTrip.GetBySlug("current1"); err != nil {
+		t.Errorf("Expected current trip to survive pruning: %v", err)
+	}
+}
+
+func TestMapsCallLogFind(t *testing.T) {
+	service := newTestDB(t)
+	now := time.Now()
+
+	logs := []MapsCallLog{
+		{SKU: "place_details", Timestamp: now.Add(-3 * time.Hour)},
+		{SKU: "place_details", Timestamp: now.Add(-2 * time.Hour), Error: "quota exceeded"},
+		{SKU: "place_details", Timestamp: now.Add(-time.Hour)},
+		{SKU: "routes", Timestamp: now.Add(-time.Hour), Error: "timeout"},
+	}
+	for i := range logs {
+		if err := service.MapsCallLog.Create(&logs[i]); err != nil {
+			t.Fatalf("Failed to create log: %v", err)
+		}
+	}
+
+	found, total, err := service.MapsCallLog.Find(MapsCallLogFilter{SKU: "place_details"}, 2, 0)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if total != 3 || len(found) != 2 {
+		t.Fatalf("Expected 2 of 3 place details logs, got %d of %d", len(found), total)
+	}
+	if !found[0].Timestamp.After(found[1].Timestamp) {
+		t.Error("Expected newest logs first")
+	}
+
+	hasError := true
+	found, total, err = service.MapsCallLog.Find(MapsCallLogFilter{
+		SKU:      "place_details",
+		From:     now.Add(-150 * time.Minute),
+		HasError: &hasError,
+	}, 10, 0)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if total != 1 || len(found) != 1 || found[0].Error != "quota exceeded" {
+		t.Errorf("Expected only the failed place details call, got %+v", found)
+	}
+}
+
+func TestMapsCallLogEstimateCost(t *testing.T) {
+	service := newTestDB(t)
+	now := time.Now()
+
+	logs := []MapsCallLog{
+		{SKU: "place_details", Timestamp: now.Add(-3 * time.Hour)},
+		{SKU: "place_details", Timestamp: now.Add(-2 * time.Hour)},
+		{SKU: "routes", Timestamp: now.Add(-time.Hour)},
+		{SKU: "mystery", Timestamp: now.Add(-time.Hour)},
+		{SKU: "routes", Timestamp: now.Add(-48 * time.Hour)},
+	}
+	for i := range logs {
+		if err := service.MapsCallLog.Create(&logs[i]); err != nil {
+			t.Fatalf("Failed to create log: %v", err)
+		}
+	}
+
+	pricing := map[string]float64{"place_details": 0.017, "routes": 0.005}
+	total, breakdown, err := service.MapsCallLog.EstimateCost(now.Add(-24*time.Hour), now, pricing)
+	if err != nil {
+		t.Fatalf("EstimateCost failed: %v", err)
+	}
+
+	if want := 2*0.017 + 0.005; math.Abs(total-want) > 1e-9 {
+		t.Errorf("Expected total %v, got %v", want, total)
+	}
+	if len(breakdown) != 3 {
+		t.Fatalf("Expected 3 SKUs in the breakdown, got %+v", breakdown)
+	}
+	if details := breakdown["place_details"]; details.Calls != 2 || details.Unpriced {
+		t.Errorf("Unexpected place details cost %+v", details)
+	}
+	if routes := breakdown["routes"]; routes.Calls != 1 {
+		t.Errorf("Expected the old routes call outside the range, got %+v", routes)
+	}
+	if mystery := breakdown["mystery"]; !mystery.Unpriced || mystery.Cost != 0 || mystery.Calls != 1 {
+		t.Errorf("Expected the unknown SKU flagged as unpriced, got %+v", mystery)
+	}
+}
+
+func TestMapsCallLogBuffer(t *testing.T) {
+	service := newTestDB(t)
+
+	buffer := NewMapsCallLogBuffer(service.MapsCallLog, 1000, time.Hour)
+	for i := 0; i < 5; i++ {
+		buffer.Record(MapsCallLog{SKU: "place_details"})
+	}
+	if count, _ := service.MapsCallLog.Count(); count != 0 {
+		t.Errorf("Expected nothing written before flushing, got %d rows", count)
+	}
+	if err := buffer.Close(); err != nil {
+		t.Fatalf("Failed to close buffer: %v", err)
+	}
+
+	// entries are found by time range whatever zone the caller's bounds are in
+	pacific := time.FixedZone("PDT", -7*60*60)
+	now := time.Now().In(pacific)
+	_, breakdown, err := service.MapsCallLog.EstimateCost(now.Add(-time.Minute), now.Add(time.Minute), nil)
+	if err != nil {
+		t.Fatalf("EstimateCost failed: %v", err)
+	}
+	if calls := breakdown["place_details"].Calls; calls != 5 {
+		t.Errorf("Expected the 5 buffered calls in range, got %d", calls)
+	}
+	logs, total, err := service.MapsCallLog.Find(MapsCallLogFilter{From: now.Add(-time.Minute)}, 10, 0)
+	if err != nil || total != 5 || len(logs) != 5 {
+		t.Errorf("Expected 5 logs since a minute ago, got %d (err: %v)", total, err)
+	}
+
+	// filling the buffer flushes without waiting for the interval
+	buffer = NewMapsCallLogBuffer(service.MapsCallLog, 10, time.Hour)
+	defer buffer.Close()
+	for i := 0; i < 10; i++ {
+		buffer.Record(MapsCallLog{SKU: "routes"})
+	}
+	var count int64
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		service.db.Model(&MapsCallLog{}).Where("sku = ?", "routes").Count(&count)
+		if count == 10 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count != 10 {
+		t.Errorf("Expected full buffer to flush 10 entries, got %d", count)
+	}
+}
+
+func TestSuperchargerForEachConfirmed(t *testing.T) {
+	service := newTestDB(t)
+	now := time.Now()
+
+	scs := []Supercharger{
+		{PlaceID: "confirmed_old", IsSupercharger: true, LastUpdated: now.Add(-48 * time.Hour)},
+		{PlaceID: "confirmed_new", IsSupercharger: true, LastUpdated: now},
+		{PlaceID: "not_supercharger", IsSupercharger: false, LastUpdated: now},
+	}
+	if err := service.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create superchargers: %v", err)
+	}
+
+	collect := func(since time.Time) []string {
+		var ids []string
+		err := service.Supercharger.ForEachConfirmed(since, 1, func(batch []Supercharger) error {
+			for _, sc := range batch {
+				ids = append(ids, sc.PlaceID)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ForEachConfirmed failed: %v", err)
+		}
+		return ids
+	}
+
+	if ids := collect(time.Time{}); len(ids) != 2 {
+		t.Errorf("Expected both confirmed superchargers, got %v", ids)
+	}
+	if ids := collect(now.Add(-time.Hour)); len(ids) != 1 || ids[0] != "confirmed_new" {
+		t.Errorf("Expected only the recently updated supercharger, got %v", ids)
+	}
+}
+
+func TestSuperchargerCoverageHull(t *testing.T) {
+	service := newTestDB(t)
+
+	hull, err := service.Supercharger.CoverageHull()
+	if err != nil || len(hull) != 0 {
+		t.Fatalf("Expected an empty hull without superchargers, got %v (err: %v)", hull, err)
+	}
+
+	// a 10x10 grid of confirmed chargers plus one unconfirmed place outside it
+	var scs []Supercharger
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			scs = append(scs, Supercharger{PlaceID: fmt.Sprintf("hull_%d_%d", i, j), Latitude: float64(i), Longitude: float64(j), IsSupercharger: true})
+		}
+	}
+	scs = append(scs, Supercharger{PlaceID: "hull_unconfirmed", Latitude: 50, Longitude: 50})
+	if err := service.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create superchargers: %v", err)
+	}
+
+	hull, err = service.Supercharger.CoverageHull()
+	if err != nil {
+		t.Fatalf("CoverageHull failed: %v", err)
+	}
+	expected := []Coordinate{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 9},
+		{Latitude: 9, Longitude: 9},
+		{Latitude: 9, Longitude: 0},
+	}
+	if len(hull) != len(expected) {
+		t.Fatalf("Expected the grid corners, got %v", hull)
+	}
+	for i := range expected {
+		if hull[i] != expected[i] {
+			t.Errorf("Hull point %d: expected %v, got %v", i, expected[i], hull[i])
+		}
+	}
+}
+
+func TestMoveRejectedPlaces(t *testing.T) {
+	service := newTestDB(t)
+
+	checked := time.Now().Add(-time.Hour).Truncate(time.Second)
+	scs := []Supercharger{
+		{PlaceID: "move_real", Name: "Supercharger", LastUpdated: checked, IsSupercharger: true},
+		{PlaceID: "move_gas", Name: "Gas Station", LastUpdated: checked},
+	}
+	if err := service.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create superchargers: %v", err)
+	}
+
+	moved, err := service.MoveRejectedPlaces()
+	if err != nil {
+		t.Fatalf("MoveRejectedPlaces failed: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("Expected 1 place moved, got %d", moved)
+	}
+
+	if _, err := service.Supercharger.GetByID("move_gas"); err == nil {
+		t.Error("Expected the rejected place to be removed from the superchargers table")
+	}
+	if _, err := service.Supercharger.GetByID("move_real"); err != nil {
+		t.Errorf("Expected the real supercharger to be kept: %v", err)
+	}
+	rejected, err := service.RejectedPlace.GetByID("move_gas")
+	if err != nil {
+		t.Fatalf("Expected the place in the rejected table: %v", err)
+	}
+	if !rejected.CheckedAt.Equal(checked) {
+		t.Errorf("Expected checked_at %v, got %v", checked, rejected.CheckedAt)
+	}
+
+	// running it again finds nothing left to move
+	if moved, err := service.MoveRejectedPlaces(); err != nil || moved != 0 {
+		t.Errorf("Expected nothing to move, got %d (err: %v)", moved, err)
+	}
+}
+
+func TestGetTopRestaurants(t *testing.T) {
+	service := newTestDB(t)
+
+	if err := service.Supercharger.Create(&Supercharger{PlaceID: "top_sc", IsSupercharger: true}); err != nil {
+		t.Fatalf("Failed to create supercharger: %v", err)
+	}
+	err := service.Supercharger.SetRestaurantsForSupercharger("top_sc", []RestaurantWithDistance{
+		{Restaurant: Restaurant{PlaceID: "close_poor", Rating: 2.5}, Distance: 100},
+		{Restaurant: Restaurant{PlaceID: "far_great", Rating: 5}, Distance: 140},
+		{Restaurant: Restaurant{PlaceID: "unrated", Rating: 0}, Distance: 80},
+		{Restaurant: Restaurant{PlaceID: "far_poor", Rating: 2}, Distance: 400},
+	}, "", 0)
+	if err != nil {
+		t.Fatalf("Failed to associate restaurants: %v", err)
+	}
+
+	// ranks are 140, 150, 160 and 640 meters
+	top, err := service.Supercharger.GetTopRestaurants("top_sc", 3, 0)
+	if err != nil {
+		t.Fatalf("GetTopRestaurants failed: %v", err)
+	}
+	var got []string
+	for _, r := range top {
+		got = append(got, r.PlaceID)
+	}
+	if fmt.Sprint(got) != "[far_great close_poor unrated]" {
+		t.Errorf("Expected [far_great close_poor unrated], got %v", got)
+	}
+	if top[0].Distance != 140 {
+		t.Errorf("Expected the mapping distance to be returned, got %v", top[0].Distance)
+	}
+
+	rated, err := service.Supercharger.GetTopRestaurants("top_sc", 0, 2.5)
+	if err != nil {
+		t.Fatalf("GetTopRestaurants failed: %v", err)
+	}
+	if len(rated) != 2 {
+		t.Errorf("Expected 2 restaurants rated 2.5 or more, got %d", len(rated))
+	}
+}
+
+func TestTrimRestaurantMappings(t *testing.T) {
+	service := newTestDB(t)
+
+	restaurants := []RestaurantWithDistance{
+		{Restaurant: Restaurant{PlaceID: "trim_close_poor", Rating: 2.5}, Distance: 100},
+		{Restaurant: Restaurant{PlaceID: "trim_far_great", Rating: 5}, Distance: 140},
+		{Restaurant: Restaurant{PlaceID: "trim_unrated", Rating: 0}, Distance: 80},
+		{Restaurant: Restaurant{PlaceID: "trim_far_poor", Rating: 2}, Distance: 400},
+	}
+	// ranks are 140, 150, 160 and 640 meters, and the original order is kept
+	var got []string
+	for _, r := range TopRestaurants(restaurants, 2) {
+		got = append(got, r.PlaceID)
+	}
+	if fmt.Sprint(got) != "[trim_close_poor trim_far_great]" {
+		t.Errorf("Expected [trim_close_poor trim_far_great], got %v", got)
+	}
+	if all := TopRestaurants(restaurants, 0); len(all) != len(restaurants) {
+		t.Errorf("Expected zero to keep all restaurants, got %d", len(all))
+	}
+
+	for _, id := range []string{"trim_dense", "trim_sparse"} {
+		if err := service.Supercharger.Create(&Supercharger{PlaceID: id, IsSupercharger: true}); err != nil {
+			t.Fatalf("Failed to create supercharger: %v", err)
+		}
+	}
+	if err := service.Supercharger.SetRestaurantsForSupercharger("trim_dense", restaurants, "", 0); err != nil {
+		t.Fatalf("Failed to associate restaurants: %v", err)
+	}
+	if err := service.Supercharger.SetRestaurantsForSupercharger("trim_sparse", restaurants[:2], "", 0); err != nil {
+		t.Fatalf("Failed to associate restaurants: %v", err)
+	}
+
+	trimmed, err := service.Supercharger.TrimRestaurantMappings(2)
+	if err != nil {
+		t.Fatalf("TrimRestaurantMappings failed: %v", err)
+	}
+	if trimmed != 2 {
+		t.Errorf("Expected 2 mappings trimmed, got %d", trimmed)
+	}
+	dense, err := service.Supercharger.GetRestaurantsForSupercharger("trim_dense")
+	if err != nil || len(dense) != 2 || dense[0].PlaceID != "trim_close_poor" || dense[1].PlaceID != "trim_far_great" {
+		t.Errorf("Expected the best two restaurants kept, got %v: %v", dense, err)
+	}
+	if sparse, _ := service.Supercharger.GetRestaurantsForSupercharger("trim_sparse"); len(sparse) != 2 {
+		t.Errorf("Expected a supercharger within the cap to be untouched, got %d restaurants", len(sparse))
+	}
+}
+
+func TestFetchStats(t *testing.T) {
+	service := newTestDB(t)
+
+	for _, isSupercharger := range []bool{true, true, false} {
+		if err := service.FetchStats.RecordSuccess("stats_flip", isSupercharger); err != nil {
+			t.Fatalf("RecordSuccess failed: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := service.FetchStats.RecordFailure("stats_fail"); err != nil {
+			t.Fatalf("RecordFailure failed: %v", err)
+		}
+	}
+	if err := service.FetchStats.RecordSuccess("stats_fail", true); err != nil {
+		t.Fatalf("RecordSuccess failed: %v", err)
+	}
+
+	stats, err := service.FetchStats.GetByIDs([]string{"stats_flip", "stats_fail", "stats_none"})
+	if err != nil {
+		t.Fatalf("GetByIDs failed: %v", err)
+	}
+	if _, ok := stats["stats_none"]; ok {
+		t.Error("Expected no stats for a place never looked up")
+	}
+
+	flip := stats["stats_flip"]
+	if flip.Successes != 3 || flip.Reclassifications != 1 || flip.LastIsSupercharger == nil || *flip.LastIsSupercharger {
+		t.Errorf("Expected 3 successes and 1 reclassification ending not a supercharger, got %+v", flip)
+	}
+	if got := flip.Reliability(); got != 0.8 {
+		t.Errorf("Expected reliability 0.8, got %v", got)
+	}
+
+	fail := stats["stats_fail"]
+	if fail.Failures != 3 || fail.Successes != 1 || fail.Reclassifications != 0 {
+		t.Errorf("Expected 3 failures and 1 success, got %+v", fail)
+	}
+	if got := fail.Reliability(); got != 0.4 {
+		t.Errorf("Expected reliability 0.4, got %v", got)
+	}
+	if got := (FetchStats{}).Reliability(); got != 1 {
+		t.Errorf("Expected no history to be fully reliable, got %v", got)
+	}
+}
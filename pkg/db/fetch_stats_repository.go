@@ -0,0 +1,60 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FetchStatsRepository provides operations for FetchStats entities
+type FetchStatsRepository struct {
+	db *gorm.DB
+}
+
+// NewFetchStatsRepository creates a new FetchStatsRepository
+func NewFetchStatsRepository(db *gorm.DB) *FetchStatsRepository {
+	return &FetchStatsRepository{db: db}
+}
+
+// RecordSuccess counts a successful lookup of the place, and a reclassification if it found a different
+// answer to whether the place is a supercharger than the last one. It is a single upsert, so concurrent
+// lookups don't lose counts.
+func (r *FetchStatsRepository) RecordSuccess(placeID string, isSupercharger bool) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "place_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"successes":            gorm.Expr("successes + 1"),
+			"reclassifications":    gorm.Expr("reclassifications + (last_is_supercharger IS NOT NULL AND last_is_supercharger != excluded.last_is_supercharger)"),
+			"last_is_supercharger": gorm.Expr("excluded.last_is_supercharger"),
+			"updated_at":           gorm.Expr("excluded.updated_at"),
+		}),
+	}).Create(&FetchStats{PlaceID: placeID, Successes: 1, LastIsSupercharger: &isSupercharger, UpdatedAt: time.Now()}).Error
+}
+
+// RecordFailure counts a failed lookup of the place
+func (r *FetchStatsRepository) RecordFailure(placeID string) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "place_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failures":   gorm.Expr("failures + 1"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+	}).Create(&FetchStats{PlaceID: placeID, Failures: 1, UpdatedAt: time.Now()}).Error
+}
+
+// GetByIDs retrieves the stats for the given places, keyed by PlaceID. Places without history are missing.
+func (r *FetchStatsRepository) GetByIDs(placeIDs []string) (map[string]FetchStats, error) {
+	found := make(map[string]FetchStats, len(placeIDs))
+	for start := 0; start < len(placeIDs); start += batchGetSize {
+		var stats []FetchStats
+		chunk := placeIDs[start:min(start+batchGetSize, len(placeIDs))]
+		if err := r.db.Where("place_id IN ?", chunk).Find(&stats).Error; err != nil {
+			return nil, err
+		}
+		for _, s := range stats {
+			found[s.PlaceID] = s
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,53 @@
+package db
+
+import "sort"
+
+// convexHull computes the convex hull with Andrew's monotone chain, treating longitude as x and
+// latitude as y. It's not meant for sets spanning the antimeridian.
+func convexHull(points []Coordinate) []Coordinate {
+	sorted := make([]Coordinate, len(points))
+	copy(sorted, points)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Longitude != sorted[j].Longitude {
+			return sorted[i].Longitude < sorted[j].Longitude
+		}
+		return sorted[i].Latitude < sorted[j].Latitude
+	})
+
+	// drop duplicates so stacked chargers don't produce degenerate edges
+	unique := sorted[:0]
+	for i, p := range sorted {
+		if i == 0 || p != sorted[i-1] {
+			unique = append(unique, p)
+		}
+	}
+	if len(unique) < 3 {
+		return unique
+	}
+
+	// cross is positive when o -> a -> b turns counter-clockwise
+	cross := func(o, a, b Coordinate) float64 {
+		return (a.Longitude-o.Longitude)*(b.Latitude-o.Latitude) - (a.Latitude-o.Latitude)*(b.Longitude-o.Longitude)
+	}
+
+	hull := make([]Coordinate, 0, 2*len(unique))
+	// lower hull
+	for _, p := range unique {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	// upper hull
+	lower := len(hull) + 1
+	for i := len(unique) - 2; i >= 0; i-- {
+		p := unique[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+
+	// the last point is the first one again
+	return hull[:len(hull)-1]
+}
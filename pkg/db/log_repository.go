@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // MapsCallLogRepository provides CRUD operations for MapsCallLog entities
@@ -21,6 +22,14 @@ func (r *MapsCallLogRepository) Create(log *MapsCallLog) error {
 	return r.db.Create(log).Error
 }
 
+// CreateBatch creates several maps call log entries at once
+func (r *MapsCallLogRepository) CreateBatch(logs []MapsCallLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(logs, 100).Error
+}
+
 // GetByID retrieves a maps call log by its ID
 func (r *MapsCallLogRepository) GetByID(id uint) (*MapsCallLog, error) {
 	var log MapsCallLog
@@ -34,7 +43,7 @@ func (r *MapsCallLogRepository) GetByID(id uint) (*MapsCallLog, error) {
 // GetByTimeRange retrieves logs within a time range
 func (r *MapsCallLogRepository) GetByTimeRange(start, end time.Time, limit, offset int) ([]MapsCallLog, error) {
 	var logs []MapsCallLog
-	query := r.db.Where("timestamp BETWEEN ? AND ?", start, end).Order("timestamp DESC")
+	query := r.db.Where("timestamp BETWEEN ? AND ?", start.UTC(), end.UTC()).Order("timestamp DESC")
 
 	if limit > 0 {
 		query = query.Limit(limit)
@@ -79,6 +88,94 @@ func (r *MapsCallLogRepository) GetWithErrors(limit, offset int) ([]MapsCallLog,
 	return logs, err
 }
 
+// MapsCallLogFilter narrows a maps call log search. Zero values don't filter.
+type MapsCallLogFilter struct {
+	SKU      string
+	From     time.Time
+	To       time.Time
+	HasError *bool
+}
+
+// apply adds the filter's conditions to a query
+func (f MapsCallLogFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.SKU != "" {
+		query = query.Where("sku = ?", f.SKU)
+	}
+	if !f.From.IsZero() {
+		query = query.Where("timestamp >= ?", f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		query = query.Where("timestamp <= ?", f.To.UTC())
+	}
+	if f.HasError != nil {
+		if *f.HasError {
+			query = query.Where("error != ''")
+		} else {
+			query = query.Where("error = ''")
+		}
+	}
+	return query
+}
+
+// Find retrieves logs matching all of the filter's conditions, newest first, along with
+// the total number of matches so callers can page through them
+func (r *MapsCallLogRepository) Find(filter MapsCallLogFilter, limit, offset int) ([]MapsCallLog, int64, error) {
+	var total int64
+	if err := filter.apply(r.db.Model(&MapsCallLog{})).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []MapsCallLog
+	query := filter.apply(r.db).Order("timestamp DESC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	err := query.Find(&logs).Error
+	return logs, total, err
+}
+
+// SKUCost is the estimated spend on one SKU
+type SKUCost struct {
+	Calls     int64   `json:"calls"`
+	UnitPrice float64 `json:"unit_price"`
+	Cost      float64 `json:"cost"`
+	// Unpriced is set when the SKU was logged but had no price, so its zero cost is unknown rather than free
+	Unpriced bool `json:"unpriced,omitempty"`
+}
+
+// EstimateCost totals the spend on calls logged between start and end, pricing each call by its SKU's
+// unit price in pricing. The breakdown has an entry for every SKU logged in the range, including any
+// missing from pricing, which are flagged as unpriced and counted as free in the total.
+func (r *MapsCallLogRepository) EstimateCost(start, end time.Time, pricing map[string]float64) (float64, map[string]SKUCost, error) {
+	var rows []struct {
+		SKU   string
+		Calls int64
+	}
+	err := r.db.Model(&MapsCallLog{}).
+		Select("sku, COUNT(*) AS calls").
+		Where("timestamp BETWEEN ? AND ?", start.UTC(), end.UTC()).
+		Group("sku").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var total float64
+	breakdown := make(map[string]SKUCost, len(rows))
+	for _, row := range rows {
+		price, ok := pricing[row.SKU]
+		cost := SKUCost{Calls: row.Calls, UnitPrice: price, Cost: float64(row.Calls) * price, Unpriced: !ok}
+		breakdown[row.SKU] = cost
+		total += cost.Cost
+	}
+	return total, breakdown, nil
+}
+
 // Delete deletes a maps call log by ID
 func (r *MapsCallLogRepository) Delete(id uint) error {
 	return r.db.Where("id = ?", id).Delete(&MapsCallLog{}).Error
@@ -86,7 +183,7 @@ func (r *MapsCallLogRepository) Delete(id uint) error {
 
 // DeleteOlderThan deletes logs older than the specified time
 func (r *MapsCallLogRepository) DeleteOlderThan(cutoff time.Time) error {
-	return r.db.Where("timestamp < ?", cutoff).Delete(&MapsCallLog{}).Error
+	return r.db.Where("timestamp < ?", cutoff.UTC()).Delete(&MapsCallLog{}).Error
 }
 
 // Count returns total number of logs
@@ -147,6 +244,17 @@ func (r *CacheHitRepository) Upsert(cacheHit *CacheHit) error {
 	return r.db.Save(cacheHit).Error
 }
 
+// UpsertBatch creates or updates many cache hit entries in as few statements as possible
+func (r *CacheHitRepository) UpsertBatch(hits []CacheHit) error {
+	if len(hits) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "object_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"hit", "last_updated", "type"}),
+	}).CreateInBatches(hits, createBatchSize).Error
+}
+
 // Delete deletes a cache hit by object ID
 func (r *CacheHitRepository) Delete(objectID string) error {
 	return r.db.Where("object_id = ?", objectID).Delete(&CacheHit{}).Error
@@ -175,6 +283,28 @@ func (r *CacheHitRepository) GetHitRate(cacheType string) (float64, error) {
 	return float64(hits) / float64(total), nil
 }
 
+// GetHitRates calculates the cache hit rate for every type that has entries
+func (r *CacheHitRepository) GetHitRates() (map[string]float64, error) {
+	var rows []struct {
+		Type  string
+		Total int64
+		Hits  int64
+	}
+	err := r.db.Model(&CacheHit{}).
+		Select("type, COUNT(*) AS total, SUM(CASE WHEN hit THEN 1 ELSE 0 END) AS hits").
+		Group("type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		rates[row.Type] = float64(row.Hits) / float64(row.Total)
+	}
+	return rates, nil
+}
+
 // RouteCallLogRepository provides CRUD operations for RouteCallLog entities
 type RouteCallLogRepository struct {
 	db *gorm.DB
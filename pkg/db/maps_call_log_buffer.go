@@ -0,0 +1,23 @@
+package db
+
+import "time"
+
+// MapsCallLogBuffer collects maps call log entries in memory and writes them with
+// MapsCallLogRepository.CreateBatch, so logging a Google call doesn't add a synchronous write to it.
+// Entries are flushed every interval, or sooner once size are pending.
+type MapsCallLogBuffer struct {
+	*batchBuffer[MapsCallLog]
+}
+
+// NewMapsCallLogBuffer creates a buffer and starts its background flusher. Call Close to stop it.
+func NewMapsCallLogBuffer(repo *MapsCallLogRepository, size int, interval time.Duration) *MapsCallLogBuffer {
+	return &MapsCallLogBuffer{newBatchBuffer(size, interval, "maps call logs", nil, repo.CreateBatch)}
+}
+
+// Record queues a call log entry, stamped with the current time if it has none. It never touches the database.
+func (b *MapsCallLogBuffer) Record(entry MapsCallLog) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	b.add(entry)
+}
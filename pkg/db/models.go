@@ -2,6 +2,8 @@ package db
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Restaurant represents a restaurant from Google Places API
@@ -16,7 +18,28 @@ type Restaurant struct {
 	PrimaryType        string    `gorm:"column:primary_type" json:"primary_type"`
 	PrimaryTypeDisplay string    `gorm:"column:primary_type_display" json:"primary_type_display"`
 	DisplayName        string    `gorm:"column:display_name" json:"display_name"`
+	Types              []string  `gorm:"column:types;serializer:json" json:"types"` // raw Google place types
 	LastUpdated        time.Time `gorm:"column:last_updated;default:CURRENT_TIMESTAMP" json:"last_updated"`
+	// OpeningHours are the restaurant's regular weekly hours, nil when Google has none or they were never fetched
+	OpeningHours *OpeningHours `gorm:"column:opening_hours;serializer:json" json:"-"`
+}
+
+// OpeningHours mirrors the regularOpeningHours object from Google Places API
+type OpeningHours struct {
+	Periods []OpeningPeriod `json:"periods"`
+}
+
+// OpeningPeriod is a single open/close pair. A missing Close means always open.
+type OpeningPeriod struct {
+	Open  OpeningPoint  `json:"open"`
+	Close *OpeningPoint `json:"close,omitempty"`
+}
+
+// OpeningPoint is a time in the week, with Day 0 being Sunday as in Google's API
+type OpeningPoint struct {
+	Day    int `json:"day"`
+	Hour   int `json:"hour"`
+	Minute int `json:"minute"`
 }
 
 // TableName returns the table name for Restaurant
@@ -24,6 +47,18 @@ func (Restaurant) TableName() string {
 	return "restaurants"
 }
 
+// BeforeSave stores missing types as an empty array rather than null, and stamps new rows with the
+// current time so the caller's copy matches what the database default would have stored
+func (r *Restaurant) BeforeSave(tx *gorm.DB) error {
+	if r.Types == nil {
+		r.Types = []string{}
+	}
+	if r.LastUpdated.IsZero() {
+		r.LastUpdated = time.Now()
+	}
+	return nil
+}
+
 // Supercharger represents a Tesla supercharger location
 type Supercharger struct {
 	PlaceID     string    `gorm:"primaryKey;column:place_id" json:"place_id"`
@@ -32,6 +67,20 @@ type Supercharger struct {
 	Latitude    float64   `gorm:"column:latitude" json:"latitude"`
 	Longitude   float64   `gorm:"column:longitude" json:"longitude"`
 	LastUpdated time.Time `gorm:"column:last_updated;default:CURRENT_TIMESTAMP" json:"last_updated"`
+	TimeZone    string    `gorm:"column:time_zone" json:"time_zone"`         // IANA timezone ID, resolved lazily
+	Types       []string  `gorm:"column:types;serializer:json" json:"types"` // raw Google place types
+	// nil when restaurants have never been fetched for this supercharger
+	LastRestaurantUpdate *time.Time `gorm:"column:last_restaurant_update" json:"last_restaurant_update,omitempty"`
+	// the locale and radius restaurants were last searched with, so other searches don't reuse them
+	RestaurantLocale string  `gorm:"column:restaurant_locale" json:"restaurant_locale,omitempty"`
+	RestaurantRadius float64 `gorm:"column:restaurant_radius" json:"restaurant_radius,omitempty"`
+	// Google rating of the charger itself and how many reviews it's based on, only fetched on request
+	Rating           float64    `gorm:"column:rating" json:"rating,omitempty"`
+	ReviewCount      int        `gorm:"column:review_count" json:"review_count,omitempty"`
+	LastReviewUpdate *time.Time `gorm:"column:last_review_update" json:"last_review_update,omitempty"`
+	// MatchConfidence is how much the place looked like a supercharger when it was looked up, from 0 to 1.
+	// Zero for places cached before it was recorded.
+	MatchConfidence float64 `gorm:"column:match_confidence" json:"match_confidence,omitempty"`
 	// this is in order to keep track of IDs that get returned that aren't actually superchargers
 	IsSupercharger bool `gorm:"column:is_supercharger" json:"is_supercharger"`
 }
@@ -41,6 +90,18 @@ func (Supercharger) TableName() string {
 	return "superchargers"
 }
 
+// BeforeSave stores missing types as an empty array rather than null, and stamps new rows with the
+// current time so the caller's copy has the LastUpdated that cache expiry is judged by
+func (s *Supercharger) BeforeSave(tx *gorm.DB) error {
+	if s.Types == nil {
+		s.Types = []string{}
+	}
+	if s.LastUpdated.IsZero() {
+		s.LastUpdated = time.Now()
+	}
+	return nil
+}
+
 // MapsCallLog represents API call logging for maps operations
 type MapsCallLog struct {
 	ID             uint      `gorm:"primaryKey;autoIncrement;column:id" json:"id"`
@@ -52,6 +113,16 @@ type MapsCallLog struct {
 	Details        string    `gorm:"column:details" json:"details"`
 }
 
+// BeforeCreate stamps entries in UTC, matching the CURRENT_TIMESTAMP default, so time range queries compare
+// like with like whatever the server's time zone
+func (l *MapsCallLog) BeforeCreate(tx *gorm.DB) error {
+	if l.Timestamp.IsZero() {
+		l.Timestamp = time.Now()
+	}
+	l.Timestamp = l.Timestamp.UTC()
+	return nil
+}
+
 // CacheHit represents cache hit tracking
 type CacheHit struct {
 	ObjectID    string    `gorm:"primaryKey;column:object_id" json:"object_id"`
@@ -64,6 +135,35 @@ type CacheHit struct {
 type RestaurantWithDistance struct {
 	Restaurant
 	Distance float64 `json:"distance"`
+	// OpenAtArrival is whether the restaurant is open at the supercharger ETA, nil when unknown
+	OpenAtArrival *bool `gorm:"-" json:"open_at_arrival,omitempty"`
+	// BearingFromRoute is the restaurant's direction from the supercharger relative to the route's
+	// direction of travel, in degrees. Zero is straight ahead, positive is to the right, ±180 is behind.
+	BearingFromRoute float64 `gorm:"-" json:"bearing_from_route"`
+	// WalkingDistance and WalkingDuration (seconds) are from the supercharger by foot, nil unless requested
+	WalkingDistance *float64 `gorm:"-" json:"walking_distance,omitempty"`
+	WalkingDuration *int     `gorm:"-" json:"walking_duration,omitempty"`
+}
+
+// SuperchargerWithDistance represents a supercharger with its distance in meters from a point
+type SuperchargerWithDistance struct {
+	Supercharger
+	Distance float64 `json:"distance"`
+}
+
+// DensityCell is one cell of a supercharger density grid
+type DensityCell struct {
+	MinLat float64 `json:"min_lat"`
+	MaxLat float64 `json:"max_lat"`
+	MinLng float64 `json:"min_lng"`
+	MaxLng float64 `json:"max_lng"`
+	Count  int64   `json:"count"`
+}
+
+// Coordinate is a latitude/longitude pair
+type Coordinate struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
 }
 
 // RestaurantSuperchargerMapping represents the mapping between restaurants and superchargers with distance
@@ -89,3 +189,91 @@ type RouteCallLog struct {
 	Error       string    `gorm:"column:error" json:"error"`
 	IPAddress   string    `gorm:"column:ip_address" json:"ip_address"`
 }
+
+// SavedTrip is a snapshot of a planned route shared through a short link
+type SavedTrip struct {
+	Slug        string    `gorm:"primaryKey;column:slug" json:"slug"`
+	Origin      string    `gorm:"column:origin" json:"origin"`
+	Destination string    `gorm:"column:destination" json:"destination"`
+	Params      string    `gorm:"column:params" json:"params"` // encoded /route query the trip was planned with
+	Result      string    `gorm:"column:result" json:"-"`      // JSON encoded route result
+	CreatedAt   time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+	ExpiresAt   time.Time `gorm:"column:expires_at;index" json:"expires_at"`
+}
+
+// TableName returns the table name for SavedTrip
+func (SavedTrip) TableName() string {
+	return "saved_trips"
+}
+
+// RouteAnalysis is a stored route and the settings it was searched with, so the same trip can be
+// searched again without asking the Routes API for it
+type RouteAnalysis struct {
+	RouteKey           string    `gorm:"primaryKey;column:route_key" json:"route_key"` // normalized origin, destination and routing options
+	Origin             string    `gorm:"column:origin" json:"origin"`
+	Destination        string    `gorm:"column:destination" json:"destination"`
+	EncodedPolyline    string    `gorm:"column:encoded_polyline" json:"encoded_polyline"`
+	DistanceMeters     int       `gorm:"column:distance_meters" json:"distance_meters"`
+	DurationSeconds    int       `gorm:"column:duration_seconds" json:"duration_seconds"`
+	TypicalSeconds     int       `gorm:"column:typical_seconds" json:"typical_seconds"`
+	RoutingPreference  string    `gorm:"column:routing_preference" json:"routing_preference"`
+	SearchConfig       string    `gorm:"column:search_config" json:"search_config"` // JSON encoded spatial settings used for the circles
+	SearchRadiusMeters float64   `gorm:"column:search_radius_meters" json:"search_radius_meters"`
+	CircleCount        int       `gorm:"column:circle_count" json:"circle_count"`
+	UpdatedAt          time.Time `gorm:"column:updated_at;index" json:"updated_at"`
+}
+
+// TableName returns the table name for RouteAnalysis
+func (RouteAnalysis) TableName() string {
+	return "route_analyses"
+}
+
+// RejectedPlace is a place ID that was looked up and found not to be a supercharger. Keeping just the ID
+// stops it being looked up again without filling the superchargers table with gas stations.
+type RejectedPlace struct {
+	PlaceID   string    `gorm:"primaryKey;column:id" json:"id"`
+	CheckedAt time.Time `gorm:"column:checked_at" json:"checked_at"`
+}
+
+// TableName returns the table name for RejectedPlace
+func (RejectedPlace) TableName() string {
+	return "rejected_place_ids"
+}
+
+// FetchStats counts how lookups of a place have gone, to spot flaky or defunct sites. It is kept apart
+// from the supercharger row so the history survives the row being invalidated and fetched again.
+type FetchStats struct {
+	PlaceID   string `gorm:"primaryKey;column:place_id" json:"place_id"`
+	Successes int    `gorm:"column:successes;not null;default:0" json:"successes"`
+	Failures  int    `gorm:"column:failures;not null;default:0" json:"failures"`
+	// Reclassifications counts lookups that changed whether the place is a supercharger
+	Reclassifications int `gorm:"column:reclassifications;not null;default:0" json:"reclassifications"`
+	// LastIsSupercharger is what the last successful lookup found, nil before the first one
+	LastIsSupercharger *bool     `gorm:"column:last_is_supercharger" json:"last_is_supercharger"`
+	UpdatedAt          time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// TableName returns the table name for FetchStats
+func (FetchStats) TableName() string {
+	return "supercharger_fetch_stats"
+}
+
+// Reliability is the share of lookups that went cleanly, from 0 to 1. A place with no history scores 1,
+// and a single success counts for as much as the absence of history so one bad lookup isn't damning.
+func (s FetchStats) Reliability() float64 {
+	return float64(s.Successes+1) / float64(s.Successes+s.Failures+s.Reclassifications+1)
+}
+
+// PlaceLocation is the location of a place looked up only for its coordinates, such as an autocomplete
+// result the map is centred on. Superchargers and restaurants already have theirs in their own tables.
+type PlaceLocation struct {
+	PlaceID   string    `gorm:"primaryKey;column:place_id" json:"place_id"`
+	Latitude  float64   `gorm:"column:latitude" json:"latitude"`
+	Longitude float64   `gorm:"column:longitude" json:"longitude"`
+	FetchedAt time.Time `gorm:"column:fetched_at;index" json:"fetched_at"`
+}
+
+// TableName returns the table name for PlaceLocation
+func (PlaceLocation) TableName() string {
+	return "place_locations"
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// NearestInitialRadiusMeters is the radius GetNearest searches first before widening
+const NearestInitialRadiusMeters = 50000.0
+
+// earthRadiusMeters is the mean radius of the Earth
+const earthRadiusMeters = 6371000
+
+// haversineMeters returns the great circle distance between two points
+func haversineMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLng := (lng2 - lng1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// searchBox is a latitude/longitude box holding every point within some radius of a center
+type searchBox struct {
+	// minLng is east of maxLng when the box crosses the antimeridian, covering longitudes from minLng up
+	// to 180 and from -180 up to maxLng
+	minLat, maxLat, minLng, maxLng float64
+	// global is set when the box covers the whole Earth
+	global bool
+}
+
+// boundingBox returns the smallest box holding every point within radius meters of lat/lng. Near the poles
+// every longitude is within reach, so the box spans them all.
+func boundingBox(lat, lng, radius float64) searchBox {
+	angular := radius / earthRadiusMeters
+	dLat := angular * 180 / math.Pi
+	box := searchBox{minLat: lat - dLat, maxLat: lat + dLat}
+
+	if box.minLat <= -90 || box.maxLat >= 90 {
+		// the circle covers a pole
+		box.minLat, box.maxLat = math.Max(box.minLat, -90), math.Min(box.maxLat, 90)
+		box.minLng, box.maxLng = -180, 180
+		box.global = box.minLat == -90 && box.maxLat == 90
+		return box
+	}
+
+	// the widest longitude offset is where the circle touches a meridian, not at the center's latitude
+	dLng := math.Asin(math.Sin(angular)/math.Cos(lat*math.Pi/180)) * 180 / math.Pi
+	box.minLng, box.maxLng = lng-dLng, lng+dLng
+	switch {
+	case box.maxLng-box.minLng >= 360:
+		box.minLng, box.maxLng = -180, 180
+	case box.minLng < -180:
+		box.minLng += 360
+	case box.maxLng > 180:
+		box.maxLng -= 360
+	}
+	return box
+}
+
+// withinLongitudes limits a query to longitudes from minLng east to maxLng. A minLng east of maxLng is a
+// box crossing the antimeridian, such as a map viewport over the Pacific, and matches both ends of the range.
+func withinLongitudes(query *gorm.DB, minLng, maxLng float64) *gorm.DB {
+	if minLng > maxLng {
+		return query.Where("longitude >= ? OR longitude <= ?", minLng, maxLng)
+	}
+	return query.Where("longitude BETWEEN ? AND ?", minLng, maxLng)
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PlaceLocationRepository provides operations for PlaceLocation entities
+type PlaceLocationRepository struct {
+	db *gorm.DB
+}
+
+// NewPlaceLocationRepository creates a new PlaceLocationRepository
+func NewPlaceLocationRepository(db *gorm.DB) *PlaceLocationRepository {
+	return &PlaceLocationRepository{db: db}
+}
+
+// Save records a place's location, replacing any earlier one
+func (r *PlaceLocationRepository) Save(placeID string, latitude, longitude float64) error {
+	return r.db.Save(&PlaceLocation{PlaceID: placeID, Latitude: latitude, Longitude: longitude, FetchedAt: time.Now()}).Error
+}
+
+// GetByID retrieves a place's location by its ID
+func (r *PlaceLocationRepository) GetByID(placeID string) (*PlaceLocation, error) {
+	var location PlaceLocation
+	err := r.db.Where("place_id = ?", placeID).First(&location).Error
+	if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+// DeleteOlderThan deletes locations fetched before cutoff, returning how many were deleted
+func (r *PlaceLocationRepository) DeleteOlderThan(cutoff time.Time) (int64, error) {
+	result := r.db.Where("fetched_at < ?", cutoff).Delete(&PlaceLocation{})
+	return result.RowsAffected, result.Error
+}
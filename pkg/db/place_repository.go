@@ -1,7 +1,15 @@
 package db
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // RestaurantRepository provides CRUD operations for Restaurant entities
@@ -29,14 +37,112 @@ func (r *RestaurantRepository) GetByID(restaurantID string) (*Restaurant, error)
 	return &restaurant, nil
 }
 
-// GetByLocation retrieves restaurants within a bounding box
+// GetByLocation retrieves restaurants within a bounding box. A minLng greater than maxLng is a box
+// crossing the antimeridian.
 func (r *RestaurantRepository) GetByLocation(minLat, maxLat, minLng, maxLng float64) ([]Restaurant, error) {
 	var restaurants []Restaurant
-	err := r.db.Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?",
-		minLat, maxLat, minLng, maxLng).Find(&restaurants).Error
+	query := r.db.Where("latitude BETWEEN ? AND ?", minLat, maxLat)
+	err := withinLongitudes(query, minLng, maxLng).Find(&restaurants).Error
+	return restaurants, err
+}
+
+// PlaceFilter narrows the places returned by GetAllMatching.
+// Zero-valued fields are ignored, so an empty filter matches every place.
+type PlaceFilter struct {
+	PrimaryType string
+	MinRating   float64
+	// Bounding box, only applied when HasBounds is true
+	HasBounds bool
+	MinLat    float64
+	MaxLat    float64
+	MinLng    float64
+	MaxLng    float64
+}
+
+// GetAll retrieves restaurants without any filtering. It pages by offset, which SQLite has to scan past,
+// so use GetAllAfter to walk large tables.
+func (r *RestaurantRepository) GetAll(limit, offset int) ([]Restaurant, error) {
+	return r.GetAllMatching(PlaceFilter{}, limit, offset)
+}
+
+// GetAllMatching retrieves restaurants matching the filter, with the predicates applied in SQL. Like
+// GetAll it pages by offset.
+func (r *RestaurantRepository) GetAllMatching(filter PlaceFilter, limit, offset int) ([]Restaurant, error) {
+	var restaurants []Restaurant
+	query := r.db.Order("place_id ASC")
+
+	if filter.PrimaryType != "" {
+		query = query.Where("primary_type = ?", filter.PrimaryType)
+	}
+	if filter.MinRating > 0 {
+		query = query.Where("rating >= ?", filter.MinRating)
+	}
+	if filter.HasBounds {
+		query = withinLongitudes(query.Where("latitude BETWEEN ? AND ?", filter.MinLat, filter.MaxLat),
+			filter.MinLng, filter.MaxLng)
+	}
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	err := query.Find(&restaurants).Error
+	return restaurants, err
+}
+
+// Search finds up to limit restaurants whose name or display name contains term, ignoring case. Exact
+// matches rank first, then names starting with term, then the rest, with ties going to the better rated
+// and more reviewed. A limit of zero or less returns every match.
+func (r *RestaurantRepository) Search(term string, limit int) ([]Restaurant, error) {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return nil, errors.New("search term is required")
+	}
+	// the term is matched literally, so wildcards in it are escaped
+	escaped := likeEscaper.Replace(term)
+
+	var restaurants []Restaurant
+	query := r.db.
+		Where("name LIKE ? ESCAPE '\\' OR display_name LIKE ? ESCAPE '\\'", "%"+escaped+"%", "%"+escaped+"%").
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL: "CASE WHEN LOWER(name) = LOWER(?) OR LOWER(display_name) = LOWER(?) THEN 0 " +
+				"WHEN name LIKE ? ESCAPE '\\' OR display_name LIKE ? ESCAPE '\\' THEN 1 ELSE 2 END, " +
+				"rating DESC, user_ratings_total DESC, place_id ASC",
+			Vars: []interface{}{term, term, escaped + "%", escaped + "%"},
+		}})
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&restaurants).Error
 	return restaurants, err
 }
 
+// likeEscaper escapes LIKE wildcards with a backslash
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// GetAllAfter retrieves up to limit restaurants in place ID order after the cursor, and returns the cursor
+// for the next page, which is zero once there are no more. Use it over GetAll to walk large tables.
+func (r *RestaurantRepository) GetAllAfter(cursor Cursor, limit int) ([]Restaurant, Cursor, error) {
+	var restaurants []Restaurant
+	if err := afterCursor(r.db, cursor, limit).Find(&restaurants).Error; err != nil {
+		return nil, Cursor{}, err
+	}
+	if len(restaurants) == 0 {
+		return restaurants, Cursor{}, nil
+	}
+	return restaurants, Cursor{PlaceID: restaurants[len(restaurants)-1].PlaceID}, nil
+}
+
+// Count returns the total number of restaurants
+func (r *RestaurantRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&Restaurant{}).Count(&count).Error
+	return count, err
+}
+
 // SuperchargerRepository provides CRUD operations for Supercharger entities
 type SuperchargerRepository struct {
 	db *gorm.DB
@@ -52,6 +158,18 @@ func (r *SuperchargerRepository) Create(supercharger *Supercharger) error {
 	return r.db.Create(supercharger).Error
 }
 
+// createBatchSize is the number of rows per INSERT in CreateBatch. SQLite historically limits
+// a statement to 999 bound variables, so 50 rows leaves room for up to 19 columns per row.
+const createBatchSize = 50
+
+// CreateBatch creates many superchargers, chunking the inserts to stay under SQLite's variable limit
+func (r *SuperchargerRepository) CreateBatch(superchargers []Supercharger) error {
+	if len(superchargers) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(superchargers, createBatchSize).Error
+}
+
 // GetByID retrieves a supercharger by its ID
 func (r *SuperchargerRepository) GetByID(placeID string) (*Supercharger, error) {
 	var supercharger Supercharger
@@ -62,14 +180,233 @@ func (r *SuperchargerRepository) GetByID(placeID string) (*Supercharger, error)
 	return &supercharger, nil
 }
 
-// GetByLocation retrieves superchargers within a bounding box
+// batchGetSize is how many IDs BatchGetByIDs puts in one IN clause, well under SQLite's 999 variable limit
+const batchGetSize = 500
+
+// BatchGetByIDs retrieves the superchargers with the given IDs in as few queries as possible, keyed by
+// PlaceID. IDs that aren't stored are missing from the map rather than being an error.
+func (r *SuperchargerRepository) BatchGetByIDs(ids []string) (map[string]*Supercharger, error) {
+	found := make(map[string]*Supercharger, len(ids))
+	for start := 0; start < len(ids); start += batchGetSize {
+		var superchargers []Supercharger
+		chunk := ids[start:min(start+batchGetSize, len(ids))]
+		if err := r.db.Where("place_id IN ?", chunk).Find(&superchargers).Error; err != nil {
+			return nil, err
+		}
+		for i := range superchargers {
+			found[superchargers[i].PlaceID] = &superchargers[i]
+		}
+	}
+	return found, nil
+}
+
+// GetAllAfter retrieves up to limit superchargers, confirmed or not, in place ID order after the cursor,
+// and returns the cursor for the next page, which is zero once there are no more
+func (r *SuperchargerRepository) GetAllAfter(cursor Cursor, limit int) ([]Supercharger, Cursor, error) {
+	var superchargers []Supercharger
+	if err := afterCursor(r.db, cursor, limit).Find(&superchargers).Error; err != nil {
+		return nil, Cursor{}, err
+	}
+	if len(superchargers) == 0 {
+		return superchargers, Cursor{}, nil
+	}
+	return superchargers, Cursor{PlaceID: superchargers[len(superchargers)-1].PlaceID}, nil
+}
+
+// UpdateTimeZone sets the cached timezone for a supercharger
+func (r *SuperchargerRepository) UpdateTimeZone(placeID, timeZone string) error {
+	return r.db.Model(&Supercharger{}).Where("place_id = ?", placeID).Update("time_zone", timeZone).Error
+}
+
+// GetMissingAddress returns up to limit confirmed superchargers with no address
+func (r *SuperchargerRepository) GetMissingAddress(limit int) ([]Supercharger, error) {
+	var superchargers []Supercharger
+	err := r.db.Where("(address = '' OR address IS NULL) AND is_supercharger = TRUE").
+		Order("place_id").Limit(limit).Find(&superchargers).Error
+	return superchargers, err
+}
+
+// UpdateAddresses sets the address of each supercharger keyed by place ID, in a single transaction
+func (r *SuperchargerRepository) UpdateAddresses(addresses map[string]string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for placeID, address := range addresses {
+			if err := tx.Model(&Supercharger{}).Where("place_id = ?", placeID).Update("address", address).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateRating stores the Google rating and review count for a supercharger
+func (r *SuperchargerRepository) UpdateRating(placeID string, rating float64, reviewCount int) error {
+	return r.db.Model(&Supercharger{}).Where("place_id = ?", placeID).Updates(map[string]interface{}{
+		"rating":             rating,
+		"review_count":       reviewCount,
+		"last_review_update": time.Now(),
+	}).Error
+}
+
+// GetByLocation retrieves superchargers within a bounding box. A minLng greater than maxLng is a box
+// crossing the antimeridian.
 func (r *SuperchargerRepository) GetByLocation(minLat, maxLat, minLng, maxLng float64) ([]Supercharger, error) {
 	var superchargers []Supercharger
-	err := r.db.Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ? and is_supercharger = TRUE",
-		minLat, maxLat, minLng, maxLng).Find(&superchargers).Error
+	query := r.db.Where("latitude BETWEEN ? AND ? and is_supercharger = TRUE", minLat, maxLat)
+	err := withinLongitudes(query, minLng, maxLng).Find(&superchargers).Error
 	return superchargers, err
 }
 
+// GetNearest returns up to limit confirmed superchargers closest to a point, nearest first. It searches
+// a box around the point that starts NearestInitialRadiusMeters across and doubles until it holds limit
+// superchargers within the radius, so dense areas only read nearby rows.
+func (r *SuperchargerRepository) GetNearest(lat, lng float64, limit int) ([]SuperchargerWithDistance, error) {
+	if limit < 1 {
+		return nil, fmt.Errorf("limit must be positive, got %d", limit)
+	}
+	if math.Abs(lat) > 90 || math.Abs(lng) > 180 {
+		return nil, fmt.Errorf("point %v,%v is out of range", lat, lng)
+	}
+
+	for radius := NearestInitialRadiusMeters; ; radius *= 2 {
+		box := boundingBox(lat, lng, radius)
+		query := r.db.Where("latitude BETWEEN ? AND ? and is_supercharger = TRUE", box.minLat, box.maxLat)
+		query = withinLongitudes(query, box.minLng, box.maxLng)
+		var superchargers []Supercharger
+		if err := query.Find(&superchargers).Error; err != nil {
+			return nil, err
+		}
+
+		nearest := make([]SuperchargerWithDistance, len(superchargers))
+		within := 0
+		for i, sc := range superchargers {
+			nearest[i] = SuperchargerWithDistance{Supercharger: sc, Distance: haversineMeters(lat, lng, sc.Latitude, sc.Longitude)}
+			if nearest[i].Distance <= radius {
+				within++
+			}
+		}
+
+		// rows in the box's corners are further than the radius, and closer ones may lie outside the box,
+		// so only those within the radius are known to be nearest
+		if within >= limit || box.global {
+			sort.Slice(nearest, func(i, j int) bool { return nearest[i].Distance < nearest[j].Distance })
+			if len(nearest) > limit {
+				nearest = nearest[:limit]
+			}
+			return nearest, nil
+		}
+	}
+}
+
+// ForEachInLocation passes superchargers within a bounding box to fn in batches of batchSize,
+// so dense areas can be streamed without loading every row at once. A minLng greater than maxLng is a
+// box crossing the antimeridian.
+func (r *SuperchargerRepository) ForEachInLocation(minLat, maxLat, minLng, maxLng float64, batchSize int, fn func([]Supercharger) error) error {
+	var batch []Supercharger
+	query := r.db.Where("latitude BETWEEN ? AND ? and is_supercharger = TRUE", minLat, maxLat)
+	return withinLongitudes(query, minLng, maxLng).FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// ForEachConfirmed passes every confirmed supercharger last updated at or after updatedSince to fn
+// in batches of batchSize. A zero updatedSince includes them all.
+func (r *SuperchargerRepository) ForEachConfirmed(updatedSince time.Time, batchSize int, fn func([]Supercharger) error) error {
+	var batch []Supercharger
+	query := r.db.Where("is_supercharger = TRUE")
+	if !updatedSince.IsZero() {
+		query = query.Where("last_updated >= ?", updatedSince)
+	}
+	return query.FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// DensityGrid counts confirmed superchargers per cell of a grid laid over a bounding box.
+// Cells are cellDegrees square starting at minLat/minLng, and empty cells are omitted.
+func (r *SuperchargerRepository) DensityGrid(minLat, maxLat, minLng, maxLng, cellDegrees float64) ([]DensityCell, error) {
+	if cellDegrees <= 0 {
+		return nil, fmt.Errorf("cell size must be positive, got %v", cellDegrees)
+	}
+
+	// rows are inside the box so offsets are non-negative and the cast floors them
+	var rows []struct {
+		CellY int
+		CellX int
+		Count int64
+	}
+	err := r.db.Model(&Supercharger{}).
+		Select("CAST((latitude - ?) / ? AS INTEGER) AS cell_y, CAST((longitude - ?) / ? AS INTEGER) AS cell_x, COUNT(*) AS count",
+			minLat, cellDegrees, minLng, cellDegrees).
+		Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ? and is_supercharger = TRUE",
+			minLat, maxLat, minLng, maxLng).
+		Group("cell_y, cell_x").
+		Order("cell_y, cell_x").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	cells := make([]DensityCell, len(rows))
+	for i, row := range rows {
+		cells[i] = DensityCell{
+			MinLat: minLat + float64(row.CellY)*cellDegrees,
+			MaxLat: minLat + float64(row.CellY+1)*cellDegrees,
+			MinLng: minLng + float64(row.CellX)*cellDegrees,
+			MaxLng: minLng + float64(row.CellX+1)*cellDegrees,
+			Count:  row.Count,
+		}
+	}
+	return cells, nil
+}
+
+// coverageBatchSize is how many superchargers are loaded at a time while building the coverage hull
+const coverageBatchSize = 1000
+
+// CoverageHull returns the convex hull of all confirmed superchargers, counter-clockwise and without
+// repeating the first point. Fewer than three points are returned as is when there aren't enough
+// distinct superchargers to enclose an area.
+func (r *SuperchargerRepository) CoverageHull() ([]Coordinate, error) {
+	var hull []Coordinate
+	var batch []Supercharger
+	// only the running hull is kept between batches, so memory doesn't grow with the table
+	err := r.db.Select("place_id, latitude, longitude").
+		Where("is_supercharger = TRUE").
+		FindInBatches(&batch, coverageBatchSize, func(tx *gorm.DB, _ int) error {
+			points := make([]Coordinate, 0, len(hull)+len(batch))
+			points = append(points, hull...)
+			for _, sc := range batch {
+				points = append(points, Coordinate{Latitude: sc.Latitude, Longitude: sc.Longitude})
+			}
+			hull = convexHull(points)
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	return hull, nil
+}
+
+// Count returns the total number of cached places, including those that turned out not to be superchargers
+func (r *SuperchargerRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&Supercharger{}).Count(&count).Error
+	return count, err
+}
+
+// CountConfirmed returns the number of cached places confirmed to be superchargers
+func (r *SuperchargerRepository) CountConfirmed() (int64, error) {
+	var count int64
+	err := r.db.Model(&Supercharger{}).Where("is_supercharger = TRUE").Count(&count).Error
+	return count, err
+}
+
+// CountRestaurantMappings returns the number of restaurant to supercharger associations
+func (r *SuperchargerRepository) CountRestaurantMappings() (int64, error) {
+	var count int64
+	err := r.db.Model(&RestaurantSuperchargerMapping{}).Count(&count).Error
+	return count, err
+}
+
 // GetRestaurantsForSupercharger retrieves all restaurants associated with a supercharger with distances
 func (r *SuperchargerRepository) GetRestaurantsForSupercharger(superchargerID string) ([]RestaurantWithDistance, error) {
 	var results []struct {
@@ -95,6 +432,113 @@ func (r *SuperchargerRepository) GetRestaurantsForSupercharger(superchargerID st
 	return restaurantsWithDistance, err
 }
 
+// GetTopRestaurants retrieves up to n restaurants near a supercharger rated at least minRating, best first.
+// Restaurants are ranked by distance stretched by how far their rating falls short of five stars, so an
+// unrated restaurant ranks as if it were twice as far away. An n of zero or less returns them all.
+func (r *SuperchargerRepository) GetTopRestaurants(superchargerID string, n int, minRating float64) ([]RestaurantWithDistance, error) {
+	var results []struct {
+		Restaurant
+		Distance float64 `json:"distance"`
+	}
+
+	query := r.db.Table("restaurants").
+		Select("restaurants.*, restaurant_supercharger_mappings.distance").
+		Joins("JOIN restaurant_supercharger_mappings ON restaurants.place_id = restaurant_supercharger_mappings.restaurant_id").
+		Where("restaurant_supercharger_mappings.supercharger_id = ?", superchargerID).
+		Order("restaurant_supercharger_mappings.distance * (2 - MIN(MAX(restaurants.rating, 0), 5) / 5.0) ASC, restaurant_supercharger_mappings.distance ASC")
+	if minRating > 0 {
+		query = query.Where("restaurants.rating >= ?", minRating)
+	}
+	if n > 0 {
+		query = query.Limit(n)
+	}
+	err := query.Scan(&results).Error
+
+	restaurantsWithDistance := make([]RestaurantWithDistance, len(results))
+	for i, result := range results {
+		restaurantsWithDistance[i] = RestaurantWithDistance{
+			Restaurant: result.Restaurant,
+			Distance:   result.Distance,
+		}
+	}
+
+	return restaurantsWithDistance, err
+}
+
+// restaurantRank orders restaurants the same way GetTopRestaurants does, lowest first
+func restaurantRank(r RestaurantWithDistance) float64 {
+	return r.Distance * (2 - math.Min(math.Max(r.Rating, 0), 5)/5)
+}
+
+// TopRestaurants returns the best n restaurants, ranked as GetTopRestaurants ranks them, in their original
+// order. Restaurants are returned unchanged if there are n or fewer, or n is zero or less.
+func TopRestaurants(restaurants []RestaurantWithDistance, n int) []RestaurantWithDistance {
+	if n <= 0 || len(restaurants) <= n {
+		return restaurants
+	}
+
+	order := make([]int, len(restaurants))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := restaurants[order[i]], restaurants[order[j]]
+		if rankA, rankB := restaurantRank(a), restaurantRank(b); rankA != rankB {
+			return rankA < rankB
+		}
+		return a.Distance < b.Distance
+	})
+	keep := make([]bool, len(restaurants))
+	for _, i := range order[:n] {
+		keep[i] = true
+	}
+
+	top := make([]RestaurantWithDistance, 0, n)
+	for i, r := range restaurants {
+		if keep[i] {
+			top = append(top, r)
+		}
+	}
+	return top
+}
+
+// TrimRestaurantMappings removes restaurant mappings from superchargers with more than max of them,
+// keeping the best max as ranked by GetTopRestaurants. It returns how many mappings were removed.
+func (r *SuperchargerRepository) TrimRestaurantMappings(max int) (int64, error) {
+	if max < 1 {
+		return 0, fmt.Errorf("max must be positive, got %d", max)
+	}
+
+	var superchargerIDs []string
+	err := r.db.Model(&RestaurantSuperchargerMapping{}).
+		Group("supercharger_id").
+		Having("COUNT(*) > ?", max).
+		Pluck("supercharger_id", &superchargerIDs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var trimmed int64
+	for _, superchargerID := range superchargerIDs {
+		top, err := r.GetTopRestaurants(superchargerID, max, 0)
+		if err != nil {
+			return trimmed, fmt.Errorf("failed to rank restaurants for %s: %w", superchargerID, err)
+		}
+		keep := make([]string, len(top))
+		for i, restaurant := range top {
+			keep[i] = restaurant.PlaceID
+		}
+
+		result := r.db.Where("supercharger_id = ? AND restaurant_id NOT IN ?", superchargerID, keep).
+			Delete(&RestaurantSuperchargerMapping{})
+		if result.Error != nil {
+			return trimmed, fmt.Errorf("failed to trim restaurants for %s: %w", superchargerID, result.Error)
+		}
+		trimmed += result.RowsAffected
+	}
+	return trimmed, nil
+}
+
 // AddSuperchargerWithRestaurants creates a supercharger and associates it with multiple restaurants with distances
 func (r *SuperchargerRepository) AddSuperchargerWithRestaurants(supercharger *Supercharger, restaurants []RestaurantWithDistance) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
@@ -103,45 +547,93 @@ func (r *SuperchargerRepository) AddSuperchargerWithRestaurants(supercharger *Su
 			return err
 		}
 
-		// Create restaurants if they don't exist
-		for _, restaurant := range restaurants {
-			var existing Restaurant
-			if err := tx.Where("place_id = ?", restaurant.PlaceID).First(&existing).Error; err != nil {
-				if err == gorm.ErrRecordNotFound {
-					// Restaurant doesn't exist, create it
-					newRestaurant := Restaurant{
-						PlaceID:            restaurant.PlaceID,
-						Name:               restaurant.Name,
-						Address:            restaurant.Address,
-						Latitude:           restaurant.Latitude,
-						Longitude:          restaurant.Longitude,
-						Rating:             restaurant.Rating,
-						UserRatingsTotal:   restaurant.UserRatingsTotal,
-						PrimaryType:        restaurant.PrimaryType,
-						PrimaryTypeDisplay: restaurant.PrimaryTypeDisplay,
-						DisplayName:        restaurant.DisplayName,
-						LastUpdated:        restaurant.LastUpdated,
-					}
-					if err := tx.Create(&newRestaurant).Error; err != nil {
-						return err
-					}
-				} else {
-					return err
-				}
+		return addRestaurantMappings(tx, supercharger.PlaceID, restaurants)
+	})
+}
+
+// Refresh saves every column of an existing supercharger, such as one looked up again after expiring,
+// and removes its restaurant mappings too if dropRestaurants is set
+func (r *SuperchargerRepository) Refresh(supercharger *Supercharger, dropRestaurants bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if dropRestaurants {
+			if err := tx.Where("supercharger_id = ?", supercharger.PlaceID).Delete(&RestaurantSuperchargerMapping{}).Error; err != nil {
+				return err
 			}
+		}
+		return tx.Save(supercharger).Error
+	})
+}
+
+// SetRestaurantsForSupercharger replaces an existing supercharger's restaurants and records
+// when, in which locale and within what radius they were searched for
+func (r *SuperchargerRepository) SetRestaurantsForSupercharger(superchargerID string, restaurants []RestaurantWithDistance, locale string, radius float64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("supercharger_id = ?", superchargerID).Delete(&RestaurantSuperchargerMapping{}).Error; err != nil {
+			return err
+		}
+
+		if err := addRestaurantMappings(tx, superchargerID, restaurants); err != nil {
+			return err
+		}
+
+		return tx.Model(&Supercharger{}).Where("place_id = ?", superchargerID).Updates(map[string]interface{}{
+			"last_restaurant_update": time.Now(),
+			"restaurant_locale":      locale,
+			"restaurant_radius":      radius,
+		}).Error
+	})
+}
 
-			// Create the mapping with distance
-			mapping := RestaurantSuperchargerMapping{
-				RestaurantID:   restaurant.PlaceID,
-				SuperchargerID: supercharger.PlaceID,
-				Distance:       restaurant.Distance,
+// addRestaurantMappings creates any missing restaurants and maps them to the supercharger
+func addRestaurantMappings(tx *gorm.DB, superchargerID string, restaurants []RestaurantWithDistance) error {
+	for _, restaurant := range restaurants {
+		var existing Restaurant
+		if err := tx.Where("place_id = ?", restaurant.PlaceID).First(&existing).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				// Restaurant doesn't exist, create it
+				newRestaurant := Restaurant{
+					PlaceID:            restaurant.PlaceID,
+					Name:               restaurant.Name,
+					Address:            restaurant.Address,
+					Latitude:           restaurant.Latitude,
+					Longitude:          restaurant.Longitude,
+					Rating:             restaurant.Rating,
+					UserRatingsTotal:   restaurant.UserRatingsTotal,
+					PrimaryType:        restaurant.PrimaryType,
+					PrimaryTypeDisplay: restaurant.PrimaryTypeDisplay,
+					DisplayName:        restaurant.DisplayName,
+					Types:              restaurant.Types,
+					OpeningHours:       restaurant.OpeningHours,
+					LastUpdated:        restaurant.LastUpdated,
+				}
+				if err := tx.Create(&newRestaurant).Error; err != nil {
+					return err
+				}
+			} else {
+				return err
 			}
-			err := tx.Create(&mapping).Error
-			if err != nil {
+		} else if restaurant.UserRatingsTotal > 0 || restaurant.OpeningHours != nil {
+			// refresh ratings and hours on restaurants stored before they were fetched, or that have changed since
+			if err := tx.Model(&existing).Select("rating", "user_ratings_total", "opening_hours").Updates(&Restaurant{
+				Rating:           restaurant.Rating,
+				UserRatingsTotal: restaurant.UserRatingsTotal,
+				OpeningHours:     restaurant.OpeningHours,
+			}).Error; err != nil {
 				return err
 			}
 		}
 
-		return nil
-	})
+		// Create the mapping with distance
+		mapping := RestaurantSuperchargerMapping{
+			RestaurantID:   restaurant.PlaceID,
+			SuperchargerID: superchargerID,
+			Distance:       restaurant.Distance,
+		}
+		err := tx.Create(&mapping).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RejectedPlaceRepository provides operations for RejectedPlace entities
+type RejectedPlaceRepository struct {
+	db *gorm.DB
+}
+
+// NewRejectedPlaceRepository creates a new RejectedPlaceRepository
+func NewRejectedPlaceRepository(db *gorm.DB) *RejectedPlaceRepository {
+	return &RejectedPlaceRepository{db: db}
+}
+
+// Add records a place as not being a supercharger, refreshing its check time if it was already recorded
+func (r *RejectedPlaceRepository) Add(placeID string) error {
+	return r.db.Save(&RejectedPlace{PlaceID: placeID, CheckedAt: time.Now()}).Error
+}
+
+// GetByID retrieves a rejected place by its ID
+func (r *RejectedPlaceRepository) GetByID(placeID string) (*RejectedPlace, error) {
+	var rejected RejectedPlace
+	err := r.db.Where("id = ?", placeID).First(&rejected).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rejected, nil
+}
+
+// Delete removes a rejected place so it will be looked up again
+func (r *RejectedPlaceRepository) Delete(placeID string) error {
+	return r.db.Where("id = ?", placeID).Delete(&RejectedPlace{}).Error
+}
+
+// Count returns the number of rejected places
+func (r *RejectedPlaceRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&RejectedPlace{}).Count(&count).Error
+	return count, err
+}
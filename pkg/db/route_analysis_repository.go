@@ -0,0 +1,38 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RouteAnalysisRepository provides operations for stored RouteAnalysis entities
+type RouteAnalysisRepository struct {
+	db *gorm.DB
+}
+
+// NewRouteAnalysisRepository creates a new RouteAnalysisRepository
+func NewRouteAnalysisRepository(db *gorm.DB) *RouteAnalysisRepository {
+	return &RouteAnalysisRepository{db: db}
+}
+
+// Save creates or replaces the analysis stored under its route key
+func (r *RouteAnalysisRepository) Save(analysis *RouteAnalysis) error {
+	return r.db.Save(analysis).Error
+}
+
+// GetByKey retrieves the analysis for a route key if it was stored at or after since
+func (r *RouteAnalysisRepository) GetByKey(routeKey string, since time.Time) (*RouteAnalysis, error) {
+	var analysis RouteAnalysis
+	err := r.db.Where("route_key = ? AND updated_at >= ?", routeKey, since).First(&analysis).Error
+	if err != nil {
+		return nil, err
+	}
+	return &analysis, nil
+}
+
+// DeleteOlderThan deletes analyses stored before cutoff and returns how many were removed
+func (r *RouteAnalysisRepository) DeleteOlderThan(cutoff time.Time) (int64, error) {
+	result := r.db.Where("updated_at < ?", cutoff).Delete(&RouteAnalysis{})
+	return result.RowsAffected, result.Error
+}
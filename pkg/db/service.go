@@ -1,28 +1,40 @@
 package db
 
 import (
+	"fmt"
+
 	"gorm.io/gorm"
 )
 
 // Service provides a unified interface to all database operations
 type Service struct {
-	Restaurant   *RestaurantRepository
-	Supercharger *SuperchargerRepository
-	MapsCallLog  *MapsCallLogRepository
-	CacheHit     *CacheHitRepository
-	RouteCallLog *RouteCallLogRepository
-	db           *gorm.DB
+	Restaurant    *RestaurantRepository
+	Supercharger  *SuperchargerRepository
+	MapsCallLog   *MapsCallLogRepository
+	CacheHit      *CacheHitRepository
+	RouteCallLog  *RouteCallLogRepository
+	SavedTrip     *SavedTripRepository
+	RouteAnalysis *RouteAnalysisRepository
+	RejectedPlace *RejectedPlaceRepository
+	FetchStats    *FetchStatsRepository
+	PlaceLocation *PlaceLocationRepository
+	db            *gorm.DB
 }
 
 // NewService creates a new database service with all repositories
 func NewService(db *gorm.DB) *Service {
 	return &Service{
-		Restaurant:   NewRestaurantRepository(db),
-		Supercharger: NewSuperchargerRepository(db),
-		MapsCallLog:  NewMapsCallLogRepository(db),
-		CacheHit:     NewCacheHitRepository(db),
-		RouteCallLog: NewRouteCallLogRepository(db),
-		db:           db,
+		Restaurant:    NewRestaurantRepository(db),
+		Supercharger:  NewSuperchargerRepository(db),
+		MapsCallLog:   NewMapsCallLogRepository(db),
+		CacheHit:      NewCacheHitRepository(db),
+		RouteCallLog:  NewRouteCallLogRepository(db),
+		SavedTrip:     NewSavedTripRepository(db),
+		RouteAnalysis: NewRouteAnalysisRepository(db),
+		RejectedPlace: NewRejectedPlaceRepository(db),
+		FetchStats:    NewFetchStatsRepository(db),
+		PlaceLocation: NewPlaceLocationRepository(db),
+		db:            db,
 	}
 }
 
@@ -34,6 +46,16 @@ func GetDefaultService() *Service {
 	return NewService(DB)
 }
 
+// Close closes the connection of a service from InitializeNew. Services from GetDefaultService share
+// the global connection, which is closed with the package-level Close instead.
+func (s *Service) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
 // Transaction executes a function within a database transaction
 func (s *Service) Transaction(fn func(*Service) error) error {
 	return s.db.Transaction(func(tx *gorm.DB) error {
@@ -41,3 +63,101 @@ func (s *Service) Transaction(fn func(*Service) error) error {
 		return fn(txService)
 	})
 }
+
+// InvalidateSupercharger removes a cached supercharger and its restaurant mappings so the
+// next request re-fetches it from Google. Returns gorm.ErrRecordNotFound if it isn't cached.
+func (s *Service) InvalidateSupercharger(placeID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("supercharger_id = ?", placeID).Delete(&RestaurantSuperchargerMapping{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("place_id = ?", placeID).Delete(&Supercharger{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// InvalidateRegion removes every cached supercharger within a bounding box, along with their
+// restaurant mappings, and returns how many were removed.
+func (s *Service) InvalidateRegion(minLat, maxLat, minLng, maxLng float64) (int, error) {
+	var removed int
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var placeIDs []string
+		query := tx.Model(&Supercharger{}).Where("latitude BETWEEN ? AND ?", minLat, maxLat)
+		err := withinLongitudes(query, minLng, maxLng).Pluck("place_id", &placeIDs).Error
+		if err != nil {
+			return err
+		}
+		if len(placeIDs) == 0 {
+			return nil
+		}
+
+		if err := tx.Where("supercharger_id IN ?", placeIDs).Delete(&RestaurantSuperchargerMapping{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("place_id IN ?", placeIDs).Delete(&Supercharger{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = int(result.RowsAffected)
+		return nil
+	})
+	return removed, err
+}
+
+// MoveRejectedPlaces moves places cached as full supercharger rows with is_supercharger=false into the
+// rejected place table, keeping the time they were checked, and returns how many were moved.
+func (s *Service) MoveRejectedPlaces() (int64, error) {
+	var moved int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT OR REPLACE INTO rejected_place_ids (id, checked_at)
+			SELECT place_id, last_updated FROM superchargers WHERE is_supercharger = FALSE`).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("supercharger_id IN (?)", tx.Model(&Supercharger{}).Select("place_id").Where("is_supercharger = FALSE")).
+			Delete(&RestaurantSuperchargerMapping{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("is_supercharger = FALSE").Delete(&Supercharger{})
+		if result.Error != nil {
+			return result.Error
+		}
+		moved = result.RowsAffected
+		return nil
+	})
+	return moved, err
+}
+
+// Maintain reclaims free pages and refreshes query planner statistics after bulk writes or deletes.
+// VACUUM can't run inside a transaction and briefly needs exclusive access to the database, so this
+// should be called on the top-level service while traffic is low.
+func (s *Service) Maintain() error {
+	if _, inTx := s.db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return fmt.Errorf("maintenance cannot run inside a transaction")
+	}
+
+	// pin a single connection so the statements run in order on the same handle
+	return s.db.Connection(func(conn *gorm.DB) error {
+		for _, stmt := range []string{
+			"VACUUM",
+			"ANALYZE",
+			// VACUUM writes through the WAL, so fold it back into the main file
+			"PRAGMA wal_checkpoint(TRUNCATE)",
+		} {
+			if err := conn.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to run %s: %w", stmt, err)
+			}
+		}
+		return nil
+	})
+}
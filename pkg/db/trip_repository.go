@@ -0,0 +1,38 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SavedTripRepository provides CRUD operations for SavedTrip entities
+type SavedTripRepository struct {
+	db *gorm.DB
+}
+
+// NewSavedTripRepository creates a new SavedTripRepository
+func NewSavedTripRepository(db *gorm.DB) *SavedTripRepository {
+	return &SavedTripRepository{db: db}
+}
+
+// Create creates a new saved trip
+func (r *SavedTripRepository) Create(trip *SavedTrip) error {
+	return r.db.Create(trip).Error
+}
+
+// GetBySlug retrieves a saved trip by its slug. Expired trips are treated as not found
+func (r *SavedTripRepository) GetBySlug(slug string) (*SavedTrip, error) {
+	var trip SavedTrip
+	err := r.db.Where("slug = ? AND expires_at > ?", slug, time.Now()).First(&trip).Error
+	if err != nil {
+		return nil, err
+	}
+	return &trip, nil
+}
+
+// DeleteExpired deletes trips that expired before the given time and returns how many were removed
+func (r *SavedTripRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at <= ?", now).Delete(&SavedTrip{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,126 @@
+package maps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+// addressUpdateBatchSize is how many filled addresses are written to the database at a time
+const addressUpdateBatchSize = 100
+
+// filledAddress is the outcome of reverse geocoding one supercharger
+type filledAddress struct {
+	placeID string
+	address string
+}
+
+// BatchFillMissingAddresses reverse geocodes up to limit confirmed superchargers that have no address,
+// using concurrency workers, and returns how many were filled. Superchargers that can't be geocoded are
+// logged and left blank. Every call is recorded in the maps call log. If ctx is cancelled the addresses
+// found so far are still saved and the context error is returned with the count.
+func BatchFillMissingAddresses(ctx context.Context, service *db.Service, apiKey string, concurrency, limit int) (int, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	superchargers, err := service.Supercharger.GetMissingAddress(limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get superchargers missing addresses: %w", err)
+	}
+	if len(superchargers) == 0 {
+		return 0, nil
+	}
+
+	jobs := make(chan db.Supercharger)
+	results := make(chan filledAddress)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sc := range jobs {
+				location := Center{Latitude: sc.Latitude, Longitude: sc.Longitude}
+				address, err := ReverseGeocode(ctx, apiKey, location, Locale{})
+				logGeocodeCall(service, sc.PlaceID, err)
+				if err != nil {
+					if !errors.Is(err, ErrAddressNotFound) && ctx.Err() == nil {
+						log.Printf("Warning: failed to reverse geocode supercharger %s: %v", sc.PlaceID, err)
+					}
+					continue
+				}
+				results <- filledAddress{placeID: sc.PlaceID, address: address}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, sc := range superchargers {
+			select {
+			case jobs <- sc:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	filled := 0
+	pending := make(map[string]string, addressUpdateBatchSize)
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		if err := service.Supercharger.UpdateAddresses(pending); err != nil {
+			return fmt.Errorf("failed to save addresses: %w", err)
+		}
+		filled += len(pending)
+		pending = make(map[string]string, addressUpdateBatchSize)
+		return nil
+	}
+
+	var saveErr error
+	for result := range results {
+		// keep draining after a failed save so the workers can finish
+		if saveErr != nil {
+			continue
+		}
+		pending[result.placeID] = result.address
+		if len(pending) >= addressUpdateBatchSize {
+			saveErr = flush()
+		}
+	}
+	if saveErr != nil {
+		return filled, saveErr
+	}
+	if err := flush(); err != nil {
+		return filled, err
+	}
+
+	return filled, ctx.Err()
+}
+
+// logGeocodeCall records a reverse geocoding call for a supercharger in the maps call log
+func logGeocodeCall(service *db.Service, placeID string, callErr error) {
+	entry := &db.MapsCallLog{
+		SKU:            SKUGeocoding,
+		SuperchargerID: &placeID,
+		Details:        "reverse geocode missing address",
+	}
+	if callErr != nil {
+		entry.Error = callErr.Error()
+	}
+	if err := service.MapsCallLog.Create(entry); err != nil {
+		log.Printf("Warning: failed to log geocoding call for %s: %v", placeID, err)
+	}
+}
@@ -0,0 +1,74 @@
+package maps
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestBatchFillMissingAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		latlng := r.URL.Query().Get("latlng")
+		// the charger in the middle of nowhere has no address
+		if strings.HasPrefix(latlng, "0.000000") {
+			w.Write([]byte(`{"status":"ZERO_RESULTS","results":[]}`))
+			return
+		}
+		fmt.Fprintf(w, `{"status":"OK","results":[{"formatted_address":"Near %s"}]}`, latlng)
+	}))
+	defer server.Close()
+
+	originalEndpoint := geocodeEndpoint
+	defer func() { geocodeEndpoint = originalEndpoint }()
+	geocodeEndpoint = server.URL
+
+	broker := newTestDB(t)
+	scs := []db.Supercharger{
+		{PlaceID: "addr_blank1", Latitude: 37, Longitude: -122, IsSupercharger: true},
+		{PlaceID: "addr_blank2", Latitude: 38, Longitude: -121, IsSupercharger: true},
+		{PlaceID: "addr_nowhere", Latitude: 0, Longitude: 0, IsSupercharger: true},
+		{PlaceID: "addr_set", Address: "1 Main St", Latitude: 39, Longitude: -120, IsSupercharger: true},
+		{PlaceID: "addr_unconfirmed", Latitude: 40, Longitude: -119},
+	}
+	if err := broker.Supercharger.CreateBatch(scs); err != nil {
+		t.Fatalf("Failed to create superchargers: %v", err)
+	}
+
+	filled, err := BatchFillMissingAddresses(context.Background(), broker, "key", 2, 10)
+	if err != nil {
+		t.Fatalf("BatchFillMissingAddresses failed: %v", err)
+	}
+	if filled != 2 {
+		t.Errorf("Expected 2 addresses filled, got %d", filled)
+	}
+
+	sc, err := broker.Supercharger.GetByID("addr_blank1")
+	if err != nil || sc.Address != "Near 37.000000,-122.000000" {
+		t.Errorf("Expected addr_blank1 to be filled, got %q (err: %v)", sc.Address, err)
+	}
+	sc, err = broker.Supercharger.GetByID("addr_set")
+	if err != nil || sc.Address != "1 Main St" {
+		t.Errorf("Expected existing address to be kept, got %q (err: %v)", sc.Address, err)
+	}
+
+	// only the three confirmed blank chargers were geocoded
+	logs, total, err := broker.MapsCallLog.Find(db.MapsCallLogFilter{SKU: SKUGeocoding}, 10, 0)
+	if err != nil || total != 3 {
+		t.Fatalf("Expected 3 geocoding calls logged, got %d (err: %v)", total, err)
+	}
+	for _, entry := range logs {
+		if *entry.SuperchargerID == "addr_nowhere" && entry.Error == "" {
+			t.Error("Expected the failed lookup to be logged with its error")
+		}
+	}
+
+	// the remaining blank charger is retried but still can't be filled
+	if filled, err := BatchFillMissingAddresses(context.Background(), broker, "key", 2, 10); err != nil || filled != 0 {
+		t.Errorf("Expected nothing left to fill, got %d (err: %v)", filled, err)
+	}
+}
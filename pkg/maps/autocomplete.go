@@ -9,6 +9,9 @@ import (
 	"net/http"
 )
 
+// autocompleteEndpoint is a package-level variable so tests can point it at a mock server.
+var autocompleteEndpoint = "https://places.googleapis.com/v1/places:autocomplete"
+
 // AutocompleteRequest represents the request body for Places API v1 autocomplete
 type AutocompleteRequest struct {
 	Input                string        `json:"input"`
@@ -30,9 +33,16 @@ type Suggestion struct {
 
 // PlacePrediction represents a place prediction
 type PlacePrediction struct {
-	PlaceID string   `json:"placeId"`
-	Text    Text     `json:"text"`
-	Types   []string `json:"types,omitempty"`
+	PlaceID          string            `json:"placeId"`
+	Text             Text              `json:"text"`
+	StructuredFormat *StructuredFormat `json:"structuredFormat,omitempty"`
+	Types            []string          `json:"types,omitempty"`
+}
+
+// StructuredFormat splits a prediction into the place's name and where it is
+type StructuredFormat struct {
+	MainText      Text `json:"mainText"`
+	SecondaryText Text `json:"secondaryText"`
 }
 
 // QueryPrediction represents a query prediction
@@ -47,13 +57,17 @@ type Text struct {
 
 // AutocompletePrediction represents a simplified prediction for our API
 type AutocompletePrediction struct {
-	Description string   `json:"description"`
-	PlaceID     string   `json:"place_id"`
-	Types       []string `json:"types"`
+	Description string `json:"description"`
+	// MainText is the place itself, e.g. "Sydney Opera House", and SecondaryText where it is,
+	// e.g. "Sydney NSW, Australia". MainText falls back to Description when Google doesn't split it.
+	MainText      string   `json:"main_text"`
+	SecondaryText string   `json:"secondary_text,omitempty"`
+	PlaceID       string   `json:"place_id"`
+	Types         []string `json:"types"`
 }
 
 // GetAutocompleteSuggestions fetches place autocomplete suggestions from Google Places API v1
-func GetAutocompleteSuggestions(ctx context.Context, apiKey, input string, sessionToken string) ([]AutocompletePrediction, error) {
+func GetAutocompleteSuggestions(ctx context.Context, apiKey, input string, sessionToken string) (predictions []AutocompletePrediction, err error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("API key is missing")
 	}
@@ -78,21 +92,25 @@ func GetAutocompleteSuggestions(ctx context.Context, apiKey, input string, sessi
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+
 	// Create HTTP request
-	apiURL := "https://places.googleapis.com/v1/places:autocomplete"
-	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", autocompleteEndpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("X-Goog-Api-Key", apiKey)
-	req.Header.Set("X-Goog-FieldMask", "suggestions.placePrediction.placeId,suggestions.placePrediction.text,suggestions.placePrediction.types")
+	req.Header.Set("X-Goog-FieldMask", "suggestions.placePrediction.placeId,suggestions.placePrediction.text,suggestions.placePrediction.structuredFormat,suggestions.placePrediction.types")
 
+	countCall(SKUAutocomplete)
+	defer func() { logCall(SKUAutocomplete, "", "", err) }()
 	// Make the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := doWithRetry(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -115,14 +133,19 @@ func GetAutocompleteSuggestions(ctx context.Context, apiKey, input string, sessi
 	}
 
 	// Convert to our simplified format
-	var predictions []AutocompletePrediction
 	for _, suggestion := range autocompleteResp.Suggestions {
-		if suggestion.PlacePrediction != nil {
+		// drop predictions whose IDs would fail when the user selects them
+		if suggestion.PlacePrediction != nil && IsValidPlaceID(suggestion.PlacePrediction.PlaceID) {
 			prediction := AutocompletePrediction{
 				Description: suggestion.PlacePrediction.Text.Text,
+				MainText:    suggestion.PlacePrediction.Text.Text,
 				PlaceID:     suggestion.PlacePrediction.PlaceID,
 				Types:       suggestion.PlacePrediction.Types,
 			}
+			if structured := suggestion.PlacePrediction.StructuredFormat; structured != nil && structured.MainText.Text != "" {
+				prediction.MainText = structured.MainText.Text
+				prediction.SecondaryText = structured.SecondaryText.Text
+			}
 			predictions = append(predictions, prediction)
 		}
 	}
@@ -0,0 +1,44 @@
+package maps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetAutocompleteSuggestionsStructuredText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("X-Goog-FieldMask"), "structuredFormat") {
+			t.Errorf("Expected structuredFormat in the field mask, got %q", r.Header.Get("X-Goog-FieldMask"))
+		}
+		w.Write([]byte(`{"suggestions":[
+			{"placePrediction":{"placeId":"ChIJ3S-JXmauEmsRUcIaWtf4MzE","text":{"text":"Sydney Opera House, Sydney NSW, Australia"},
+				"structuredFormat":{"mainText":{"text":"Sydney Opera House"},"secondaryText":{"text":"Sydney NSW, Australia"}}}},
+			{"placePrediction":{"placeId":"ChIJP3Sa8ziYEmsRUKgyFmh9AQM","text":{"text":"Sydney NSW, Australia"}}}
+		]}`))
+	}))
+	defer server.Close()
+
+	original := autocompleteEndpoint
+	defer func() { autocompleteEndpoint = original }()
+	autocompleteEndpoint = server.URL
+
+	predictions, err := GetAutocompleteSuggestions(context.Background(), "key", "sydney", "")
+	if err != nil {
+		t.Fatalf("GetAutocompleteSuggestions failed: %v", err)
+	}
+	if len(predictions) != 2 {
+		t.Fatalf("Expected 2 predictions, got %d", len(predictions))
+	}
+
+	opera := predictions[0]
+	if opera.Description != "Sydney Opera House, Sydney NSW, Australia" || opera.MainText != "Sydney Opera House" || opera.SecondaryText != "Sydney NSW, Australia" {
+		t.Errorf("Expected structured text, got %+v", opera)
+	}
+	// without a structured format the whole description is the main text
+	if city := predictions[1]; city.MainText != city.Description || city.SecondaryText != "" {
+		t.Errorf("Expected the description as main text, got %+v", city)
+	}
+}
@@ -0,0 +1,97 @@
+package maps
+
+import (
+	"math"
+	"sort"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+// Bearing returns the initial compass bearing in degrees [0, 360) from one point to another.
+func Bearing(from, to Center) float64 {
+	lat1 := from.Latitude * math.Pi / 180
+	lat2 := to.Latitude * math.Pi / 180
+	dLon := (to.Longitude - from.Longitude) * math.Pi / 180
+
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// relativeBearing returns the angle of target relative to heading, in degrees (-180, 180].
+// Zero is straight ahead, positive is to the right.
+func relativeBearing(heading, target float64) float64 {
+	diff := math.Mod(target-heading, 360)
+	if diff > 180 {
+		diff -= 360
+	} else if diff <= -180 {
+		diff += 360
+	}
+	return diff
+}
+
+// RouteHeadingAt returns the direction of travel, in compass degrees, of the route segment closest to point.
+// A route with no segments has no heading and returns 0.
+func RouteHeadingAt(index *PolylineIndex, point Center) float64 {
+	heading, _ := routeHeadingAt(point, index)
+	return heading
+}
+
+// routeHeadingAt returns the direction of travel, in compass degrees, of the route segment closest to point.
+// The boolean is false when the route has no segments.
+func routeHeadingAt(point Center, index *PolylineIndex) (float64, bool) {
+	if index == nil || len(index.polyline) < 2 {
+		return 0, false
+	}
+	point = index.localize(point)
+
+	segments := index.nearbySegments(point)
+	if len(segments) == 0 {
+		// outside the indexed area, fall back to checking every segment
+		for i := 0; i < len(index.polyline)-1; i++ {
+			segments = append(segments, PolylineSegment{StartIdx: i, EndIdx: i + 1})
+		}
+	}
+
+	minDist := math.MaxFloat64
+	var p1, p2 Center
+	for _, segment := range segments {
+		a, b := index.polyline[segment.StartIdx], index.polyline[segment.EndIdx]
+		// zero length segments have no direction
+		if a == b {
+			continue
+		}
+		if dist := distanceToSegment(point, a, b); dist < minDist {
+			minDist = dist
+			p1, p2 = a, b
+		}
+	}
+	if minDist == math.MaxFloat64 {
+		return 0, false
+	}
+
+	return Bearing(p1, p2), true
+}
+
+// orientRestaurants returns a copy of restaurants with BearingFromRoute set relative to the route's heading
+// at the supercharger, ordered so food that doesn't need a U-turn comes first.
+// A restaurant directly behind the driver is ranked as if it were twice as far away.
+func orientRestaurants(restaurants []db.RestaurantWithDistance, supercharger Center, heading float64) []db.RestaurantWithDistance {
+	oriented := make([]db.RestaurantWithDistance, len(restaurants))
+	copy(oriented, restaurants)
+
+	for i := range oriented {
+		restaurant := Center{Latitude: oriented[i].Latitude, Longitude: oriented[i].Longitude}
+		oriented[i].BearingFromRoute = relativeBearing(heading, Bearing(supercharger, restaurant))
+	}
+
+	weighted := func(r db.RestaurantWithDistance) float64 {
+		return r.Distance * (1 + math.Abs(r.BearingFromRoute)/180)
+	}
+	sort.SliceStable(oriented, func(i, j int) bool {
+		return weighted(oriented[i]) < weighted(oriented[j])
+	})
+
+	return oriented
+}
@@ -0,0 +1,92 @@
+package maps
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestBearing(t *testing.T) {
+	origin := Center{Latitude: 0, Longitude: 0}
+	tests := []struct {
+		to   Center
+		want float64
+	}{
+		{Center{Latitude: 1, Longitude: 0}, 0},
+		{Center{Latitude: 0, Longitude: 1}, 90},
+		{Center{Latitude: -1, Longitude: 0}, 180},
+		{Center{Latitude: 0, Longitude: -1}, 270},
+	}
+	for _, tt := range tests {
+		if got := Bearing(origin, tt.to); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("bearing to %v = %.2f, want %.2f", tt.to, got, tt.want)
+		}
+	}
+
+	if got := relativeBearing(350, 10); got != 20 {
+		t.Errorf("relativeBearing(350, 10) = %v, want 20", got)
+	}
+	if got := relativeBearing(10, 350); got != -20 {
+		t.Errorf("relativeBearing(10, 350) = %v, want -20", got)
+	}
+}
+
+func TestRouteHeadingAt(t *testing.T) {
+	// an anticlockwise square, travelling east, north, west then south
+	route := []Center{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 1},
+		{Latitude: 1, Longitude: 1},
+		{Latitude: 1, Longitude: 0},
+		{Latitude: 0.1, Longitude: 0},
+	}
+	index := buildPolylineIndex(route, 0.1)
+
+	tests := []struct {
+		point Center
+		want  float64
+	}{
+		{Center{Latitude: -0.01, Longitude: 0.5}, 90},
+		{Center{Latitude: 0.5, Longitude: 1.01}, 0},
+		{Center{Latitude: 1.01, Longitude: 0.5}, 270},
+		{Center{Latitude: 0.5, Longitude: -0.01}, 180},
+	}
+	for _, tt := range tests {
+		if got := RouteHeadingAt(index, tt.point); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("RouteHeadingAt(%v) = %.2f, want %.2f", tt.point, got, tt.want)
+		}
+	}
+
+	if got := RouteHeadingAt(buildPolylineIndex([]Center{{Latitude: 1, Longitude: 1}}, 0.1), Center{}); got != 0 {
+		t.Errorf("Expected no heading for a single point route, got %.2f", got)
+	}
+}
+
+func TestOrientRestaurants(t *testing.T) {
+	// route heading due north through the supercharger
+	index := buildPolylineIndex([]Center{{Latitude: 37.0, Longitude: -122.0}, {Latitude: 37.1, Longitude: -122.0}}, 0.01)
+	supercharger := Center{Latitude: 37.05, Longitude: -122.0}
+
+	heading, ok := routeHeadingAt(supercharger, index)
+	if !ok || math.Abs(heading) > 0.01 {
+		t.Fatalf("Expected heading 0, got %.2f (ok: %v)", heading, ok)
+	}
+
+	restaurants := []db.RestaurantWithDistance{
+		{Restaurant: db.Restaurant{PlaceID: "behind", Latitude: 37.049, Longitude: -122.0}, Distance: 100},
+		{Restaurant: db.Restaurant{PlaceID: "ahead", Latitude: 37.0515, Longitude: -122.0}, Distance: 150},
+	}
+	oriented := orientRestaurants(restaurants, supercharger, heading)
+
+	if oriented[0].PlaceID != "ahead" {
+		t.Errorf("Expected restaurant ahead to rank first, got %s", oriented[0].PlaceID)
+	}
+	if math.Abs(oriented[1].BearingFromRoute) < 179 {
+		t.Errorf("Expected restaurant behind at ±180, got %.2f", oriented[1].BearingFromRoute)
+	}
+	// the shared input must be left untouched
+	if restaurants[0].PlaceID != "behind" || restaurants[0].BearingFromRoute != 0 {
+		t.Error("orientRestaurants modified its input")
+	}
+}
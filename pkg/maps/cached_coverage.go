@@ -0,0 +1,102 @@
+package maps
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+// DefaultMinCachedPerCircle is how many cached superchargers a circle needs to skip its Google search
+// when SearchConfig.PreferCached is set. It is high enough that a lone cached charger doesn't hide new
+// ones opened around it.
+const DefaultMinCachedPerCircle = 3
+
+// minCachedPerCircle returns the cached superchargers a circle needs to skip its search, defaulting to DefaultMinCachedPerCircle
+func (c *SearchConfig) minCachedPerCircle() int {
+	if c.MinCachedPerCircle <= 0 {
+		return DefaultMinCachedPerCircle
+	}
+	return c.MinCachedPerCircle
+}
+
+// cellIndex identifies a density grid cell by its row and column from the grid's origin
+type cellIndex struct {
+	row, col int
+}
+
+// cachedCoverage splits circles into the gaps that still need a Google search and the rest, which are
+// covered well enough by cached superchargers. Coverage comes from a density grid with cells one circle
+// across: a circle counts the cached superchargers in every cell its bounding box touches, and is a gap if
+// there are fewer than minCached. The cached superchargers inside each covered circle's bounding box are
+// returned with their locations so they can stand in for the skipped searches.
+func cachedCoverage(broker *db.Service, circles []Circle, radius float64, minCached int) ([]Circle, map[string]*Center, error) {
+	if len(circles) == 0 {
+		return circles, map[string]*Center{}, nil
+	}
+
+	cellDegrees := 2 * radius / metersPerDegree
+	// every circle's bounding box, so the grid covers all the cells they touch
+	minLat, maxLat, minLng, maxLng := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	boxes := make([][4]float64, len(circles))
+	for i, circle := range circles {
+		boxes[i] = circleBounds(circle.Center, radius)
+		minLat, maxLat = math.Min(minLat, boxes[i][0]), math.Max(maxLat, boxes[i][1])
+		minLng, maxLng = math.Min(minLng, boxes[i][2]), math.Max(maxLng, boxes[i][3])
+	}
+
+	cells, err := broker.Supercharger.DensityGrid(minLat, maxLat, minLng, maxLng, cellDegrees)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load cached supercharger density: %w", err)
+	}
+	counts := make(map[cellIndex]int64, len(cells))
+	for _, cell := range cells {
+		counts[cellIndex{
+			row: int(math.Round((cell.MinLat - minLat) / cellDegrees)),
+			col: int(math.Round((cell.MinLng - minLng) / cellDegrees)),
+		}] = cell.Count
+	}
+	cellOf := func(lat, lng float64) cellIndex {
+		return cellIndex{row: int((lat - minLat) / cellDegrees), col: int((lng - minLng) / cellDegrees)}
+	}
+
+	var gaps []Circle
+	var covered [][4]float64
+	for i, circle := range circles {
+		low, high := cellOf(boxes[i][0], boxes[i][2]), cellOf(boxes[i][1], boxes[i][3])
+		var cached int64
+		for row := low.row; row <= high.row; row++ {
+			for col := low.col; col <= high.col; col++ {
+				cached += counts[cellIndex{row, col}]
+			}
+		}
+		if cached < int64(minCached) {
+			gaps = append(gaps, circle)
+			continue
+		}
+		covered = append(covered, boxes[i])
+	}
+
+	// each covered circle only reads its own box, so a long route doesn't scan everything between its ends
+	cachedIDs := make(map[string]*Center)
+	for _, box := range covered {
+		err = broker.Supercharger.ForEachInLocation(box[0], box[1], box[2], box[3], 500, func(batch []db.Supercharger) error {
+			for _, sc := range batch {
+				cachedIDs[sc.PlaceID] = &Center{Latitude: sc.Latitude, Longitude: sc.Longitude}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load cached superchargers: %w", err)
+		}
+	}
+	return gaps, cachedIDs, nil
+}
+
+// circleBounds returns the min/max latitude and longitude of the box around a circle
+func circleBounds(center Center, radius float64) [4]float64 {
+	dLat := radius / metersPerDegree
+	// clamp so circles near the poles don't divide by zero
+	dLng := radius / (metersPerDegree * math.Max(math.Cos(center.Latitude*math.Pi/180), 0.01))
+	return [4]float64{center.Latitude - dLat, center.Latitude + dLat, center.Longitude - dLng, center.Longitude + dLng}
+}
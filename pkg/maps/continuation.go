@@ -0,0 +1,68 @@
+package maps
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// continuationRouteMaxAge is how long a stored route is reused for continuation requests when
+// RouteReuseMaxAge doesn't allow longer, so every page of a route shares the same geometry
+const continuationRouteMaxAge = time.Hour
+
+// routePage is the slice of a route's search circles searched by one request
+type routePage struct {
+	// circles are the page's own circles plus the first circle of the next page, since a supercharger just
+	// before the page's end may only be found by the circle after it
+	circles []Circle
+	// from and to bound the distance along the route of the superchargers in this page, to is +Inf on the last page
+	from, to float64
+	// next is the continuation token for the following page, empty on the last page
+	next string
+}
+
+// pageCircles picks up to perPage of the circles starting at continueFrom meters along the route.
+// Circles are placed in order along the route, so each page covers a contiguous stretch. Pages overlap by
+// one circle, with superchargers assigned to a page by their distance along the route. A perPage of zero
+// or less takes every remaining circle.
+func pageCircles(circles []Circle, index *PolylineIndex, continueFrom float64, perPage int) routePage {
+	alongRoute := make([]float64, len(circles))
+	for i, circle := range circles {
+		_, alongRoute[i], _ = distanceToPolylineWithIndex(circle.Center, index)
+	}
+
+	start := 0
+	if continueFrom > 0 {
+		for start < len(circles) && alongRoute[start] < continueFrom {
+			start++
+		}
+	}
+	end := len(circles)
+	if perPage > 0 && start+perPage < end {
+		end = start + perPage
+	}
+
+	page := routePage{circles: circles[start:end], from: continueFrom, to: math.Inf(1)}
+	if end < len(circles) {
+		page.circles = circles[start : end+1]
+		page.to = alongRoute[end]
+		page.next = strconv.FormatFloat(alongRoute[end], 'f', -1, 64)
+	}
+	return page
+}
+
+// contains reports whether a supercharger this far along the route belongs to the page
+func (p routePage) contains(distanceAlongRoute float64) bool {
+	return distanceAlongRoute >= p.from && distanceAlongRoute < p.to
+}
+
+// ParseContinuation reads a continuation token from a previous result's NextContinuation,
+// returning the distance along the route in meters to resume from
+func ParseContinuation(token string) (float64, error) {
+	offset, err := strconv.ParseFloat(token, 64)
+	if err != nil || offset < 0 || math.IsInf(offset, 0) || math.IsNaN(offset) {
+		return 0, fmt.Errorf("invalid continuation token %q", token)
+	}
+	return offset, nil
+}
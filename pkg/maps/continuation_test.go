@@ -0,0 +1,105 @@
+package maps
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPageCircles(t *testing.T) {
+	// about 111km due east along the equator
+	route := []Center{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 1}}
+	index := buildPolylineIndex(route, 0.1)
+	circles := pointsToCircles(interpolatePoints(route, 1000), 20000)
+	if len(circles) < 5 {
+		t.Fatalf("Expected at least 5 circles, got %d", len(circles))
+	}
+
+	var searched []Circle
+	var from float64
+	pages := 0
+	for {
+		page := pageCircles(circles, index, from, 2)
+		pages++
+		if page.from != from || !page.contains(from) {
+			t.Errorf("Page %d should start at %.0fm", pages, from)
+		}
+		if page.next == "" {
+			if !math.IsInf(page.to, 1) {
+				t.Errorf("Expected the last page to run to the end of the route, got %.0fm", page.to)
+			}
+			searched = append(searched, page.circles...)
+			break
+		}
+		// the page also searches the next page's first circle
+		searched = append(searched, page.circles[:len(page.circles)-1]...)
+		next, err := ParseContinuation(page.next)
+		if err != nil {
+			t.Fatalf("ParseContinuation failed: %v", err)
+		}
+		if next <= from || next != page.to || page.contains(next) {
+			t.Fatalf("Expected page %d to end where the next starts, got %.0fm to %.0fm then %.0fm", pages, from, page.to, next)
+		}
+		following := pageCircles(circles, index, next, 2)
+		if page.circles[len(page.circles)-1] != following.circles[0] {
+			t.Errorf("Expected page %d to overlap the next by one circle", pages)
+		}
+		from = next
+	}
+
+	// every circle is a page's own exactly once
+	if len(searched) != len(circles) {
+		t.Fatalf("Expected %d circles over all pages, got %d", len(circles), len(searched))
+	}
+	for i := range circles {
+		if searched[i] != circles[i] {
+			t.Errorf("Circle %d searched out of order", i)
+		}
+	}
+	if want := (len(circles) + 1) / 2; pages != want {
+		t.Errorf("Expected %d pages, got %d", want, pages)
+	}
+
+	// without a page size everything is searched at once
+	if page := pageCircles(circles, index, 0, 0); len(page.circles) != len(circles) || page.next != "" {
+		t.Errorf("Expected a single page, got %d circles and next %q", len(page.circles), page.next)
+	}
+
+	for _, token := range []string{"", "abc", "-5", "Inf", "NaN"} {
+		if _, err := ParseContinuation(token); err == nil {
+			t.Errorf("Expected %q to be rejected", token)
+		}
+	}
+}
+
+func TestPageCirclesFindsChargersAtPageEnd(t *testing.T) {
+	route := []Center{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 1}}
+	index := buildPolylineIndex(route, 0.1)
+	circles := pointsToCircles(interpolatePoints(route, 1000), 20000)
+
+	page := pageCircles(circles, index, 0, 2)
+	if page.next == "" {
+		t.Fatal("Expected more than one page")
+	}
+	// a charger just before the page ends, off to the side of the route, is only in reach of the
+	// circle that starts the next page
+	next := pageCircles(circles, index, page.to, 2).circles[0]
+	lateral := next.Radius * 0.99 / 111320
+	charger := Center{Latitude: lateral, Longitude: next.Center.Longitude - 0.00001}
+	_, along, _ := distanceToPolylineWithIndex(charger, index)
+	if !page.contains(along) {
+		t.Fatalf("Expected the charger at %.0fm to belong to the page ending at %.0fm", along, page.to)
+	}
+	for _, circle := range circles {
+		if circle != next && haversineDistance(circle.Center, charger) <= circle.Radius {
+			t.Fatal("Expected only the next page's first circle to reach the charger")
+		}
+	}
+
+	found := false
+	for _, circle := range page.circles {
+		found = found || haversineDistance(circle.Center, charger) <= circle.Radius
+	}
+	if !found {
+		t.Error("Expected the page to search a circle reaching the charger")
+	}
+}
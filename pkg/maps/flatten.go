@@ -0,0 +1,41 @@
+package maps
+
+import "github.com/brensch/passengerprincess/pkg/db"
+
+// FlatSupercharger is a supercharger that refers to its restaurants by place ID
+// instead of embedding them.
+type FlatSupercharger struct {
+	SuperchargerWithETA
+	// Restaurants is always empty, it shadows the embedded list so it isn't serialised
+	Restaurants   []db.RestaurantWithDistance `json:"restaurants,omitempty"`
+	RestaurantIDs []string                    `json:"restaurant_ids"` // Ranked as in the nested response
+}
+
+// FlatSuperchargersOnRouteResult is a route result with each restaurant listed once in a map keyed by
+// place ID. Restaurants near several chargers are only sent once, which shrinks dense metro routes.
+type FlatSuperchargersOnRouteResult struct {
+	*SuperchargersOnRouteResult
+	Superchargers []FlatSupercharger       `json:"superchargers"`
+	Restaurants   map[string]db.Restaurant `json:"restaurants"`
+}
+
+// Flatten converts the result to the deduplicated flat shape. The original result is not modified.
+func (r *SuperchargersOnRouteResult) Flatten() *FlatSuperchargersOnRouteResult {
+	flat := &FlatSuperchargersOnRouteResult{
+		SuperchargersOnRouteResult: r,
+		Superchargers:              make([]FlatSupercharger, len(r.Superchargers)),
+		Restaurants:                make(map[string]db.Restaurant),
+	}
+
+	for i, sc := range r.Superchargers {
+		ids := make([]string, len(sc.Restaurants))
+		for j, restaurant := range sc.Restaurants {
+			ids[j] = restaurant.PlaceID
+			flat.Restaurants[restaurant.PlaceID] = restaurant.Restaurant
+		}
+		sc.Restaurants = nil
+		flat.Superchargers[i] = FlatSupercharger{SuperchargerWithETA: sc, RestaurantIDs: ids}
+	}
+
+	return flat
+}
@@ -0,0 +1,55 @@
+package maps
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestFlatten(t *testing.T) {
+	shared := db.RestaurantWithDistance{Restaurant: db.Restaurant{PlaceID: "shared", Name: "Diner"}, Distance: 100}
+	only := db.RestaurantWithDistance{Restaurant: db.Restaurant{PlaceID: "only", Name: "Cafe"}, Distance: 200}
+	result := &SuperchargersOnRouteResult{
+		Origin: "a",
+		Superchargers: []SuperchargerWithETA{
+			{Supercharger: &db.Supercharger{PlaceID: "sc1"}, Restaurants: []db.RestaurantWithDistance{shared, only}},
+			{Supercharger: &db.Supercharger{PlaceID: "sc2"}, Restaurants: []db.RestaurantWithDistance{shared}},
+		},
+	}
+
+	flat := result.Flatten()
+
+	if len(flat.Restaurants) != 2 {
+		t.Errorf("Expected 2 deduplicated restaurants, got %d", len(flat.Restaurants))
+	}
+	if ids := flat.Superchargers[0].RestaurantIDs; len(ids) != 2 || ids[0] != "shared" || ids[1] != "only" {
+		t.Errorf("Unexpected restaurant IDs for sc1: %v", ids)
+	}
+	if len(result.Superchargers[0].Restaurants) != 2 {
+		t.Error("Flatten modified the original result")
+	}
+
+	encoded, err := json.Marshal(flat)
+	if err != nil {
+		t.Fatalf("Failed to marshal flat result: %v", err)
+	}
+	var decoded struct {
+		Origin        string                     `json:"origin"`
+		Restaurants   map[string]json.RawMessage `json:"restaurants"`
+		Superchargers []map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal flat result: %v", err)
+	}
+	if decoded.Origin != "a" || len(decoded.Restaurants) != 2 {
+		t.Errorf("Unexpected top level fields: %s", encoded)
+	}
+	if _, nested := decoded.Superchargers[0]["restaurants"]; nested {
+		t.Error("Expected nested restaurants to be omitted")
+	}
+	if strings.Count(string(encoded), `"Diner"`) != 1 {
+		t.Errorf("Expected shared restaurant to appear once: %s", encoded)
+	}
+}
@@ -0,0 +1,24 @@
+package maps
+
+import "github.com/brensch/passengerprincess/pkg/db"
+
+// foodRatings returns the average rating of the restaurants, weighted by how many reviews each has,
+// and the best rating among them. Restaurants without reviews are ignored, and both are nil when none have any.
+func foodRatings(restaurants []db.RestaurantWithDistance) (avg, best *float64) {
+	var weighted, reviews, top float64
+	for _, r := range restaurants {
+		if r.UserRatingsTotal <= 0 {
+			continue
+		}
+		weighted += r.Rating * float64(r.UserRatingsTotal)
+		reviews += float64(r.UserRatingsTotal)
+		if r.Rating > top {
+			top = r.Rating
+		}
+	}
+	if reviews == 0 {
+		return nil, nil
+	}
+	average := weighted / reviews
+	return &average, &top
+}
@@ -0,0 +1,30 @@
+package maps
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestFoodRatings(t *testing.T) {
+	restaurant := func(rating float64, reviews int) db.RestaurantWithDistance {
+		return db.RestaurantWithDistance{Restaurant: db.Restaurant{Rating: rating, UserRatingsTotal: reviews}}
+	}
+
+	avg, best := foodRatings([]db.RestaurantWithDistance{
+		restaurant(4.0, 300),
+		restaurant(5.0, 100),
+		restaurant(4.9, 0), // no reviews, ignored
+	})
+	if avg == nil || math.Abs(*avg-4.25) > 1e-9 {
+		t.Errorf("Expected a weighted average of 4.25, got %v", avg)
+	}
+	if best == nil || *best != 5.0 {
+		t.Errorf("Expected a best rating of 5, got %v", best)
+	}
+
+	if avg, best := foodRatings(nil); avg != nil || best != nil {
+		t.Errorf("Expected no ratings without restaurants, got %v %v", avg, best)
+	}
+}
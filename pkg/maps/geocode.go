@@ -0,0 +1,131 @@
+package maps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+var geocodeEndpoint = "https://maps.googleapis.com/maps/api/geocode/json"
+
+// ErrAddressNotFound is returned when a geocoder has no match for an address
+var ErrAddressNotFound = errors.New("address not found")
+
+// Geocoder resolves an address to a location and its formatted address.
+// Implementations other than Google, such as Nominatim, can be swapped in through SearchConfig.
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (Center, string, error)
+}
+
+// GoogleGeocoder resolves addresses with the Google Geocoding API
+type GoogleGeocoder struct {
+	APIKey string
+	Locale Locale
+}
+
+// geocodeResponse is the subset of the Geocoding API response we use
+type geocodeResponse struct {
+	Status       string          `json:"status"`
+	ErrorMessage string          `json:"error_message,omitempty"`
+	Results      []geocodeResult `json:"results"`
+}
+
+// geocodeResult is one match in a Geocoding API response
+type geocodeResult struct {
+	FormattedAddress string `json:"formatted_address"`
+	Geometry         struct {
+		Location struct {
+			Lat float64 `json:"lat"`
+			Lng float64 `json:"lng"`
+		} `json:"location"`
+	} `json:"geometry"`
+}
+
+// Geocode returns the location and formatted address of the best match for address
+func (g *GoogleGeocoder) Geocode(ctx context.Context, address string) (Center, string, error) {
+	params := url.Values{}
+	params.Set("address", address)
+	params.Set("key", g.APIKey)
+	if g.Locale.RegionCode != "" {
+		params.Set("region", g.Locale.RegionCode)
+	}
+
+	best, err := getGeocode(ctx, params, g.Locale)
+	if err != nil {
+		if errors.Is(err, ErrAddressNotFound) {
+			return Center{}, "", fmt.Errorf("%w: %q", ErrAddressNotFound, address)
+		}
+		return Center{}, "", err
+	}
+
+	location := Center{Latitude: best.Geometry.Location.Lat, Longitude: best.Geometry.Location.Lng}
+	return location, best.FormattedAddress, nil
+}
+
+// ReverseGeocode returns the formatted street address closest to location
+func ReverseGeocode(ctx context.Context, apiKey string, location Center, locale Locale) (string, error) {
+	params := url.Values{}
+	params.Set("latlng", fmt.Sprintf("%f,%f", location.Latitude, location.Longitude))
+	params.Set("result_type", "street_address|premise|route")
+	params.Set("key", apiKey)
+
+	best, err := getGeocode(ctx, params, locale)
+	if err != nil {
+		if errors.Is(err, ErrAddressNotFound) {
+			return "", fmt.Errorf("%w: no address near %f,%f", ErrAddressNotFound, location.Latitude, location.Longitude)
+		}
+		return "", err
+	}
+	return best.FormattedAddress, nil
+}
+
+// getGeocode makes a Geocoding API request and returns the best result, or ErrAddressNotFound when there isn't one
+func getGeocode(ctx context.Context, params url.Values, locale Locale) (*geocodeResult, error) {
+	if locale.LanguageCode != "" {
+		params.Set("language", locale.LanguageCode)
+	}
+
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", geocodeEndpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	countCall(SKUGeocoding)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Google Geocoding API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google geocoding api returned an error. status: %s, body: %s", resp.Status, string(bodyBytes))
+	}
+
+	var geoResp geocodeResponse
+	if err := json.Unmarshal(bodyBytes, &geoResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response json: %w", err)
+	}
+
+	// Like the Time Zone API, failures are reported in the body with a 200
+	if geoResp.Status == "ZERO_RESULTS" || (geoResp.Status == "OK" && len(geoResp.Results) == 0) {
+		return nil, ErrAddressNotFound
+	}
+	if geoResp.Status != "OK" {
+		return nil, fmt.Errorf("google geocoding api returned status %s: %s", geoResp.Status, geoResp.ErrorMessage)
+	}
+
+	return &geoResp.Results[0], nil
+}
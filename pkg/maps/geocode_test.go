@@ -0,0 +1,72 @@
+package maps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGoogleGeocoder(t *testing.T) {
+	body := `{"status":"OK","results":[{"formatted_address":"Boston, MA, USA","geometry":{"location":{"lat":42.36,"lng":-71.06}}}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("address"); got != "Boston" {
+			t.Errorf("Unexpected address parameter %q", got)
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	originalEndpoint := geocodeEndpoint
+	defer func() { geocodeEndpoint = originalEndpoint }()
+	geocodeEndpoint = server.URL
+
+	geocoder := &GoogleGeocoder{APIKey: "key"}
+	location, formatted, err := geocoder.Geocode(context.Background(), "Boston")
+	if err != nil {
+		t.Fatalf("Geocode failed: %v", err)
+	}
+	if location.Latitude != 42.36 || location.Longitude != -71.06 || formatted != "Boston, MA, USA" {
+		t.Errorf("Unexpected result %v %q", location, formatted)
+	}
+
+	body = `{"status":"ZERO_RESULTS","results":[]}`
+	if _, _, err := geocoder.Geocode(context.Background(), "Boston"); !errors.Is(err, ErrAddressNotFound) {
+		t.Errorf("Expected ErrAddressNotFound, got %v", err)
+	}
+}
+
+// stubGeocoder resolves every address to the same point
+type stubGeocoder struct {
+	location Center
+}
+
+func (g stubGeocoder) Geocode(ctx context.Context, address string) (Center, string, error) {
+	return g.location, address, nil
+}
+
+func TestGetSuperchargersOnRouteUsesGeocoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req EnhancedRouteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if req.Origin.Address != "" || req.Origin.Location == nil || req.Origin.Location.LatLng.Latitude != 1.5 {
+			t.Errorf("Expected the geocoded origin to be sent as a location, got %+v", req.Origin)
+		}
+		w.Write([]byte(`{"routes":[{"distanceMeters":0,"duration":"0s","polyline":{"encodedPolyline":""}}]}`))
+	}))
+	defer server.Close()
+
+	original := routesEndpoint
+	defer func() { routesEndpoint = original }()
+	routesEndpoint = server.URL
+
+	config := DefaultSearchConfig()
+	config.Geocoder = stubGeocoder{location: Center{Latitude: 1.5, Longitude: 2.5}}
+	if _, err := GetSuperchargersOnRoute(context.Background(), nil, "key", "here", "there", config); !errors.Is(err, ErrEmptyRoute) {
+		t.Errorf("Expected ErrEmptyRoute, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package maps
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// geoJSONFeatureCollection is a GeoJSON FeatureCollection
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// geoJSONFeature is a GeoJSON feature with a Point or LineString geometry
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// geoJSONGeometry holds a [lng, lat] position for a Point or a list of them for a LineString
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// geoJSONPosition orders a point as GeoJSON requires, longitude first
+func geoJSONPosition(latitude, longitude float64) [2]float64 {
+	return [2]float64{longitude, latitude}
+}
+
+// MarshalRouteGeoJSON encodes a route result as a GeoJSON FeatureCollection for GIS tools and map libraries:
+// a LineString for the route, then a Point for each supercharger and each of their restaurants. Features
+// have a "kind" property of "route", "supercharger" or "restaurant" to tell them apart. A restaurant near
+// several superchargers appears once, under the first.
+func MarshalRouteGeoJSON(result *SuperchargersOnRouteResult) ([]byte, error) {
+	if result == nil || result.Route == nil {
+		return nil, fmt.Errorf("%w: result has no route", ErrEmptyRoute)
+	}
+	points, err := result.Route.Path()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode route path: %w", err)
+	}
+
+	line := make([][2]float64, len(points))
+	for i, p := range points {
+		line[i] = geoJSONPosition(p.Latitude, p.Longitude)
+	}
+	collection := geoJSONFeatureCollection{
+		Type: "FeatureCollection",
+		Features: []geoJSONFeature{{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "LineString", Coordinates: line},
+			Properties: map[string]interface{}{
+				"kind":             "route",
+				"origin":           result.Origin,
+				"destination":      result.Destination,
+				"distance_meters":  result.Route.DistanceMeters,
+				"duration_seconds": int(result.Route.Duration.Seconds()),
+			},
+		}},
+	}
+
+	seenRestaurants := make(map[string]bool)
+	for _, sc := range result.Superchargers {
+		if sc.Supercharger == nil {
+			continue
+		}
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "Point", Coordinates: geoJSONPosition(sc.Supercharger.Latitude, sc.Supercharger.Longitude)},
+			Properties: map[string]interface{}{
+				"kind":                 "supercharger",
+				"place_id":             sc.Supercharger.PlaceID,
+				"name":                 sc.Supercharger.Name,
+				"address":              sc.Supercharger.Address,
+				"arrival_time":         sc.ArrivalTime,
+				"arrival_timestamp_ms": sc.ArrivalTimestampMS,
+				"distance_from_route":  sc.DistanceFromRoute,
+			},
+		})
+
+		for _, r := range sc.Restaurants {
+			if seenRestaurants[r.PlaceID] {
+				continue
+			}
+			seenRestaurants[r.PlaceID] = true
+			collection.Features = append(collection.Features, geoJSONFeature{
+				Type:     "Feature",
+				Geometry: geoJSONGeometry{Type: "Point", Coordinates: geoJSONPosition(r.Latitude, r.Longitude)},
+				Properties: map[string]interface{}{
+					"kind":            "restaurant",
+					"place_id":        r.PlaceID,
+					"name":            r.Name,
+					"address":         r.Address,
+					"rating":          r.Rating,
+					"supercharger_id": sc.Supercharger.PlaceID,
+					"distance":        r.Distance,
+				},
+			})
+		}
+	}
+
+	return json.Marshal(collection)
+}
@@ -0,0 +1,64 @@
+package maps
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestMarshalRouteGeoJSON(t *testing.T) {
+	path := []Center{{Latitude: 38, Longitude: -121}, {Latitude: 39, Longitude: -120}}
+	shared := db.RestaurantWithDistance{Restaurant: db.Restaurant{PlaceID: "shared", Latitude: 38.51, Longitude: -120.5}}
+	result := &SuperchargersOnRouteResult{
+		Route: &RouteInfo{EncodedPolyline: EncodePolyline(path)},
+		Superchargers: []SuperchargerWithETA{
+			{Supercharger: &db.Supercharger{PlaceID: "sc1", Name: "First", Latitude: 38.5, Longitude: -120.5}, ArrivalTime: "10:00 AM", Restaurants: []db.RestaurantWithDistance{shared}},
+			{Supercharger: &db.Supercharger{PlaceID: "sc2", Latitude: 38.52, Longitude: -120.5}, Restaurants: []db.RestaurantWithDistance{shared}},
+		},
+	}
+
+	data, err := MarshalRouteGeoJSON(result)
+	if err != nil {
+		t.Fatalf("MarshalRouteGeoJSON failed: %v", err)
+	}
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &collection); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 4 {
+		t.Fatalf("Expected a route, 2 superchargers and 1 shared restaurant, got %d features", len(collection.Features))
+	}
+
+	var line [][2]float64
+	if err := json.Unmarshal(collection.Features[0].Geometry.Coordinates, &line); err != nil || collection.Features[0].Geometry.Type != "LineString" {
+		t.Fatalf("Expected the route as a LineString, got %s: %v", collection.Features[0].Geometry.Type, err)
+	}
+	if len(line) != 2 || line[0] != [2]float64{-121, 38} {
+		t.Errorf("Expected [lng, lat] positions, got %v", line)
+	}
+
+	var point [2]float64
+	if err := json.Unmarshal(collection.Features[1].Geometry.Coordinates, &point); err != nil || point != [2]float64{-120.5, 38.5} {
+		t.Errorf("Expected the supercharger at [lng, lat], got %v: %v", point, err)
+	}
+	if props := collection.Features[1].Properties; props["kind"] != "supercharger" || props["name"] != "First" || props["arrival_time"] != "10:00 AM" {
+		t.Errorf("Unexpected supercharger properties %v", props)
+	}
+	if props := collection.Features[2].Properties; props["kind"] != "restaurant" || props["supercharger_id"] != "sc1" {
+		t.Errorf("Expected the shared restaurant under the first supercharger, got %v", props)
+	}
+
+	if _, err := MarshalRouteGeoJSON(&SuperchargersOnRouteResult{}); err == nil {
+		t.Error("Expected an error for a result without a route")
+	}
+}
@@ -0,0 +1,94 @@
+package maps
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// gpxExport is the GPX 1.1 document MarshalRouteGPX writes, unlike gpxDocument which only reads paths.
+// Waypoints must come before tracks to satisfy the schema.
+type gpxExport struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Namespace string        `xml:"xmlns,attr"`
+	Name      string        `xml:"metadata>name,omitempty"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+	Track     gpxTrack      `xml:"trk"`
+}
+
+// gpxWaypoint is a named point, used for superchargers
+type gpxWaypoint struct {
+	Latitude    float64 `xml:"lat,attr"`
+	Longitude   float64 `xml:"lon,attr"`
+	Name        string  `xml:"name,omitempty"`
+	Description string  `xml:"desc,omitempty"`
+}
+
+// gpxTrack is the route as a single track segment. Routes carry no elevation, so points have no <ele>.
+type gpxTrack struct {
+	Name   string     `xml:"name,omitempty"`
+	Points []gpxPoint `xml:"trkseg>trkpt"`
+}
+
+// MarshalRouteGPX encodes a route result as a GPX 1.1 document for navigation apps: a track of the route's
+// path in order, and a waypoint for each supercharger describing the restaurants near it.
+func MarshalRouteGPX(result *SuperchargersOnRouteResult) ([]byte, error) {
+	if result == nil || result.Route == nil {
+		return nil, fmt.Errorf("%w: result has no route", ErrEmptyRoute)
+	}
+	points, err := result.Route.Path()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode route path: %w", err)
+	}
+
+	name := ""
+	if result.Origin != "" && result.Destination != "" {
+		name = result.Origin + " to " + result.Destination
+	}
+	doc := gpxExport{
+		Version:   "1.1",
+		Creator:   "PassengerPrincess",
+		Namespace: "http://www.topografix.com/GPX/1/1",
+		Name:      name,
+		Track:     gpxTrack{Name: name, Points: make([]gpxPoint, len(points))},
+	}
+	for i, p := range points {
+		doc.Track.Points[i] = gpxPoint{Lat: p.Latitude, Lon: p.Longitude}
+	}
+
+	for _, sc := range result.Superchargers {
+		if sc.Supercharger == nil {
+			continue
+		}
+		doc.Waypoints = append(doc.Waypoints, gpxWaypoint{
+			Latitude:    sc.Supercharger.Latitude,
+			Longitude:   sc.Supercharger.Longitude,
+			Name:        sc.Supercharger.Name,
+			Description: gpxRestaurantsDescription(sc),
+		})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode GPX: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// gpxRestaurantsDescription lists a supercharger's restaurants with their ratings, for its waypoint description
+func gpxRestaurantsDescription(sc SuperchargerWithETA) string {
+	if len(sc.Restaurants) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(sc.Restaurants))
+	for _, r := range sc.Restaurants {
+		if r.Rating > 0 {
+			names = append(names, fmt.Sprintf("%s (%.1f)", r.Name, r.Rating))
+		} else {
+			names = append(names, r.Name)
+		}
+	}
+	return "Nearby restaurants: " + strings.Join(names, ", ")
+}
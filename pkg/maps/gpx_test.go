@@ -0,0 +1,76 @@
+package maps
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestMarshalRouteGPX(t *testing.T) {
+	path := []Center{{Latitude: 38, Longitude: -121}, {Latitude: 38.5, Longitude: -120.5}, {Latitude: 39, Longitude: -120}}
+	result := &SuperchargersOnRouteResult{
+		Origin:      "Sacramento",
+		Destination: "Reno",
+		Route:       &RouteInfo{EncodedPolyline: EncodePolyline(path)},
+		Superchargers: []SuperchargerWithETA{
+			{
+				Supercharger: &db.Supercharger{PlaceID: "sc1", Name: "Truckee & Co", Latitude: 38.5, Longitude: -120.5},
+				Restaurants: []db.RestaurantWithDistance{
+					{Restaurant: db.Restaurant{Name: "Diner", Rating: 4.5}},
+					{Restaurant: db.Restaurant{Name: "Cafe"}},
+				},
+			},
+		},
+	}
+
+	data, err := MarshalRouteGPX(result)
+	if err != nil {
+		t.Fatalf("MarshalRouteGPX failed: %v", err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Errorf("Expected an XML declaration, got %.40q", data)
+	}
+	if strings.Contains(string(data), "<ele>") {
+		t.Error("Expected no elevation since routes don't have any")
+	}
+
+	var doc struct {
+		Version   string `xml:"version,attr"`
+		Waypoints []struct {
+			Lat  float64 `xml:"lat,attr"`
+			Lon  float64 `xml:"lon,attr"`
+			Name string  `xml:"name"`
+			Desc string  `xml:"desc"`
+		} `xml:"wpt"`
+		Points []struct {
+			Lat float64 `xml:"lat,attr"`
+			Lon float64 `xml:"lon,attr"`
+		} `xml:"trk>trkseg>trkpt"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Invalid XML: %v", err)
+	}
+	if doc.Version != "1.1" {
+		t.Errorf("Expected GPX 1.1, got %q", doc.Version)
+	}
+	if len(doc.Points) != len(path) {
+		t.Fatalf("Expected %d track points, got %d", len(path), len(doc.Points))
+	}
+	for i, p := range doc.Points {
+		if p.Lat != path[i].Latitude || p.Lon != path[i].Longitude {
+			t.Errorf("Track point %d = (%v, %v), want %v", i, p.Lat, p.Lon, path[i])
+		}
+	}
+	if len(doc.Waypoints) != 1 || doc.Waypoints[0].Name != "Truckee & Co" {
+		t.Fatalf("Expected one supercharger waypoint, got %+v", doc.Waypoints)
+	}
+	if want := "Nearby restaurants: Diner (4.5), Cafe"; doc.Waypoints[0].Desc != want {
+		t.Errorf("Waypoint description = %q, want %q", doc.Waypoints[0].Desc, want)
+	}
+
+	if _, err := MarshalRouteGPX(&SuperchargersOnRouteResult{}); err == nil {
+		t.Error("Expected an error for a result without a route")
+	}
+}
@@ -0,0 +1,71 @@
+package maps
+
+import (
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+const minutesPerWeek = 7 * 24 * 60
+
+// OpeningHours mirrors the regularOpeningHours object from Google Places API
+type OpeningHours = db.OpeningHours
+
+// OpeningPeriod is a single open/close pair. A missing Close means always open.
+type OpeningPeriod = db.OpeningPeriod
+
+// OpeningPoint is a time in the week, with Day 0 being Sunday as in Google's API
+type OpeningPoint = db.OpeningPoint
+
+// minuteOfWeek returns the number of minutes since Sunday midnight
+func minuteOfWeek(p OpeningPoint) int {
+	return p.Day*24*60 + p.Hour*60 + p.Minute
+}
+
+// IsOpenAt reports whether the hours include t, evaluated in t's location.
+// It returns nil when there are no hours to evaluate.
+func IsOpenAt(hours *OpeningHours, t time.Time) *bool {
+	if hours == nil || len(hours.Periods) == 0 {
+		return nil
+	}
+
+	at := int(t.Weekday())*24*60 + t.Hour()*60 + t.Minute()
+	open := false
+	for _, period := range hours.Periods {
+		if period.Close == nil {
+			open = true
+			break
+		}
+
+		start := minuteOfWeek(period.Open)
+		end := minuteOfWeek(*period.Close)
+		// Overnight periods that run past Saturday night wrap around the week
+		if end <= start {
+			end += minutesPerWeek
+		}
+		if (at >= start && at < end) || (at+minutesPerWeek >= start && at+minutesPerWeek < end) {
+			open = true
+			break
+		}
+	}
+
+	return &open
+}
+
+// SetOpenAtArrival flags each of the supercharger's restaurants with whether it will be open when the
+// driver arrives, evaluating their opening hours in loc, the supercharger's local timezone. A nil loc uses
+// the arrival time's own location. Restaurants without hours are left unset. The restaurants are copied
+// first since they may be shared with other requests.
+func SetOpenAtArrival(sc *SuperchargerWithETA, loc *time.Location) {
+	arrival := sc.arrival
+	if loc != nil {
+		arrival = arrival.In(loc)
+	}
+
+	restaurants := make([]db.RestaurantWithDistance, len(sc.Restaurants))
+	copy(restaurants, sc.Restaurants)
+	for i := range restaurants {
+		restaurants[i].OpenAtArrival = IsOpenAt(restaurants[i].OpeningHours, arrival)
+	}
+	sc.Restaurants = restaurants
+}
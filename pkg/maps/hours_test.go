@@ -0,0 +1,78 @@
+package maps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestIsOpenAt(t *testing.T) {
+	// Open 9am-5pm Monday, and Friday 10pm until 2am Saturday
+	hours := &OpeningHours{Periods: []OpeningPeriod{
+		{Open: OpeningPoint{Day: 1, Hour: 9}, Close: &OpeningPoint{Day: 1, Hour: 17}},
+		{Open: OpeningPoint{Day: 5, Hour: 22}, Close: &OpeningPoint{Day: 6, Hour: 2}},
+		// Saturday 11pm until 1am Sunday wraps around the week
+		{Open: OpeningPoint{Day: 6, Hour: 23}, Close: &OpeningPoint{Day: 0, Hour: 1}},
+	}}
+
+	// 2024-01-01 was a Monday
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"monday morning", monday.Add(10 * time.Hour), true},
+		{"monday evening", monday.Add(18 * time.Hour), false},
+		{"friday late night", monday.AddDate(0, 0, 4).Add(23 * time.Hour), true},
+		{"saturday early morning", monday.AddDate(0, 0, 5).Add(1 * time.Hour), true},
+		{"saturday morning", monday.AddDate(0, 0, 5).Add(3 * time.Hour), false},
+		{"sunday just after midnight", monday.AddDate(0, 0, 6).Add(30 * time.Minute), true},
+	}
+	for _, tt := range tests {
+		got := IsOpenAt(hours, tt.at)
+		if got == nil || *got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	if IsOpenAt(nil, monday) != nil {
+		t.Error("Expected nil for a place with no hours")
+	}
+
+	alwaysOpen := &OpeningHours{Periods: []OpeningPeriod{{Open: OpeningPoint{Day: 0}}}}
+	if got := IsOpenAt(alwaysOpen, monday); got == nil || !*got {
+		t.Error("Expected a period without a close to always be open")
+	}
+}
+
+func TestSetOpenAtArrival(t *testing.T) {
+	// Arrive 10am Monday in New York, which is 3pm UTC
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	sc := &SuperchargerWithETA{
+		arrival: time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC),
+		Restaurants: []db.RestaurantWithDistance{
+			{Restaurant: db.Restaurant{PlaceID: "breakfast", OpeningHours: &OpeningHours{Periods: []OpeningPeriod{
+				{Open: OpeningPoint{Day: 1, Hour: 7}, Close: &OpeningPoint{Day: 1, Hour: 11}},
+			}}}},
+			{Restaurant: db.Restaurant{PlaceID: "unknown"}},
+		},
+	}
+	shared := sc.Restaurants
+
+	SetOpenAtArrival(sc, newYork)
+
+	if got := sc.Restaurants[0].OpenAtArrival; got == nil || !*got {
+		t.Errorf("Expected breakfast place to be open at local arrival time, got %v", got)
+	}
+	if sc.Restaurants[1].OpenAtArrival != nil {
+		t.Error("Expected restaurant without hours to be left unset")
+	}
+	if shared[0].OpenAtArrival != nil {
+		t.Error("Expected the original restaurants to be left alone")
+	}
+}
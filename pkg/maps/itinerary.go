@@ -0,0 +1,113 @@
+package maps
+
+import (
+	"sort"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+// Itinerary is a compact trip plan: origin, charging stops, then destination
+type Itinerary struct {
+	Stops                []ItineraryStop `json:"stops"`
+	TotalDistanceMeters  int             `json:"total_distance_meters"`
+	TotalDurationSeconds int             `json:"total_duration_seconds"`
+}
+
+// ItineraryStopType identifies what an itinerary stop is
+type ItineraryStopType string
+
+const (
+	ItineraryOrigin      ItineraryStopType = "origin"
+	ItineraryCharger     ItineraryStopType = "charger"
+	ItineraryDestination ItineraryStopType = "destination"
+)
+
+// ItineraryStop is a single point on the itinerary timeline. Leg values cover the
+// drive from the previous stop.
+type ItineraryStop struct {
+	Type                     ItineraryStopType          `json:"type"`
+	Name                     string                     `json:"name"`
+	Address                  string                     `json:"address,omitempty"`
+	Location                 Center                     `json:"location"`
+	ArrivalTime              string                     `json:"arrival_time,omitempty"`
+	CumulativeDistanceMeters float64                    `json:"cumulative_distance_meters"`
+	LegDistanceMeters        float64                    `json:"leg_distance_meters"`
+	LegDurationSeconds       int                        `json:"leg_duration_seconds"`
+	TopRestaurant            *db.RestaurantWithDistance `json:"top_restaurant,omitempty"`
+}
+
+// BuildItinerary assembles an itinerary from the route result and the chosen charging stops.
+// Leg durations are estimated from the route's average speed.
+func BuildItinerary(result *SuperchargersOnRouteResult, stops []SuperchargerWithETA) Itinerary {
+	route := result.Route
+	itinerary := Itinerary{
+		TotalDistanceMeters:  route.DistanceMeters,
+		TotalDurationSeconds: int(route.Duration.Seconds()),
+	}
+
+	// The route's end points come from the polyline since we only have the query strings
+	var start, end Center
+	if points, err := route.Path(); err == nil && len(points) > 0 {
+		start, end = points[0], points[len(points)-1]
+	}
+
+	legDuration := func(legDistance float64) int {
+		if route.DistanceMeters <= 0 {
+			return 0
+		}
+		return int(route.Duration.Seconds() * legDistance / float64(route.DistanceMeters))
+	}
+
+	itinerary.Stops = append(itinerary.Stops, ItineraryStop{
+		Type:     ItineraryOrigin,
+		Name:     result.Origin,
+		Location: start,
+	})
+
+	ordered := make([]SuperchargerWithETA, len(stops))
+	copy(ordered, stops)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].DistanceAlongRoute < ordered[j].DistanceAlongRoute
+	})
+
+	previous := 0.0
+	for _, stop := range ordered {
+		leg := stop.DistanceAlongRoute - previous
+		itinerary.Stops = append(itinerary.Stops, ItineraryStop{
+			Type:                     ItineraryCharger,
+			Name:                     stop.Supercharger.Name,
+			Address:                  stop.Supercharger.Address,
+			Location:                 Center{Latitude: stop.Supercharger.Latitude, Longitude: stop.Supercharger.Longitude},
+			ArrivalTime:              stop.ArrivalTime,
+			CumulativeDistanceMeters: stop.DistanceAlongRoute,
+			LegDistanceMeters:        leg,
+			LegDurationSeconds:       legDuration(leg),
+			TopRestaurant:            topRestaurant(stop.Restaurants),
+		})
+		previous = stop.DistanceAlongRoute
+	}
+
+	leg := float64(route.DistanceMeters) - previous
+	itinerary.Stops = append(itinerary.Stops, ItineraryStop{
+		Type:                     ItineraryDestination,
+		Name:                     result.Destination,
+		Location:                 end,
+		CumulativeDistanceMeters: float64(route.DistanceMeters),
+		LegDistanceMeters:        leg,
+		LegDurationSeconds:       legDuration(leg),
+	})
+
+	return itinerary
+}
+
+// topRestaurant picks the best rated restaurant, preferring the closest on ties
+func topRestaurant(restaurants []db.RestaurantWithDistance) *db.RestaurantWithDistance {
+	var best *db.RestaurantWithDistance
+	for i := range restaurants {
+		r := &restaurants[i]
+		if best == nil || r.Rating > best.Rating || (r.Rating == best.Rating && r.Distance < best.Distance) {
+			best = r
+		}
+	}
+	return best
+}
@@ -0,0 +1,66 @@
+package maps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestBuildItinerary(t *testing.T) {
+	result := &SuperchargersOnRouteResult{
+		Origin:      "Mountain View, CA",
+		Destination: "Los Angeles, CA",
+		Route: &RouteInfo{
+			DistanceMeters:  300000,
+			Duration:        3 * time.Hour,
+			EncodedPolyline: "_p~iF~ps|U_ulLnnqC_mqNvxq`@",
+		},
+	}
+	stops := []SuperchargerWithETA{
+		{
+			Supercharger:       &db.Supercharger{Name: "Second"},
+			DistanceAlongRoute: 200000,
+		},
+		{
+			Supercharger:       &db.Supercharger{Name: "First"},
+			DistanceAlongRoute: 100000,
+			Restaurants: []db.RestaurantWithDistance{
+				{Restaurant: db.Restaurant{Name: "Okay", Rating: 3.9}, Distance: 50},
+				{Restaurant: db.Restaurant{Name: "Far", Rating: 4.5}, Distance: 400},
+				{Restaurant: db.Restaurant{Name: "Near", Rating: 4.5}, Distance: 100},
+			},
+		},
+	}
+
+	itinerary := BuildItinerary(result, stops)
+
+	if len(itinerary.Stops) != 4 {
+		t.Fatalf("Expected 4 stops, got %d", len(itinerary.Stops))
+	}
+	wantNames := []string{"Mountain View, CA", "First", "Second", "Los Angeles, CA"}
+	for i, want := range wantNames {
+		if itinerary.Stops[i].Name != want {
+			t.Errorf("stop %d: expected %s, got %s", i, want, itinerary.Stops[i].Name)
+		}
+	}
+
+	first := itinerary.Stops[1]
+	if first.LegDistanceMeters != 100000 || first.LegDurationSeconds != 3600 {
+		t.Errorf("Unexpected first leg: %v m, %v s", first.LegDistanceMeters, first.LegDurationSeconds)
+	}
+	if first.TopRestaurant == nil || first.TopRestaurant.Name != "Near" {
+		t.Errorf("Expected top restaurant Near, got %+v", first.TopRestaurant)
+	}
+	if itinerary.Stops[2].TopRestaurant != nil {
+		t.Error("Expected no top restaurant for a stop without food")
+	}
+
+	destination := itinerary.Stops[3]
+	if destination.LegDistanceMeters != 100000 || destination.CumulativeDistanceMeters != 300000 {
+		t.Errorf("Unexpected final leg: %+v", destination)
+	}
+	if destination.Location.Latitude != 43.252 || destination.Location.Longitude != -126.453 {
+		t.Errorf("Expected destination at end of polyline, got %+v", destination.Location)
+	}
+}
@@ -0,0 +1,71 @@
+package maps
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// EVChargingPlaceType is the Places API type Google assigns to charging stations in every language
+const EVChargingPlaceType = "electric_vehicle_charging_station"
+
+// Locale sets the language results are returned in and the region used to interpret queries.
+// Empty fields leave the choice to Google.
+type Locale struct {
+	LanguageCode string // e.g. "de"
+	RegionCode   string // CLDR region code, e.g. "DE"
+}
+
+// String returns the locale as a BCP 47 style tag such as "de-DE", or "" for Google's default
+func (l Locale) String() string {
+	if l.RegionCode == "" {
+		return l.LanguageCode
+	}
+	return l.LanguageCode + "-" + l.RegionCode
+}
+
+// apply adds the locale to a Place Details URL as query parameters
+func (l Locale) apply(rawURL string) string {
+	params := url.Values{}
+	if l.LanguageCode != "" {
+		params.Set("languageCode", l.LanguageCode)
+	}
+	if l.RegionCode != "" {
+		params.Set("regionCode", l.RegionCode)
+	}
+	if len(params) == 0 {
+		return rawURL
+	}
+	return rawURL + "?" + params.Encode()
+}
+
+// DefaultMinMatchConfidence is the lowest confidence accepted as a supercharger: either "supercharger" in the
+// name, or a charging station carrying the Tesla brand
+const DefaultMinMatchConfidence = 0.5
+
+// superchargerConfidence scores how much a place looks like a Tesla supercharger, from 0 to 1. Text search
+// for "tesla supercharger" also returns loosely related places, so the name is compared to the query and the
+// place types checked. Names are localised, so the brand on a charging station counts as much as the English name.
+func superchargerConfidence(place *PlaceDetails) float64 {
+	name := ""
+	if place.DisplayName != nil {
+		name = strings.ToLower(place.DisplayName.Text)
+	}
+
+	confidence := 0.0
+	if strings.Contains(name, "supercharger") {
+		confidence += 0.5
+	}
+	if strings.Contains(name, "tesla") {
+		confidence += 0.25
+	}
+	if slices.Contains(place.Types, EVChargingPlaceType) {
+		confidence += 0.25
+	}
+	return confidence
+}
+
+// isSupercharger reports whether a place looks like a Tesla supercharger at the default confidence
+func isSupercharger(place *PlaceDetails) bool {
+	return superchargerConfidence(place) >= DefaultMinMatchConfidence
+}
@@ -0,0 +1,89 @@
+package maps
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsSupercharger(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []string
+		want  bool
+	}{
+		{"Tesla Supercharger", nil, true},
+		{"Tesla Kompressor", []string{EVChargingPlaceType}, true},
+		{"Tesla Superchargeur", []string{EVChargingPlaceType, "point_of_interest"}, true},
+		{"Tesla Service Center", []string{"car_repair"}, false},
+		{"Ionity Ladestation", []string{EVChargingPlaceType}, false},
+	}
+	for _, tt := range tests {
+		place := &PlaceDetails{DisplayName: &DisplayNameObj{Text: tt.name}, Types: tt.types}
+		if got := isSupercharger(place); got != tt.want {
+			t.Errorf("isSupercharger(%q, %v) = %v, want %v", tt.name, tt.types, got, tt.want)
+		}
+	}
+	if isSupercharger(&PlaceDetails{}) {
+		t.Error("Expected place without a name not to be a supercharger")
+	}
+}
+
+func TestSuperchargerConfidence(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []string
+		want  float64
+	}{
+		{"Tesla Supercharger", []string{EVChargingPlaceType}, 1},
+		{"Supercharger", nil, 0.5},
+		{"Tesla Kompressor", []string{EVChargingPlaceType}, 0.5},
+		{"Tesla Destination Charger", nil, 0.25},
+		{"Shell", []string{"gas_station"}, 0},
+	}
+	for _, tt := range tests {
+		place := &PlaceDetails{DisplayName: &DisplayNameObj{Text: tt.name}, Types: tt.types}
+		if got := superchargerConfidence(place); got != tt.want {
+			t.Errorf("superchargerConfidence(%q, %v) = %v, want %v", tt.name, tt.types, got, tt.want)
+		}
+	}
+}
+
+func TestLocaleSentToPlaces(t *testing.T) {
+	var detailsQuery string
+	var searchBody requestBody
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			detailsQuery = r.URL.RawQuery
+			w.Write([]byte(`{"id":"ChIJlocalePlace"}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&searchBody)
+		w.Write([]byte(`{"places":[]}`))
+	}))
+	defer server.Close()
+
+	originalDetails, originalSearch := placeDetailsEndpoint, placesAPIEndpoint
+	defer func() {
+		placeDetailsEndpoint, placesAPIEndpoint = originalDetails, originalSearch
+	}()
+	placeDetailsEndpoint = server.URL
+	placesAPIEndpoint = server.URL
+
+	locale := Locale{LanguageCode: "de", RegionCode: "DE"}
+	if _, err := GetPlaceDetails(context.Background(), "key", "ChIJlocalePlace", "id", locale); err != nil {
+		t.Fatalf("GetPlaceDetails failed: %v", err)
+	}
+	if detailsQuery != "languageCode=de&regionCode=DE" {
+		t.Errorf("Unexpected details query: %q", detailsQuery)
+	}
+
+	if _, err := GetPlacesViaTextSearch(context.Background(), "key", "tesla supercharger", "places.id", Circle{}, locale); err != nil {
+		t.Fatalf("GetPlacesViaTextSearch failed: %v", err)
+	}
+	if searchBody.LanguageCode != "de" || searchBody.RegionCode != "DE" {
+		t.Errorf("Locale not sent with text search: %+v", searchBody)
+	}
+}
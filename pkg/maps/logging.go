@@ -0,0 +1,53 @@
+package maps
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// LogLevel controls how much the maps package logs, mirroring the db package's GORM log level
+type LogLevel int32
+
+const (
+	// LogSilent disables all maps logging
+	LogSilent LogLevel = iota + 1
+	// LogWarn logs only warnings
+	LogWarn
+	// LogInfo logs warnings and notable events such as cache misses
+	LogInfo
+	// LogDebug additionally logs per-request timing spans
+	LogDebug
+)
+
+var logLevel = int32(LogInfo)
+
+// SetLogLevel sets the maps package log level
+func SetLogLevel(level LogLevel) {
+	atomic.StoreInt32(&logLevel, int32(level))
+}
+
+// ParseLogLevel converts a level name (silent, warn, info, debug) to a LogLevel
+func ParseLogLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "silent":
+		return LogSilent, nil
+	case "warn":
+		return LogWarn, nil
+	case "info":
+		return LogInfo, nil
+	case "debug":
+		return LogDebug, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+// logf logs the message if the package log level includes level
+func logf(level LogLevel, format string, args ...interface{}) {
+	if LogLevel(atomic.LoadInt32(&logLevel)) < level {
+		return
+	}
+	log.Printf(format, args...)
+}
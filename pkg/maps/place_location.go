@@ -0,0 +1,77 @@
+package maps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+	"gorm.io/gorm"
+)
+
+// FieldMaskPlaceLocation asks for nothing but the location, the cheapest place details request
+const FieldMaskPlaceLocation = "id,location"
+
+// ErrPlaceHasNoLocation is returned when Google has no location for a place
+var ErrPlaceHasNoLocation = errors.New("place has no location")
+
+// ResolvePlaceLocation returns the coordinates of a place, such as an autocomplete result. Superchargers,
+// restaurants and places resolved before are read from the database; anything else costs a location-only
+// place details call and is stored for next time.
+func ResolvePlaceLocation(ctx context.Context, broker *db.Service, apiKey, placeID string) (*Center, error) {
+	if !IsValidPlaceID(placeID) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPlaceID, placeID)
+	}
+
+	location, err := cachedPlaceLocation(broker, placeID)
+	if err != nil {
+		return nil, err
+	}
+	if location != nil {
+		return location, nil
+	}
+
+	details, err := GetPlaceDetails(ctx, apiKey, placeID, FieldMaskPlaceLocation, Locale{})
+	if err != nil {
+		return nil, err
+	}
+	if details.Location == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPlaceHasNoLocation, placeID)
+	}
+
+	location = &Center{Latitude: details.Location.Latitude, Longitude: details.Location.Longitude}
+	if err := broker.PlaceLocation.Save(placeID, location.Latitude, location.Longitude); err != nil {
+		// Log the error but don't fail the request since we already have the data
+		log.Printf("Warning: failed to cache location of place %s: %v", placeID, err)
+	}
+	return location, nil
+}
+
+// cachedPlaceLocation looks for a place's location in each table that has one, returning nil if none do
+func cachedPlaceLocation(broker *db.Service, placeID string) (*Center, error) {
+	supercharger, err := broker.Supercharger.GetByID(placeID)
+	if err == nil {
+		return &Center{Latitude: supercharger.Latitude, Longitude: supercharger.Longitude}, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to query superchargers from database: %w", err)
+	}
+
+	restaurant, err := broker.Restaurant.GetByID(placeID)
+	if err == nil {
+		return &Center{Latitude: restaurant.Latitude, Longitude: restaurant.Longitude}, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to query restaurants from database: %w", err)
+	}
+
+	location, err := broker.PlaceLocation.GetByID(placeID)
+	if err == nil {
+		return &Center{Latitude: location.Latitude, Longitude: location.Longitude}, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to query place locations from database: %w", err)
+	}
+	return nil, nil
+}
@@ -0,0 +1,63 @@
+package maps
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestResolvePlaceLocation(t *testing.T) {
+	var calls int
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if mask := r.Header.Get("X-Goog-FieldMask"); mask != FieldMaskPlaceLocation {
+			t.Errorf("Expected the location-only field mask, got %q", mask)
+		}
+		if strings.HasSuffix(r.URL.Path, "ChIJremovedPlace") {
+			http.Error(w, `{"error":{"code":404,"status":"NOT_FOUND"}}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id":"ChIJresolvedPlace","location":{"latitude":-33.86,"longitude":151.21}}`))
+	})
+
+	broker := newTestDB(t)
+	if err := broker.Supercharger.Create(&db.Supercharger{PlaceID: "ChIJcachedSupercharger", Latitude: 37.4, Longitude: -122.1, IsSupercharger: true}); err != nil {
+		t.Fatalf("Failed to create supercharger: %v", err)
+	}
+
+	location, err := ResolvePlaceLocation(context.Background(), broker, "key", "ChIJcachedSupercharger")
+	if err != nil || *location != (Center{Latitude: 37.4, Longitude: -122.1}) || calls != 0 {
+		t.Fatalf("Expected the cached supercharger's location without a call, got %v after %d calls: %v", location, calls, err)
+	}
+
+	// looked up once, then served from the database
+	for i := 0; i < 2; i++ {
+		location, err = ResolvePlaceLocation(context.Background(), broker, "key", "ChIJresolvedPlace")
+		if err != nil || *location != (Center{Latitude: -33.86, Longitude: 151.21}) {
+			t.Fatalf("Expected the fetched location, got %v: %v", location, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 details call, got %d", calls)
+	}
+
+	if _, err := ResolvePlaceLocation(context.Background(), broker, "key", "bad id"); !errors.Is(err, ErrInvalidPlaceID) {
+		t.Errorf("Expected ErrInvalidPlaceID, got %v", err)
+	}
+	if _, err := ResolvePlaceLocation(context.Background(), broker, "key", "ChIJremovedPlace"); !errors.Is(err, ErrPlaceNotFound) {
+		t.Errorf("Expected ErrPlaceNotFound, got %v", err)
+	}
+
+	// old lookups are pruned, forcing a fresh call next time
+	if deleted, err := broker.PlaceLocation.DeleteOlderThan(time.Now().Add(time.Minute)); err != nil || deleted != 1 {
+		t.Errorf("Expected the resolved place to be pruned, deleted %d: %v", deleted, err)
+	}
+	if _, err := broker.PlaceLocation.GetByID("ChIJresolvedPlace"); err == nil {
+		t.Error("Expected the pruned place to be gone")
+	}
+}
@@ -0,0 +1,28 @@
+package maps
+
+import "errors"
+
+// ErrInvalidPlaceID is returned when a place ID is obviously malformed, before any API call is made.
+var ErrInvalidPlaceID = errors.New("invalid place ID")
+
+const (
+	minPlaceIDLength = 10
+	maxPlaceIDLength = 1024
+)
+
+// IsValidPlaceID reports whether id looks like a Google place ID.
+// Google doesn't guarantee a ChIJ prefix, so only the length and the
+// URL-safe base64 character set are checked.
+func IsValidPlaceID(id string) bool {
+	if len(id) < minPlaceIDLength || len(id) > maxPlaceIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
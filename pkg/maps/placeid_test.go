@@ -0,0 +1,38 @@
+package maps
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIsValidPlaceID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"ChIJj61dQgK6j4AR4GeTYWZsKWw", true},
+		{"GhIJQWDl0CIeQUARxks3icF8U8A", true},
+		{"EicxMyBNYXJrZXQgU3QsIFdpbG1pbmd0b24sIE5DIDI4NDAxLCBVU0EiGhIYChQKEgnRTo6ixx-qiRHo_bbmkCm7ZRAN", true},
+		{"", false},
+		{"ChIJ", false},
+		{"ChIJj61dQgK6j4AR4GeTYWZsKWw/../details", false},
+		{"ChIJj61dQgK6 j4AR4GeTYWZsKWw", false},
+		{"ChIJj61dQgK6j4AR4GeTYWZsKWw?key=x", false},
+		{strings.Repeat("a", maxPlaceIDLength+1), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidPlaceID(tt.id); got != tt.want {
+			t.Errorf("IsValidPlaceID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestGetPlaceDetailsRejectsInvalidPlaceID(t *testing.T) {
+	_, err := GetPlaceDetails(context.Background(), "key", "not a place", FieldMaskSuperchargerDetails, Locale{})
+	if !errors.Is(err, ErrInvalidPlaceID) {
+		t.Errorf("Expected ErrInvalidPlaceID, got %v", err)
+	}
+}
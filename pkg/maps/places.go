@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
 // Making the endpoint and client package-level variables allows us to
@@ -14,13 +16,46 @@ import (
 var (
 	placesAPIEndpoint    = "https://places.googleapis.com/v1/places:searchText"
 	placeDetailsEndpoint = "https://places.googleapis.com/v1/places"
+	placesNearbyEndpoint = "https://places.googleapis.com/v1/places:searchNearby"
 	httpClient           = &http.Client{}
+	userAgent            = "PassengerPrincess/1.0"
 )
 
+// ErrInvalidFieldMask is returned when Google rejects a request's field mask, because it is malformed or
+// asks for fields the endpoint doesn't offer. It is a programming error rather than an outage, so retrying
+// won't help.
+var ErrInvalidFieldMask = errors.New("invalid field mask")
+
+// ErrPlaceNotFound is returned when Google has no place with the requested ID, because it never existed or
+// has since been removed
+var ErrPlaceNotFound = errors.New("place not found")
+
+// SetUserAgent sets the User-Agent sent with every outbound Google request.
+// It should be called once at startup, before any requests are made.
+func SetUserAgent(ua string) {
+	userAgent = ua
+}
+
 // requestBody represents the JSON structure for the Google Places API searchText request.
 type requestBody struct {
 	TextQuery    string       `json:"textQuery"`
 	LocationBias LocationBias `json:"locationBias"`
+	LanguageCode string       `json:"languageCode,omitempty"`
+	RegionCode   string       `json:"regionCode,omitempty"`
+}
+
+// nearbyRequestBody represents the JSON structure for the Google Places API searchNearby request.
+type nearbyRequestBody struct {
+	IncludedTypes       []string            `json:"includedTypes,omitempty"`
+	RankPreference      string              `json:"rankPreference,omitempty"`
+	LocationRestriction LocationRestriction `json:"locationRestriction"`
+	LanguageCode        string              `json:"languageCode,omitempty"`
+	RegionCode          string              `json:"regionCode,omitempty"`
+}
+
+// LocationRestriction limits results to the given circle, unlike LocationBias which only prefers it.
+type LocationRestriction struct {
+	Circle Circle `json:"circle"`
 }
 
 type LocationBias struct {
@@ -57,6 +92,10 @@ type PlaceDetails struct {
 	Location               *Location       `json:"location,omitempty"`
 	PrimaryType            *string         `json:"primaryType,omitempty"`
 	PrimaryTypeDisplayName *DisplayNameObj `json:"primaryTypeDisplayName,omitempty"`
+	Types                  []string        `json:"types,omitempty"`
+	RegularOpeningHours    *OpeningHours   `json:"regularOpeningHours,omitempty"`
+	Rating                 *float64        `json:"rating,omitempty"`
+	UserRatingCount        *int            `json:"userRatingCount,omitempty"`
 }
 
 type Location struct {
@@ -66,10 +105,12 @@ type Location struct {
 
 // GetPlacesViaTextSearch queries the Google Places API (Text Search - New) to find all places
 // matching a query within a specified circular search area. It now takes a 'circle' struct directly.
-func GetPlacesViaTextSearch(ctx context.Context, apiKey, query, fieldMask string, targetCircle Circle) ([]*PlaceDetails, error) {
+func GetPlacesViaTextSearch(ctx context.Context, apiKey, query, fieldMask string, targetCircle Circle, locale Locale) (places []*PlaceDetails, err error) {
 	reqBody := requestBody{
 		TextQuery:    query,
 		LocationBias: LocationBias{Circle: targetCircle},
+		LanguageCode: locale.LanguageCode,
+		RegionCode:   locale.RegionCode,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -77,6 +118,9 @@ func GetPlacesViaTextSearch(ctx context.Context, apiKey, query, fieldMask string
 		return nil, fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+
 	req, err := http.NewRequestWithContext(ctx, "POST", placesAPIEndpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create http request: %w", err)
@@ -85,11 +129,14 @@ func GetPlacesViaTextSearch(ctx context.Context, apiKey, query, fieldMask string
 	// The FieldMask is crucial for performance and cost-effectiveness.
 	// It tells Google to only return the data we absolutely need.
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("X-Goog-Api-Key", apiKey)
 	req.Header.Set("X-Goog-FieldMask", fieldMask)
 
+	countCall(SKUTextSearch)
+	defer func() { logCall(SKUTextSearch, "", query, err) }()
 	// 5. Execute the request using the package-level client.
-	resp, err := httpClient.Do(req)
+	resp, err := doWithRetry(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request to Google Places API: %w", err)
 	}
@@ -101,7 +148,69 @@ func GetPlacesViaTextSearch(ctx context.Context, apiKey, query, fieldMask string
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("google places api returned an error. status: %s, body: %s", resp.Status, string(bodyBytes))
+		return nil, placesAPIError(resp, bodyBytes, fieldMask)
+	}
+
+	var apiResp apiResponse
+	if err := json.Unmarshal(bodyBytes, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response json: %w", err)
+	}
+
+	for _, p := range apiResp.Places {
+		if p.ID == "" {
+			return nil, fmt.Errorf("place ID is missing for a place")
+		}
+	}
+
+	return apiResp.Places, nil
+}
+
+// GetPlacesNearby queries the Google Places API (Nearby Search - New) for places of the given types
+// within a circle. It is cheaper than text search when filtering by type rather than free text.
+func GetPlacesNearby(ctx context.Context, apiKey string, center Center, radius float64, includedTypes []string, fieldMask string, locale Locale) (places []*PlaceDetails, err error) {
+	reqBody := nearbyRequestBody{
+		IncludedTypes:  includedTypes,
+		RankPreference: "DISTANCE",
+		LocationRestriction: LocationRestriction{
+			Circle: Circle{Center: center, Radius: radius},
+		},
+		LanguageCode: locale.LanguageCode,
+		RegionCode:   locale.RegionCode,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", placesNearbyEndpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Goog-Api-Key", apiKey)
+	req.Header.Set("X-Goog-FieldMask", fieldMask)
+
+	countCall(SKUNearbySearch)
+	defer func() { logCall(SKUNearbySearch, "", strings.Join(includedTypes, ","), err) }()
+	resp, err := doWithRetry(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Google Places API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, placesAPIError(resp, bodyBytes, fieldMask)
 	}
 
 	var apiResp apiResponse
@@ -119,18 +228,28 @@ func GetPlacesViaTextSearch(ctx context.Context, apiKey, query, fieldMask string
 }
 
 // GetPlaceDetails retrieves essential place information from Google Places API given a place ID
-func GetPlaceDetails(ctx context.Context, apiKey, placeID, fieldMask string) (*PlaceDetails, error) {
-	url := fmt.Sprintf("%s/%s", placeDetailsEndpoint, placeID)
+func GetPlaceDetails(ctx context.Context, apiKey, placeID, fieldMask string, locale Locale) (details *PlaceDetails, err error) {
+	if !IsValidPlaceID(placeID) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPlaceID, placeID)
+	}
+
+	url := locale.apply(fmt.Sprintf("%s/%s", placeDetailsEndpoint, placeID))
+
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create http request: %w", err)
 	}
 
+	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("X-Goog-Api-Key", apiKey)
 	req.Header.Set("X-Goog-FieldMask", fieldMask)
 
-	resp, err := httpClient.Do(req)
+	countCall(SKUPlaceDetails)
+	defer func() { logCall(SKUPlaceDetails, placeID, fieldMask, err) }()
+	resp, err := doWithRetry(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request to Google Places API: %w", err)
 	}
@@ -142,7 +261,7 @@ func GetPlaceDetails(ctx context.Context, apiKey, placeID, fieldMask string) (*P
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("google places api returned an error. status: %s, body: %s", resp.Status, string(bodyBytes))
+		return nil, placesAPIError(resp, bodyBytes, fieldMask)
 	}
 
 	var placeDetails PlaceDetails
@@ -153,3 +272,35 @@ func GetPlaceDetails(ctx context.Context, apiKey, placeID, fieldMask string) (*P
 
 	return &placeDetails, nil
 }
+
+// placesAPIError describes a failed Places API response, wrapping ErrInvalidFieldMask when Google
+// rejected the field mask and ErrPlaceNotFound when it has no such place, so callers can tell them from
+// an upstream failure
+func placesAPIError(resp *http.Response, body []byte, fieldMask string) error {
+	if resp.StatusCode == http.StatusBadRequest && isFieldMaskError(body) {
+		return fmt.Errorf("%w %q: %s", ErrInvalidFieldMask, fieldMask, string(body))
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrPlaceNotFound, string(body))
+	}
+	return fmt.Errorf("google places api returned an error. status: %s, body: %s", resp.Status, string(body))
+}
+
+// isFieldMaskError reports whether a Google error body is an INVALID_ARGUMENT blaming the field mask,
+// such as "Error expanding 'fields' parameter" or "FieldMask is a required parameter"
+func isFieldMaskError(body []byte) bool {
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return false
+	}
+	if apiErr.Error.Status != "INVALID_ARGUMENT" {
+		return false
+	}
+	message := strings.ToLower(apiErr.Error.Message)
+	return strings.Contains(message, "field mask") || strings.Contains(message, "fieldmask") || strings.Contains(message, "'fields'")
+}
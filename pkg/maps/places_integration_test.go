@@ -4,8 +4,6 @@ import (
 	"context"
 	"os"
 	"testing"
-
-	"github.com/brensch/passengerprincess/pkg/db"
 )
 
 // TestGetPlacesViaTextSearch makes an actual call to Google Places API
@@ -28,7 +26,7 @@ func TestGetPlacesViaTextSearch(t *testing.T) {
 	}
 
 	// Call the real API
-	places, err := GetPlacesViaTextSearch(context.Background(), apiKey, query, FieldMaskRestaurantTextSearch, targetCircle)
+	places, err := GetPlacesViaTextSearch(context.Background(), apiKey, query, FieldMaskRestaurantTextSearch, targetCircle, Locale{})
 	if err != nil {
 		t.Fatalf("GetPlaceIDsViaTextSearch failed: %v", err)
 	}
@@ -70,23 +68,13 @@ func TestGetSuperchargerWithCache(t *testing.T) {
 		t.Skip("MAPS_API_KEY not set, skipping integration test")
 	}
 
-	// Initialize in-memory database for testing
-	err := db.Initialize(&db.Config{
-		DatabasePath: ":memory:",
-		LogLevel:     4, // Silent
-	})
-	if err != nil {
-		t.Fatalf("Failed to initialize database: %v", err)
-	}
-	defer db.Close()
-
-	broker := db.GetDefaultService()
+	broker := newTestDB(t)
 
 	// Test with a known place ID (Googleplex)
 	placeID := "ChIJj61dQgK6j4AR4GeTYWZsKWw"
 
 	// Call the cached version (will fetch from API and cache in DB)
-	supercharger, err := GetSuperchargerWithCache(context.Background(), broker, apiKey, placeID)
+	supercharger, _, err := GetSuperchargerWithCache(context.Background(), broker, apiKey, placeID, nil)
 	if err != nil {
 		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
 	}
@@ -129,7 +117,7 @@ func TestGetSuperchargerWithCache(t *testing.T) {
 
 	// Test caching: Call again, should get from database this time
 	t.Logf("Testing cache - calling again for same place ID...")
-	supercharger2, err := GetSuperchargerWithCache(context.Background(), broker, apiKey, placeID)
+	supercharger2, _, err := GetSuperchargerWithCache(context.Background(), broker, apiKey, placeID, nil)
 	if err != nil {
 		t.Fatalf("Second call to GetSuperchargerWithCache failed: %v", err)
 	}
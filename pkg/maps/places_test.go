@@ -0,0 +1,114 @@
+package maps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetPlaceDetailsUserAgent(t *testing.T) {
+	var gotUserAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserAgent = r.Header.Get("User-Agent")
+		w.Write([]byte(`{"id":"ChIJtestPlace"}`))
+	}))
+	defer server.Close()
+
+	originalEndpoint, originalUserAgent := placeDetailsEndpoint, userAgent
+	defer func() {
+		placeDetailsEndpoint, userAgent = originalEndpoint, originalUserAgent
+	}()
+	placeDetailsEndpoint = server.URL
+	SetUserAgent("PassengerPrincessTest/1.0")
+
+	if _, err := GetPlaceDetails(context.Background(), "key", "ChIJtestPlace", "id", Locale{}); err != nil {
+		t.Fatalf("GetPlaceDetails failed: %v", err)
+	}
+	if gotUserAgent != "PassengerPrincessTest/1.0" {
+		t.Errorf("Expected configured User-Agent, got %q", gotUserAgent)
+	}
+}
+
+func TestGetPlacesNearby(t *testing.T) {
+	var gotBody nearbyRequestBody
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.Write([]byte(`{"places":[{"id":"ChIJrestaurant"}]}`))
+	}))
+	defer server.Close()
+
+	originalEndpoint := placesNearbyEndpoint
+	defer func() { placesNearbyEndpoint = originalEndpoint }()
+	placesNearbyEndpoint = server.URL
+
+	center := Center{Latitude: 37.4, Longitude: -122.1}
+	places, err := GetPlacesNearby(context.Background(), "key", center, 500, []string{RestaurantPlaceType}, FieldMaskRestaurantTextSearch, Locale{})
+	if err != nil {
+		t.Fatalf("GetPlacesNearby failed: %v", err)
+	}
+	if len(places) != 1 || places[0].ID != "ChIJrestaurant" {
+		t.Errorf("Unexpected places: %+v", places)
+	}
+
+	if len(gotBody.IncludedTypes) != 1 || gotBody.IncludedTypes[0] != RestaurantPlaceType {
+		t.Errorf("Expected includedTypes [%s], got %v", RestaurantPlaceType, gotBody.IncludedTypes)
+	}
+	if gotBody.LocationRestriction.Circle.Center != center || gotBody.LocationRestriction.Circle.Radius != 500 {
+		t.Errorf("Unexpected location restriction: %+v", gotBody.LocationRestriction)
+	}
+}
+
+func TestGetPlaceDetailsInvalidFieldMask(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantMaskErr bool
+	}{
+		{
+			name:        "unknown field",
+			body:        `{"error":{"code":400,"message":"Error expanding 'fields' parameter. Cannot find matching fields for path 'bogus'.","status":"INVALID_ARGUMENT"}}`,
+			wantMaskErr: true,
+		},
+		{
+			name:        "missing mask",
+			body:        `{"error":{"code":400,"message":"FieldMask is a required parameter. See https://cloud.google.com/apis/docs/system-parameters on how to provide it.","status":"INVALID_ARGUMENT"}}`,
+			wantMaskErr: true,
+		},
+		{
+			name:        "other invalid argument",
+			body:        `{"error":{"code":400,"message":"Invalid languageCode.","status":"INVALID_ARGUMENT"}}`,
+			wantMaskErr: false,
+		},
+	}
+
+	originalEndpoint := placeDetailsEndpoint
+	defer func() { placeDetailsEndpoint = originalEndpoint }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			placeDetailsEndpoint = server.URL
+
+			_, err := GetPlaceDetails(context.Background(), "key", "ChIJtestPlace", "id,bogus", Locale{})
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if got := errors.Is(err, ErrInvalidFieldMask); got != tt.wantMaskErr {
+				t.Fatalf("errors.Is(err, ErrInvalidFieldMask) = %v, want %v (err: %v)", got, tt.wantMaskErr, err)
+			}
+			if tt.wantMaskErr && !strings.Contains(err.Error(), `"id,bogus"`) {
+				t.Errorf("Expected the offending mask in the error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package maps
+
+import (
+	"sort"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+// RoadDetourFactor is how much longer a typical drive is than the straight line between two points.
+// Chargers within range in a straight line but not once this detour is added are flagged as borderline.
+const RoadDetourFactor = 1.3
+
+// ReachableSupercharger is a supercharger within straight-line range of the driver
+type ReachableSupercharger struct {
+	db.Supercharger
+	Distance float64 `json:"distance"` // straight-line distance from the driver in meters
+	// Borderline is true when the charger is in straight-line range but may be out of range by road
+	Borderline bool `json:"borderline"`
+}
+
+// FilterByRange drops superchargers beyond straight-line range of from, which no road can beat,
+// and flags those that may be out of reach once roads are taken into account. The result is
+// ordered by distance, closest first. It is a cheap "can I make it" check that needs no routing.
+func FilterByRange(superchargers []db.Supercharger, from Center, rangeMeters float64) []ReachableSupercharger {
+	reachable := make([]ReachableSupercharger, 0, len(superchargers))
+	for _, sc := range superchargers {
+		distance := haversineDistance(from, Center{Latitude: sc.Latitude, Longitude: sc.Longitude})
+		if distance > rangeMeters {
+			continue
+		}
+		reachable = append(reachable, ReachableSupercharger{
+			Supercharger: sc,
+			Distance:     distance,
+			Borderline:   distance*RoadDetourFactor > rangeMeters,
+		})
+	}
+
+	sort.SliceStable(reachable, func(i, j int) bool {
+		if reachable[i].Distance != reachable[j].Distance {
+			return reachable[i].Distance < reachable[j].Distance
+		}
+		return reachable[i].PlaceID < reachable[j].PlaceID
+	})
+	return reachable
+}
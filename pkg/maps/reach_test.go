@@ -0,0 +1,32 @@
+package maps
+
+import (
+	"testing"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestFilterByRange(t *testing.T) {
+	from := Center{Latitude: 0, Longitude: 0}
+	// 0.1 degrees of latitude is about 11.1km
+	superchargers := []db.Supercharger{
+		{PlaceID: "unreachable", Latitude: 0.5},
+		{PlaceID: "borderline", Latitude: 0.4},
+		{PlaceID: "close", Latitude: 0.1},
+	}
+
+	reachable := FilterByRange(superchargers, from, 50000)
+
+	if len(reachable) != 2 {
+		t.Fatalf("Expected 2 reachable superchargers, got %d", len(reachable))
+	}
+	if reachable[0].PlaceID != "close" || reachable[0].Borderline {
+		t.Errorf("Expected the close charger first and not borderline, got %+v", reachable[0])
+	}
+	if reachable[1].PlaceID != "borderline" || !reachable[1].Borderline {
+		t.Errorf("Expected the 44km charger to be borderline, got %+v", reachable[1])
+	}
+	if reachable[0].Distance < 11000 || reachable[0].Distance > 11200 {
+		t.Errorf("Unexpected distance %v", reachable[0].Distance)
+	}
+}
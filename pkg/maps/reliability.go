@@ -0,0 +1,52 @@
+package maps
+
+import (
+	"errors"
+	"log"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+// recordFetchOutcome notes how a details lookup went so flaky or defunct sites can be ranked lower. Only
+// failures that say something about the site itself, Google not knowing the place, count against it; rate
+// limits, outages, timeouts and our own network errors are ignored. Lookups with classify set also record
+// whether the place is a supercharger, judged against DefaultMinMatchConfidence so a request's own
+// threshold doesn't count as the site changing.
+func recordFetchOutcome(broker *db.Service, placeID string, details *PlaceDetails, classify bool, err error) {
+	var recordErr error
+	switch {
+	case errors.Is(err, ErrPlaceNotFound):
+		recordErr = broker.FetchStats.RecordFailure(placeID)
+	case err != nil:
+		return
+	case classify:
+		recordErr = broker.FetchStats.RecordSuccess(placeID, superchargerConfidence(details) >= DefaultMinMatchConfidence)
+	}
+	if recordErr != nil {
+		log.Printf("Warning: failed to record fetch outcome for %s: %v", placeID, recordErr)
+	}
+}
+
+// setReliability fills in each charger's Reliability from its fetch history, in one query for them all
+func setReliability(broker *db.Service, superchargers []SuperchargerWithETA) {
+	ids := make([]string, 0, len(superchargers))
+	for i := range superchargers {
+		superchargers[i].Reliability = 1
+		ids = append(ids, superchargers[i].Supercharger.PlaceID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	stats, err := broker.FetchStats.GetByIDs(ids)
+	if err != nil {
+		// every charger keeps full reliability rather than failing the route
+		log.Printf("Warning: failed to load fetch history: %v", err)
+		return
+	}
+	for i := range superchargers {
+		if s, ok := stats[superchargers[i].Supercharger.PlaceID]; ok {
+			superchargers[i].Reliability = s.Reliability()
+		}
+	}
+}
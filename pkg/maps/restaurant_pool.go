@@ -0,0 +1,85 @@
+package maps
+
+import (
+	"context"
+	"sync"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+// restaurantPoolToleranceMeters is how close two superchargers must be to share a restaurant search.
+// Sites are often listed more than once, and dense clusters put chargers across the road from each other.
+// Restaurants just inside the second charger's radius but outside the first's are missed, which is
+// why the tolerance is kept small.
+const restaurantPoolToleranceMeters = 50.0
+
+// restaurantSearch is a restaurant search around one location, shared with searches close enough to reuse it
+type restaurantSearch struct {
+	center      Center
+	radius      float64
+	ready       chan struct{} // closed once restaurants and err are set
+	restaurants []db.RestaurantWithDistance
+	err         error
+}
+
+// restaurantPool remembers the restaurant searches made while planning one route, so superchargers
+// next to each other share a single search instead of each paying for their own.
+// A nil pool doesn't share anything.
+type restaurantPool struct {
+	mu       sync.Mutex
+	searches []*restaurantSearch
+}
+
+func newRestaurantPool() *restaurantPool {
+	return &restaurantPool{}
+}
+
+// fetch returns the restaurants within radius of location, reusing a search from a supercharger
+// within restaurantPoolToleranceMeters if one has been made or is in flight.
+func (p *restaurantPool) fetch(ctx context.Context, apiKey, placeID string, location Center, locale Locale, radius float64) ([]db.RestaurantWithDistance, error) {
+	if p == nil {
+		return fetchRestaurantsNear(ctx, apiKey, placeID, location, locale, radius)
+	}
+
+	p.mu.Lock()
+	for _, search := range p.searches {
+		if search.radius < radius || haversineDistance(search.center, location) > restaurantPoolToleranceMeters {
+			continue
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-search.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if search.err != nil {
+			// the shared search failed, it may have been cancelled with its own request so try ours
+			return fetchRestaurantsNear(ctx, apiKey, placeID, location, locale, radius)
+		}
+		logf(LogDebug, "Reusing restaurant search for %s", placeID)
+		return search.near(location, radius), nil
+	}
+
+	search := &restaurantSearch{center: location, radius: radius, ready: make(chan struct{})}
+	p.searches = append(p.searches, search)
+	p.mu.Unlock()
+
+	search.restaurants, search.err = fetchRestaurantsNear(ctx, apiKey, placeID, location, locale, radius)
+	close(search.ready)
+	return search.restaurants, search.err
+}
+
+// near returns the search's restaurants within radius of location, with distances measured from there
+func (s *restaurantSearch) near(location Center, radius float64) []db.RestaurantWithDistance {
+	var restaurants []db.RestaurantWithDistance
+	for _, restaurant := range s.restaurants {
+		dist := haversineDistance(location, Center{Latitude: restaurant.Latitude, Longitude: restaurant.Longitude})
+		if dist > radius {
+			continue
+		}
+		restaurant.Distance = dist
+		restaurants = append(restaurants, restaurant)
+	}
+	return restaurants
+}
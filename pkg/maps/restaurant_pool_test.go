@@ -0,0 +1,64 @@
+package maps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRestaurantPoolSharesNearbySearches(t *testing.T) {
+	var nearbyCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&nearbyCalls, 1)
+		w.Write([]byte(`{"places":[{"id":"ChIJfood","displayName":{"text":"Diner"},"location":{"latitude":37.4003,"longitude":-122.1}}]}`))
+	}))
+	defer server.Close()
+
+	originalNearby := placesNearbyEndpoint
+	defer func() { placesNearbyEndpoint = originalNearby }()
+	placesNearbyEndpoint = server.URL
+
+	pool := newRestaurantPool()
+	// two listings for the same site, about 11m apart
+	locations := []Center{{Latitude: 37.4, Longitude: -122.1}, {Latitude: 37.4001, Longitude: -122.1}}
+
+	var wg sync.WaitGroup
+	results := make([][]float64, len(locations))
+	for i, location := range locations {
+		wg.Add(1)
+		go func(i int, location Center) {
+			defer wg.Done()
+			restaurants, err := pool.fetch(context.Background(), "key", "ChIJcharger", location, Locale{}, DefaultRestaurantRadiusMeters)
+			if err != nil {
+				t.Errorf("fetch failed: %v", err)
+				return
+			}
+			for _, restaurant := range restaurants {
+				results[i] = append(results[i], restaurant.Distance)
+			}
+		}(i, location)
+	}
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&nearbyCalls); calls != 1 {
+		t.Errorf("Expected 1 shared search, got %d", calls)
+	}
+	if len(results[0]) != 1 || len(results[1]) != 1 {
+		t.Fatalf("Expected both chargers to get the restaurant, got %v", results)
+	}
+	// distances are measured from each charger
+	if results[0][0] <= results[1][0] {
+		t.Errorf("Expected the restaurant to be closer to the second charger, got %v", results)
+	}
+
+	// a charger a kilometer away needs its own search
+	if _, err := pool.fetch(context.Background(), "key", "ChIJcharger", Center{Latitude: 37.41, Longitude: -122.1}, Locale{}, DefaultRestaurantRadiusMeters); err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if calls := atomic.LoadInt32(&nearbyCalls); calls != 2 {
+		t.Errorf("Expected a second search for a distant charger, got %d calls", calls)
+	}
+}
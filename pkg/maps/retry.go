@@ -0,0 +1,119 @@
+package maps
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how failed Google calls are retried. Only rate limiting (429), server errors (5xx)
+// and transient network errors are retried; anything else is the caller's mistake and fails at once.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a call is made in total, so 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubling before each one after
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between attempts
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used unless SetRetryPolicy changes it
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+	}
+}
+
+var retryPolicy atomic.Pointer[RetryPolicy]
+
+func init() {
+	policy := DefaultRetryPolicy()
+	retryPolicy.Store(&policy)
+}
+
+// SetRetryPolicy sets how outbound Google calls are retried. A MaxAttempts below 1 is treated as 1.
+func SetRetryPolicy(policy RetryPolicy) {
+	retryPolicy.Store(&policy)
+}
+
+// delay returns the wait before retry number retry, counting from 1: exponential backoff with jitter so
+// that concurrent callers rate limited together don't all retry at the same moment
+func (p RetryPolicy) delay(retry int) time.Duration {
+	backoff := p.BaseDelay << (retry - 1)
+	if backoff <= 0 || (p.MaxDelay > 0 && backoff > p.MaxDelay) {
+		// a large retry count overflows the shift
+		backoff = p.MaxDelay
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// doWithRetry sends req with httpClient, retrying under the retry policy. Once attempts run out the last
+// response is returned for the caller to report as usual, or the last error if there was no response.
+// Waits between attempts end early, returning the context's error, if the request's context is done.
+func doWithRetry(req *http.Request) (*http.Response, error) {
+	policy := *retryPolicy.Load()
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := httpClient.Do(req)
+		if attempt >= policy.MaxAttempts || !shouldRetry(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			// drain the body so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		// the body was consumed by the last attempt, so send a fresh copy
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
+
+// shouldRetry reports whether a call failed in a way that may succeed if made again
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return isTransientNetError(err)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// isTransientNetError reports whether a request error is a dropped connection or a network timeout,
+// rather than a bad request or a cancelled context
+func isTransientNetError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Timeout()
+}
@@ -0,0 +1,99 @@
+package maps
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoWithRetry(t *testing.T) {
+	original := *retryPolicy.Load()
+	defer SetRetryPolicy(original)
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+
+	var calls atomic.Int32
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every attempt must resend the whole body
+		if body, _ := io.ReadAll(r.Body); string(body) != `{"q":1}` {
+			t.Errorf("Attempt %d got body %q", calls.Load()+1, body)
+		}
+		w.WriteHeader(statuses[calls.Add(1)-1])
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, strings.NewReader(`{"q":1}`))
+	resp, err := doWithRetry(req)
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("Expected 200 on the third attempt, got %d after %d", resp.StatusCode, calls.Load())
+	}
+
+	// once attempts run out the last response is handed back
+	calls.Store(0)
+	statuses = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+	req, _ = http.NewRequest("POST", server.URL, strings.NewReader(`{"q":1}`))
+	resp, err = doWithRetry(req)
+	if err != nil {
+		t.Fatalf("Expected the last response, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway || calls.Load() != 3 {
+		t.Errorf("Expected 502 after 3 attempts, got %d after %d", resp.StatusCode, calls.Load())
+	}
+
+	// client errors aren't retried
+	calls.Store(0)
+	statuses = []int{http.StatusBadRequest}
+	req, _ = http.NewRequest("POST", server.URL, strings.NewReader(`{"q":1}`))
+	resp, err = doWithRetry(req)
+	if err != nil {
+		t.Fatalf("Expected the response, got %v", err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 {
+		t.Errorf("Expected a 400 to be tried once, got %d attempts", calls.Load())
+	}
+}
+
+func TestDoWithRetryCancelledDuringBackoff(t *testing.T) {
+	original := *retryPolicy.Load()
+	defer SetRetryPolicy(original)
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+
+	start := time.Now()
+	_, err := doWithRetry(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context's error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the backoff to end with the context, took %v", elapsed)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for retry, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 5: time.Second, 70: time.Second} {
+		if d := policy.delay(retry); d < max/2 || d > max {
+			t.Errorf("Retry %d: expected a delay between %v and %v, got %v", retry, max/2, max, d)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package maps
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -17,20 +19,52 @@ const (
 	earthRadiusMeters = 6371000
 )
 
+// routesEndpoint is a package-level variable so tests can point it at a mock server.
+var routesEndpoint = "https://routes.googleapis.com/directions/v2:computeRoutes"
+
 // --- Custom Result Struct ---
 
-// EncodedPolyline contains the string representation of the route path.
+// EncodedPolyline contains the route path, as an encoded string or GeoJSON depending on the encoding requested.
 type EncodedPolyline struct {
-	EncodedPolyline string `json:"encodedPolyline"`
+	EncodedPolyline   string             `json:"encodedPolyline"`
+	GeoJSONLinestring *GeoJSONLinestring `json:"geoJsonLinestring,omitempty"`
+}
+
+// GeoJSONLinestring is a path as a GeoJSON LineString, with coordinates as [longitude, latitude] pairs
+type GeoJSONLinestring struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
+// points converts the GeoJSON coordinates to route points
+func (l *GeoJSONLinestring) points() ([]Center, error) {
+	points := make([]Center, 0, len(l.Coordinates))
+	for i, coordinate := range l.Coordinates {
+		if len(coordinate) < 2 {
+			return nil, fmt.Errorf("coordinate %d has %d values, expected longitude and latitude", i, len(coordinate))
+		}
+		points = append(points, Center{Latitude: coordinate[1], Longitude: coordinate[0]})
+	}
+	return points, nil
 }
 
 // RouteInfo is a cleaner, consolidated structure for returning the final result.
 type RouteInfo struct {
 	DistanceMeters  int
 	Duration        time.Duration
+	TypicalDuration time.Duration // Duration without traffic, zero when Google omits it
 	EncodedPolyline string
+	// Points is the path as raw coordinates, only set when the route was requested as GeoJSON.
+	// Path returns the points whichever encoding was used.
+	Points []Center `json:"Points,omitempty"`
 	// Enhanced data for traffic-aware routing
 	TravelAdvisory RouteTravelAdvisory `json:"travelAdvisory,omitempty"`
+	// RoutingPreference is the preference that produced the route, cheaper than requested after a fallback
+	RoutingPreference RoutingPreference
+	// Steps are turn-by-turn instructions, only set when RouteOptions.IncludeSteps asked for them
+	Steps []NavStep `json:"Steps,omitempty"`
+	// FetchedAt is when the route came from the Routes API, which is earlier than the search for stored routes
+	FetchedAt time.Time
 }
 
 // Enhanced route structures for traffic-aware routing
@@ -43,11 +77,30 @@ type EnhancedRouteRequest struct {
 	PolylineQuality   string          `json:"polylineQuality,omitempty"`
 	PolylineEncoding  string          `json:"polylineEncoding,omitempty"`
 	DepartureTime     string          `json:"departureTime,omitempty"`
+	RouteModifiers    *RouteModifiers `json:"routeModifiers,omitempty"`
 }
 
+// RouteModifiers are the road features a Routes API request avoids
+type RouteModifiers struct {
+	AvoidTolls    bool `json:"avoidTolls,omitempty"`
+	AvoidHighways bool `json:"avoidHighways,omitempty"`
+	AvoidFerries  bool `json:"avoidFerries,omitempty"`
+}
+
+// LocationRequest is a Routes API waypoint, given as either an address or a location
 type LocationRequest struct {
-	Address string     `json:"address,omitempty"`
-	LatLng  *LatLngReq `json:"latLng,omitempty"`
+	Address  string            `json:"address,omitempty"`
+	Location *WaypointLocation `json:"location,omitempty"`
+}
+
+// WaypointLocation is a waypoint given by coordinates
+type WaypointLocation struct {
+	LatLng LatLngReq `json:"latLng"`
+}
+
+// pointWaypoint returns a waypoint at the given location
+func pointWaypoint(location Center) LocationRequest {
+	return LocationRequest{Location: &WaypointLocation{LatLng: LatLngReq{Latitude: location.Latitude, Longitude: location.Longitude}}}
 }
 
 type LatLngReq struct {
@@ -64,6 +117,7 @@ type EnhancedRoute struct {
 	Legs           []EnhancedRouteLeg  `json:"legs"`
 	TravelAdvisory RouteTravelAdvisory `json:"travelAdvisory,omitempty"`
 	Duration       string              `json:"duration"`
+	StaticDuration string              `json:"staticDuration,omitempty"`
 	DistanceMeters int                 `json:"distanceMeters"`
 }
 
@@ -76,9 +130,24 @@ type EnhancedRouteLeg struct {
 }
 
 type EnhancedRouteStep struct {
-	Polyline       EncodedPolyline `json:"polyline"`
-	StaticDuration string          `json:"staticDuration"`
-	DistanceMeters int             `json:"distanceMeters"`
+	Polyline              EncodedPolyline        `json:"polyline"`
+	StaticDuration        string                 `json:"staticDuration"`
+	DistanceMeters        int                    `json:"distanceMeters"`
+	NavigationInstruction *NavigationInstruction `json:"navigationInstruction,omitempty"`
+}
+
+// NavigationInstruction is what the driver is told to do at the start of a step
+type NavigationInstruction struct {
+	Maneuver     string `json:"maneuver"`
+	Instructions string `json:"instructions"`
+}
+
+// NavStep is one turn-by-turn instruction along a route
+type NavStep struct {
+	Instruction     string `json:"instruction"`
+	Maneuver        string `json:"maneuver,omitempty"` // e.g. TURN_LEFT, RAMP_RIGHT
+	DistanceMeters  int    `json:"distance_meters"`
+	DurationSeconds int    `json:"duration_seconds"` // without traffic
 }
 
 type RouteTravelAdvisory struct {
@@ -95,17 +164,153 @@ type SpeedReadingInterval struct {
 	Speed                   string `json:"speed"`
 }
 
+// RoutingPreference is how much traffic the Routes API takes into account. Each level costs more than the last.
+type RoutingPreference string
+
+const (
+	// RoutingTrafficUnaware ignores live traffic, the cheapest and fastest option
+	RoutingTrafficUnaware RoutingPreference = "TRAFFIC_UNAWARE"
+	// RoutingTrafficAware uses live traffic with some optimizations for latency
+	RoutingTrafficAware RoutingPreference = "TRAFFIC_AWARE"
+	// RoutingTrafficAwareOptimal uses live traffic without latency optimizations and adds traffic to the polyline
+	RoutingTrafficAwareOptimal RoutingPreference = "TRAFFIC_AWARE_OPTIMAL"
+)
+
+// routingPreferencesByCost lists the routing preferences from most to least expensive
+var routingPreferencesByCost = []RoutingPreference{RoutingTrafficAwareOptimal, RoutingTrafficAware, RoutingTrafficUnaware}
+
+// ErrTrafficUnavailable is returned when the Routes API can't use traffic data for a route,
+// e.g. in regions without traffic coverage
+var ErrTrafficUnavailable = errors.New("traffic data unavailable")
+
+// TravelMode is the kind of vehicle the Routes API plans for. Only modes that support traffic and
+// route modifiers are offered.
+type TravelMode string
+
+const (
+	// TravelModeDrive routes a car, the default
+	TravelModeDrive TravelMode = "DRIVE"
+	// TravelModeTwoWheeler routes a motorcycle or scooter
+	TravelModeTwoWheeler TravelMode = "TWO_WHEELER"
+)
+
+// PolylineEncoding is the format the Routes API returns the route path in
+type PolylineEncoding string
+
+const (
+	// PolylineEncodingEncoded returns the path as an encoded polyline string, the most compact option
+	PolylineEncodingEncoded PolylineEncoding = "ENCODED_POLYLINE"
+	// PolylineEncodingGeoJSON returns the path as GeoJSON coordinates, which are kept in RouteInfo.Points
+	PolylineEncodingGeoJSON PolylineEncoding = "GEO_JSON_LINESTRING"
+)
+
+// RouteOptions customizes route requests. The zero value gives traffic-aware optimal routing,
+// falling back to each cheaper preference in turn if traffic data is unavailable.
+type RouteOptions struct {
+	RoutingPreference RoutingPreference
+	// Fallbacks are tried in order when a preference fails with ErrTrafficUnavailable. Nil falls back
+	// through every preference cheaper than RoutingPreference, and an empty slice disables fallback.
+	Fallbacks []RoutingPreference
+	// IncludeSteps adds turn-by-turn instructions to the route
+	IncludeSteps bool
+	// PolylineEncoding is the format to request the path in, defaulting to an encoded polyline.
+	// The route's EncodedPolyline is filled in either way.
+	PolylineEncoding PolylineEncoding
+	// TravelMode is the vehicle to route, defaulting to TravelModeDrive
+	TravelMode TravelMode
+	// AvoidTolls, AvoidHighways and AvoidFerries keep the route off those roads where there's an alternative
+	AvoidTolls    bool
+	AvoidHighways bool
+	AvoidFerries  bool
+}
+
+// travelMode returns the mode to request, defaulting to driving
+func (o RouteOptions) travelMode() TravelMode {
+	if o.TravelMode == "" {
+		return TravelModeDrive
+	}
+	return o.TravelMode
+}
+
+// routeModifiers returns the features to avoid, nil when nothing is avoided
+func (o RouteOptions) routeModifiers() *RouteModifiers {
+	if !o.AvoidTolls && !o.AvoidHighways && !o.AvoidFerries {
+		return nil
+	}
+	return &RouteModifiers{AvoidTolls: o.AvoidTolls, AvoidHighways: o.AvoidHighways, AvoidFerries: o.AvoidFerries}
+}
+
+// polylineEncoding returns the encoding to request, defaulting to an encoded polyline
+func (o RouteOptions) polylineEncoding() PolylineEncoding {
+	if o.PolylineEncoding == "" {
+		return PolylineEncodingEncoded
+	}
+	return o.PolylineEncoding
+}
+
+// routingPreference returns the preference to request, defaulting to optimal
+func (o RouteOptions) routingPreference() RoutingPreference {
+	if o.RoutingPreference == "" {
+		return RoutingTrafficAwareOptimal
+	}
+	return o.RoutingPreference
+}
+
+// preferenceChain returns the preferences to try in order, starting with the preferred one
+func (o RouteOptions) preferenceChain() []RoutingPreference {
+	preferred := o.routingPreference()
+	fallbacks := o.Fallbacks
+	if fallbacks == nil {
+		for i, preference := range routingPreferencesByCost {
+			if preference == preferred {
+				fallbacks = routingPreferencesByCost[i+1:]
+			}
+		}
+	}
+
+	chain := []RoutingPreference{preferred}
+	for _, preference := range fallbacks {
+		if preference != preferred {
+			chain = append(chain, preference)
+		}
+	}
+	return chain
+}
+
 // GetRoute takes an API key and two location strings, then returns
-// information about the route with traffic-aware routing.
-func GetRoute(apiKey, origin, destination string) (*RouteInfo, error) {
+// information about the route, with traffic-aware routing unless opts says otherwise.
+// Cancelling ctx stops the Routes call, along with any retries and fallbacks.
+func GetRoute(ctx context.Context, apiKey, origin, destination string, opts RouteOptions) (*RouteInfo, error) {
+	return getRoute(ctx, apiKey, LocationRequest{Address: origin}, LocationRequest{Address: destination}, opts)
+}
+
+// GetRouteBetweenPoints is GetRoute for locations that have already been geocoded
+func GetRouteBetweenPoints(ctx context.Context, apiKey string, origin, destination Center, opts RouteOptions) (*RouteInfo, error) {
+	return getRoute(ctx, apiKey, pointWaypoint(origin), pointWaypoint(destination), opts)
+}
+
+// getRoute requests a route between two locations
+func getRoute(ctx context.Context, apiKey string, origin, destination LocationRequest, opts RouteOptions) (*RouteInfo, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("API key is missing. Please set the GOOGLE_MAPS_API_KEY environment variable")
 	}
 
-	// Get enhanced route data with traffic information
-	enhancedRoute, err := getEnhancedRouteData(apiKey, origin, destination)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get route: %w", err)
+	// Get enhanced route data with traffic information, falling back to cheaper preferences where
+	// traffic isn't available
+	var enhancedRoute *EnhancedRouteResponse
+	var preference RoutingPreference
+	chain := opts.preferenceChain()
+	for i := range chain {
+		preference = chain[i]
+		var err error
+		enhancedRoute, err = getEnhancedRouteData(ctx, apiKey, origin, destination, preference, opts)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrTrafficUnavailable) || i == len(chain)-1 {
+			return nil, fmt.Errorf("failed to get route: %w", err)
+		}
+		logf(LogInfo, "Traffic unavailable with %s routing, falling back to %s: %v", preference, chain[i+1], err)
 	}
 
 	if len(enhancedRoute.Routes) == 0 {
@@ -114,32 +319,89 @@ func GetRoute(apiKey, origin, destination string) (*RouteInfo, error) {
 
 	route := enhancedRoute.Routes[0]
 
-	// Parse the duration string
+	// Parse the duration strings
 	durationSeconds := parseDurationString(route.Duration)
+	staticDurationSeconds := parseDurationString(route.StaticDuration)
+
+	info := &RouteInfo{
+		DistanceMeters:    route.DistanceMeters,
+		Duration:          time.Duration(durationSeconds) * time.Second,
+		TypicalDuration:   time.Duration(staticDurationSeconds) * time.Second,
+		EncodedPolyline:   route.Polyline.EncodedPolyline,
+		TravelAdvisory:    route.TravelAdvisory,
+		RoutingPreference: preference,
+		FetchedAt:         time.Now(),
+	}
+	if opts.IncludeSteps {
+		info.Steps = navSteps(route.Legs)
+	}
+	// without traffic both durations are the same, so there's no delay to report
+	if preference == RoutingTrafficUnaware {
+		info.TypicalDuration = 0
+	}
+	// keep the encoded polyline too since stored routes and the frontend use it
+	if linestring := route.Polyline.GeoJSONLinestring; linestring != nil {
+		points, err := linestring.points()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse route coordinates: %w", err)
+		}
+		info.Points = points
+		info.EncodedPolyline = EncodePolyline(points)
+	}
+	return info, nil
+}
+
+// Path returns the route's points, decoding the polyline unless the route came with raw coordinates
+func (r *RouteInfo) Path() ([]Center, error) {
+	if len(r.Points) > 0 {
+		return r.Points, nil
+	}
+	return DecodePolyline(r.EncodedPolyline)
+}
+
+// DefaultSimplifyTolerances are the tolerances in meters of the simplified polylines offered to clients,
+// roughly matching city, region and country zoom levels
+var DefaultSimplifyTolerances = []float64{10, 100, 1000}
+
+// SimplifiedPolylines encodes the route's path simplified to each tolerance in meters, keyed by the
+// tolerance, so clients can draw less detail when zoomed out and keep EncodedPolyline for zooming in.
+func (r *RouteInfo) SimplifiedPolylines(tolerances []float64) (map[string]string, error) {
+	points, err := r.Path()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode route path: %w", err)
+	}
+	polylines := make(map[string]string, len(tolerances))
+	for _, tolerance := range tolerances {
+		polylines[strconv.FormatFloat(tolerance, 'f', -1, 64)] = EncodePolyline(simplifyPath(points, tolerance))
+	}
+	return polylines, nil
+}
 
-	return &RouteInfo{
-		DistanceMeters:  route.DistanceMeters,
-		Duration:        time.Duration(durationSeconds) * time.Second,
-		EncodedPolyline: route.Polyline.EncodedPolyline,
-		TravelAdvisory:  route.TravelAdvisory,
-	}, nil
+// TrafficDelay returns how much longer the route takes with traffic than without.
+// The boolean is false when Google did not return a typical duration to compare against.
+func (r *RouteInfo) TrafficDelay() (time.Duration, bool) {
+	if r.TypicalDuration <= 0 {
+		return 0, false
+	}
+	return r.Duration - r.TypicalDuration, true
 }
 
-// getEnhancedRouteData fetches traffic-aware route data from Google Routes API
-func getEnhancedRouteData(apiKey, origin, destination string) (*EnhancedRouteResponse, error) {
+// getEnhancedRouteData fetches route data from Google Routes API
+func getEnhancedRouteData(ctx context.Context, apiKey string, origin, destination LocationRequest, preference RoutingPreference, opts RouteOptions) (routes *EnhancedRouteResponse, err error) {
+	encoding := opts.polylineEncoding()
 	routesRequest := EnhancedRouteRequest{
-		Origin: LocationRequest{
-			Address: origin,
-		},
-		Destination: LocationRequest{
-			Address: destination,
-		},
-		TravelMode:        "DRIVE",
-		RoutingPreference: "TRAFFIC_AWARE_OPTIMAL",
-		ExtraComputations: []string{"TRAFFIC_ON_POLYLINE"},
+		Origin:            origin,
+		Destination:       destination,
+		TravelMode:        string(opts.travelMode()),
+		RoutingPreference: string(preference),
 		PolylineQuality:   "HIGH_QUALITY",
-		PolylineEncoding:  "ENCODED_POLYLINE",
-		DepartureTime:     time.Now().Add(1 * time.Minute).Format(time.RFC3339),
+		PolylineEncoding:  string(encoding),
+		RouteModifiers:    opts.routeModifiers(),
+	}
+	// traffic on the polyline is only computed, and billed, for traffic-aware routes
+	if preference != RoutingTrafficUnaware {
+		routesRequest.ExtraComputations = []string{"TRAFFIC_ON_POLYLINE"}
+		routesRequest.DepartureTime = time.Now().Add(1 * time.Minute).Format(time.RFC3339)
 	}
 
 	requestBody, err := json.Marshal(routesRequest)
@@ -147,18 +409,30 @@ func getEnhancedRouteData(apiKey, origin, destination string) (*EnhancedRouteRes
 		return nil, err
 	}
 
-	apiURL := "https://routes.googleapis.com/directions/v2:computeRoutes"
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", routesEndpoint, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("X-Goog-Api-Key", apiKey)
-	req.Header.Set("X-Goog-FieldMask", "routes.duration,routes.distanceMeters,routes.polyline.encodedPolyline,routes.travelAdvisory.speedReadingIntervals")
+	polylineField := "routes.polyline.encodedPolyline"
+	if encoding == PolylineEncodingGeoJSON {
+		polylineField = "routes.polyline.geoJsonLinestring"
+	}
+	fieldMask := "routes.duration,routes.staticDuration,routes.distanceMeters," + polylineField + ",routes.travelAdvisory.speedReadingIntervals"
+	if opts.IncludeSteps {
+		fieldMask += ",routes.legs.steps.navigationInstruction,routes.legs.steps.distanceMeters,routes.legs.steps.staticDuration"
+	}
+	req.Header.Set("X-Goog-FieldMask", fieldMask)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	countCall(SKURoutes)
+	defer func() { logCall(SKURoutes, "", string(preference), err) }()
+	resp, err := doWithRetry(req)
 	if err != nil {
 		return nil, err
 	}
@@ -170,6 +444,9 @@ func getEnhancedRouteData(apiKey, origin, destination string) (*EnhancedRouteRes
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		if preference != RoutingTrafficUnaware && isTrafficError(body) {
+			return nil, fmt.Errorf("%w: routes API error: %s", ErrTrafficUnavailable, string(body))
+		}
 		return nil, fmt.Errorf("routes API error: %s", string(body))
 	}
 
@@ -181,6 +458,67 @@ func getEnhancedRouteData(apiKey, origin, destination string) (*EnhancedRouteRes
 	return &routesData, nil
 }
 
+// navSteps flattens the steps of every leg into turn-by-turn instructions
+func navSteps(legs []EnhancedRouteLeg) []NavStep {
+	steps := []NavStep{}
+	for _, leg := range legs {
+		for _, step := range leg.Steps {
+			navStep := NavStep{
+				DistanceMeters:  step.DistanceMeters,
+				DurationSeconds: parseDurationString(step.StaticDuration),
+			}
+			if step.NavigationInstruction != nil {
+				navStep.Instruction = step.NavigationInstruction.Instructions
+				navStep.Maneuver = step.NavigationInstruction.Maneuver
+			}
+			steps = append(steps, navStep)
+		}
+	}
+	return steps
+}
+
+// routesAPIError is an error body from the Routes API, in Google's standard google.rpc.Status shape
+type routesAPIError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type   string `json:"@type"`
+			Reason string `json:"reason"`
+			Domain string `json:"domain"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// trafficUnavailableStatus is the status the Routes API gives a route it can't compute with traffic
+const trafficUnavailableStatus = "FAILED_PRECONDITION"
+
+// trafficUnavailableReasons are the google.rpc.ErrorInfo reasons from routes.googleapis.com that mean
+// traffic data is missing for the route, rather than that the request itself was wrong
+var trafficUnavailableReasons = map[string]bool{
+	"TRAFFIC_DATA_UNAVAILABLE": true,
+}
+
+// isTrafficError reports whether a Routes API error body says traffic data is unavailable for the route.
+// It goes by the error's status and ErrorInfo reason rather than its message, so a rejected request that
+// merely mentions traffic, such as a bad routingPreference, isn't retried with another preference.
+func isTrafficError(body []byte) bool {
+	var apiErr routesAPIError
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return false
+	}
+	if apiErr.Error.Status != trafficUnavailableStatus {
+		return false
+	}
+	for _, detail := range apiErr.Error.Details {
+		if detail.Type == "type.googleapis.com/google.rpc.ErrorInfo" && detail.Domain == "routes.googleapis.com" && trafficUnavailableReasons[detail.Reason] {
+			return true
+		}
+	}
+	return false
+}
+
 // parseDurationString parses duration strings like "2420s" to seconds
 func parseDurationString(durationStr string) int {
 	// Parse duration strings like "2420s" to seconds
@@ -197,6 +535,7 @@ func parseDurationString(durationStr string) int {
 }
 
 // interpolatePoints takes a list of points and inserts additional points at regular intervals along the path.
+// Steps across the antimeridian are interpolated the short way round.
 func interpolatePoints(points []Center, intervalMeters float64) []Center {
 	var densePoints []Center
 	if len(points) == 0 {
@@ -212,10 +551,17 @@ func interpolatePoints(points []Center, intervalMeters float64) []Center {
 			continue
 		}
 		numSegments := int(math.Ceil(dist / intervalMeters))
+		dLng := p2.Longitude - p1.Longitude
+		if math.Abs(dLng) > 180 {
+			dLng = normalizeLongitude(dLng)
+		}
 		for j := 1; j < numSegments; j++ {
 			fraction := float64(j) / float64(numSegments)
 			lat := p1.Latitude + fraction*(p2.Latitude-p1.Latitude)
-			lng := p1.Longitude + fraction*(p2.Longitude-p1.Longitude)
+			lng := p1.Longitude + fraction*dLng
+			if math.Abs(lng) > 180 {
+				lng = normalizeLongitude(lng)
+			}
 			densePoints = append(densePoints, Center{Latitude: lat, Longitude: lng})
 		}
 		densePoints = append(densePoints, p2)
@@ -223,6 +569,9 @@ func interpolatePoints(points []Center, intervalMeters float64) []Center {
 	return densePoints
 }
 
+// MaxSearchRadiusMeters is the largest circle radius the Places API accepts for a location bias
+const MaxSearchRadiusMeters = 50000.0
+
 // PolylineToCircles takes an encoded polyline string and a radius, then returns
 // a series of Circle objects that completely cover the route.
 func PolylineToCircles(encodedPolyline string, radius float64) ([]Circle, error) {
@@ -230,15 +579,56 @@ func PolylineToCircles(encodedPolyline string, radius float64) ([]Circle, error)
 		return nil, fmt.Errorf("radius must be a positive number")
 	}
 
+	points, err := routeCoverPoints(encodedPolyline, DefaultInterpolationMeters)
+	if err != nil {
+		return nil, err
+	}
+
+	return pointsToCircles(points, radius), nil
+}
+
+// PolylineToCirclesCapped covers the route like PolylineToCircles, but if that takes more than
+// maxCircles circles it widens the radius until the route fits, trading precision for fewer searches.
+// It returns the radius actually used. The radius never grows past MaxSearchRadiusMeters, so very
+// long routes may still need more than maxCircles. A maxCircles of zero or less disables the cap.
+// Points are interpolated every interpolationMeters along the route before placing circles.
+func PolylineToCirclesCapped(encodedPolyline string, radius float64, maxCircles int, interpolationMeters float64) ([]Circle, float64, error) {
+	if radius <= 0 {
+		return nil, 0, fmt.Errorf("radius must be a positive number")
+	}
+	if interpolationMeters <= 0 {
+		return nil, 0, fmt.Errorf("interpolation spacing must be a positive number")
+	}
+
+	points, err := routeCoverPoints(encodedPolyline, interpolationMeters)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	circles := pointsToCircles(points, radius)
+	for maxCircles > 0 && len(circles) > maxCircles && radius < MaxSearchRadiusMeters {
+		// circle count scales roughly inversely with radius, the extra 10% saves iterations near the cap
+		radius = math.Min(radius*float64(len(circles))/float64(maxCircles)*1.1, MaxSearchRadiusMeters)
+		circles = pointsToCircles(points, radius)
+	}
+
+	return circles, radius, nil
+}
+
+// routeCoverPoints decodes the polyline and interpolates it densely enough for circles to cover it
+func routeCoverPoints(encodedPolyline string, interpolationMeters float64) ([]Center, error) {
 	points, err := DecodePolyline(encodedPolyline)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode polyline: %w", err)
 	}
 
-	points = interpolatePoints(points, 100.0) // Interpolate points every 100 meters for better coverage
+	return interpolatePoints(points, interpolationMeters), nil
+}
 
+// pointsToCircles places circles of the given radius along the points so that every point is covered
+func pointsToCircles(points []Center, radius float64) []Circle {
 	if len(points) == 0 {
-		return []Circle{}, nil // Return empty slice if polyline has no points
+		return []Circle{} // Return empty slice if polyline has no points
 	}
 
 	var circles []Circle
@@ -268,7 +658,7 @@ func PolylineToCircles(encodedPolyline string, radius float64) ([]Circle, error)
 		circles = append(circles, newCircle)
 	}
 
-	return circles, nil
+	return circles
 }
 
 // DecodePolyline converts an encoded polyline string into a slice of geographic points.
@@ -330,6 +720,33 @@ func DecodePolyline(encoded string) ([]Center, error) {
 	return points, nil
 }
 
+// EncodePolyline converts points into an encoded polyline string, the reverse of DecodePolyline.
+// Coordinates are rounded to five decimal places, about a meter.
+func EncodePolyline(points []Center) string {
+	var encoded strings.Builder
+	encodeValue := func(delta int) {
+		value := delta << 1
+		if delta < 0 {
+			value = ^value
+		}
+		for value >= 0x20 {
+			encoded.WriteByte(byte((0x20 | (value & 0x1f)) + 63))
+			value >>= 5
+		}
+		encoded.WriteByte(byte(value + 63))
+	}
+
+	var prevLat, prevLng int
+	for _, point := range points {
+		lat := int(math.Round(point.Latitude * 1e5))
+		lng := int(math.Round(point.Longitude * 1e5))
+		encodeValue(lat - prevLat)
+		encodeValue(lng - prevLng)
+		prevLat, prevLng = lat, lng
+	}
+	return encoded.String()
+}
+
 // haversineDistance calculates the shortest distance over the earth's surface
 // between two geographic points in meters.
 func haversineDistance(p1, p2 Center) float64 {
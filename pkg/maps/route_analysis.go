@@ -0,0 +1,90 @@
+package maps
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+	"gorm.io/gorm"
+)
+
+// routeAnalysisSettings is the part of the search config stored with a route, recording how its circles were placed
+type routeAnalysisSettings struct {
+	GridSizeDegrees     float64 `json:"grid_size_degrees"`
+	InterpolationMeters float64 `json:"interpolation_meters"`
+	MaxCircles          int     `json:"max_circles,omitempty"`
+}
+
+// routeAnalysisKey identifies a route by its endpoints and routing options. Endpoints are normalized
+// so differences in case and spacing still share a stored route.
+func routeAnalysisKey(origin, destination string, config *SearchConfig) string {
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	}
+	key := fmt.Sprintf("%s|%s|%s", normalize(origin), normalize(destination), config.RouteOptions.routingPreference())
+	// other vehicles and avoided roads take different routes, the default keeps the keys of earlier routes
+	if mode := config.RouteOptions.travelMode(); mode != TravelModeDrive {
+		key += "|" + string(mode)
+	}
+	if modifiers := config.RouteOptions.routeModifiers(); modifiers != nil {
+		key += fmt.Sprintf("|avoid:%t,%t,%t", modifiers.AvoidTolls, modifiers.AvoidHighways, modifiers.AvoidFerries)
+	}
+	// geocoded routes start and end at the geocoder's match rather than wherever the Routes API resolves
+	if config.Geocoder != nil {
+		key += "|geocoded"
+	}
+	return key
+}
+
+// loadRouteAnalysis returns the route stored under key if it is newer than maxAge, or nil if there isn't one
+func loadRouteAnalysis(broker *db.Service, key string, maxAge time.Duration) *RouteInfo {
+	analysis, err := broker.RouteAnalysis.GetByKey(key, time.Now().Add(-maxAge))
+	if err != nil {
+		if err != gorm.ErrRecordNotFound {
+			log.Printf("Warning: failed to load stored route %q: %v", key, err)
+		}
+		return nil
+	}
+	return &RouteInfo{
+		DistanceMeters:    analysis.DistanceMeters,
+		Duration:          time.Duration(analysis.DurationSeconds) * time.Second,
+		TypicalDuration:   time.Duration(analysis.TypicalSeconds) * time.Second,
+		EncodedPolyline:   analysis.EncodedPolyline,
+		RoutingPreference: RoutingPreference(analysis.RoutingPreference),
+		FetchedAt:         analysis.UpdatedAt,
+	}
+}
+
+// saveRouteAnalysis stores a fetched route with the settings its circles were placed with.
+// Failures are logged rather than returned since the search itself still succeeded.
+func saveRouteAnalysis(broker *db.Service, key, origin, destination string, route *RouteInfo, config *SearchConfig, spatial SpatialConfig, searchRadius float64, circleCount int) {
+	settings, err := json.Marshal(routeAnalysisSettings{
+		GridSizeDegrees:     spatial.GridSizeDegrees,
+		InterpolationMeters: spatial.InterpolationMeters,
+		MaxCircles:          config.MaxCircles,
+	})
+	if err != nil {
+		log.Printf("Warning: failed to encode route search settings: %v", err)
+		return
+	}
+
+	analysis := &db.RouteAnalysis{
+		RouteKey:           key,
+		Origin:             origin,
+		Destination:        destination,
+		EncodedPolyline:    route.EncodedPolyline,
+		DistanceMeters:     route.DistanceMeters,
+		DurationSeconds:    int(route.Duration / time.Second),
+		TypicalSeconds:     int(route.TypicalDuration / time.Second),
+		RoutingPreference:  string(route.RoutingPreference),
+		SearchConfig:       string(settings),
+		SearchRadiusMeters: searchRadius,
+		CircleCount:        circleCount,
+	}
+	if err := broker.RouteAnalysis.Save(analysis); err != nil {
+		log.Printf("Warning: failed to store route %q: %v", key, err)
+	}
+}
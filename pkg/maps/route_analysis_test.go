@@ -0,0 +1,118 @@
+package maps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetSuperchargersOnRouteReusesStoredRoute(t *testing.T) {
+	var routeCalls int
+	routes := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routeCalls++
+		w.Write([]byte(`{"routes":[{"distanceMeters":5000,"duration":"600s","staticDuration":"540s","polyline":{"encodedPolyline":"_p~iF~ps|U_ulLnnqC"}}]}`))
+	}))
+	defer routes.Close()
+	places := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"places":[]}`))
+	}))
+	defer places.Close()
+
+	originalRoutes, originalPlaces := routesEndpoint, placesAPIEndpoint
+	defer func() { routesEndpoint, placesAPIEndpoint = originalRoutes, originalPlaces }()
+	routesEndpoint, placesAPIEndpoint = routes.URL, places.URL
+
+	broker := newTestDB(t)
+	config := DefaultSearchConfig()
+
+	// stored even when reuse is off
+	fetched, err := GetSuperchargersOnRoute(context.Background(), broker, "key", "Mountain View", "Morgan Hill", config)
+	if err != nil {
+		t.Fatalf("GetSuperchargersOnRoute failed: %v", err)
+	}
+	if fetched.Route.FetchedAt.IsZero() {
+		t.Error("Expected the fetched route to record when it was fetched")
+	}
+
+	config.RouteReuseMaxAge = time.Hour
+	result, err := GetSuperchargersOnRoute(context.Background(), broker, "key", "  mountain   view ", "MORGAN HILL", config)
+	if err != nil {
+		t.Fatalf("GetSuperchargersOnRoute failed: %v", err)
+	}
+	if routeCalls != 1 {
+		t.Errorf("Expected the stored route to be reused, got %d route calls", routeCalls)
+	}
+	// the reused route is as old as the stored one, not the search
+	if !result.Route.FetchedAt.Before(fetched.Route.FetchedAt.Add(time.Second)) || time.Since(result.Route.FetchedAt) > time.Minute {
+		t.Errorf("Expected the reused route to keep its fetch time %v, got %v", fetched.Route.FetchedAt, result.Route.FetchedAt)
+	}
+	if result.Route.EncodedPolyline != "_p~iF~ps|U_ulLnnqC" || result.Route.Duration != 600*time.Second || len(result.SearchCircles) == 0 {
+		t.Errorf("Expected the stored route to be rebuilt, got %+v with %d circles", result.Route, len(result.SearchCircles))
+	}
+
+	// a different routing preference is a different route
+	config.RouteOptions.RoutingPreference = RoutingTrafficUnaware
+	if _, err := GetSuperchargersOnRoute(context.Background(), broker, "key", "Mountain View", "Morgan Hill", config); err != nil {
+		t.Fatalf("GetSuperchargersOnRoute failed: %v", err)
+	}
+	if routeCalls != 2 {
+		t.Errorf("Expected a new route for another preference, got %d route calls", routeCalls)
+	}
+}
+
+func TestGetSuperchargersOnRouteContinuation(t *testing.T) {
+	var routeCalls, circleSearches int
+	routes := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routeCalls++
+		w.Write([]byte(`{"routes":[{"distanceMeters":250000,"duration":"9000s","polyline":{"encodedPolyline":"_p~iF~ps|U_ulLnnqC"}}]}`))
+	}))
+	defer routes.Close()
+	places := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		circleSearches++
+		w.Write([]byte(`{"places":[]}`))
+	}))
+	defer places.Close()
+
+	originalRoutes, originalPlaces := routesEndpoint, placesAPIEndpoint
+	defer func() { routesEndpoint, placesAPIEndpoint = originalRoutes, originalPlaces }()
+	routesEndpoint, placesAPIEndpoint = routes.URL, places.URL
+
+	broker := newTestDB(t)
+	config := DefaultSearchConfig()
+	config.CirclesPerPage = 3
+
+	var totalCircles, pages int
+	for {
+		result, err := GetSuperchargersOnRoute(context.Background(), broker, "key", "Sacramento", "Reno", config)
+		if err != nil {
+			t.Fatalf("GetSuperchargersOnRoute failed: %v", err)
+		}
+		pages++
+		totalCircles += len(result.SearchCircles)
+		if result.NextContinuation == "" {
+			if len(result.SearchCircles) > 3 {
+				t.Errorf("Expected at most 3 circles on the last page, got %d", len(result.SearchCircles))
+			}
+			break
+		}
+		// each page also searches the next page's first circle
+		if len(result.SearchCircles) != 4 {
+			t.Errorf("Expected 3 circles and the next page's first per page, got %d", len(result.SearchCircles))
+		}
+		if config.ContinueFrom, err = ParseContinuation(result.NextContinuation); err != nil {
+			t.Fatalf("ParseContinuation failed: %v", err)
+		}
+	}
+
+	if pages < 2 {
+		t.Fatalf("Expected the route to take several pages, got %d", pages)
+	}
+	if routeCalls != 1 {
+		t.Errorf("Expected later pages to reuse the stored route, got %d route calls", routeCalls)
+	}
+	if circleSearches != totalCircles {
+		t.Errorf("Expected one search per returned circle, got %d searches for %d circles", circleSearches, totalCircles)
+	}
+}
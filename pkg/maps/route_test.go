@@ -1,11 +1,17 @@
 package maps
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetRoute(t *testing.T) {
@@ -17,7 +23,7 @@ func TestGetRoute(t *testing.T) {
 	origin := "Framingham, MA"
 	destination := "Boston, MA"
 
-	result, err := GetRoute(apiKey, origin, destination)
+	result, err := GetRoute(context.Background(), apiKey, origin, destination, RouteOptions{})
 	if err != nil {
 		t.Fatalf("GetRoute failed: %v", err)
 	}
@@ -189,3 +195,382 @@ const mapTemplate = `
   </body>
 </html>
 `
+
+func TestRouteInfoTrafficDelay(t *testing.T) {
+	route := &RouteInfo{Duration: 40 * time.Minute, TypicalDuration: 30 * time.Minute}
+	delay, ok := route.TrafficDelay()
+	if !ok || delay != 10*time.Minute {
+		t.Errorf("Expected 10m delay, got %v (ok: %v)", delay, ok)
+	}
+
+	// Google omitted the static duration
+	route = &RouteInfo{Duration: 40 * time.Minute}
+	if _, ok := route.TrafficDelay(); ok {
+		t.Error("Expected no delay when typical duration is missing")
+	}
+}
+
+func TestRouteInfoSimplifiedPolylines(t *testing.T) {
+	// a gentle wiggle a few hundred meters wide along a straight road
+	var path []Center
+	for i := 0; i <= 100; i++ {
+		path = append(path, Center{Latitude: 0.003 * float64(i%2), Longitude: 0.01 * float64(i)})
+	}
+	route := &RouteInfo{EncodedPolyline: EncodePolyline(path)}
+
+	polylines, err := route.SimplifiedPolylines([]float64{10, 1000})
+	if err != nil {
+		t.Fatalf("SimplifiedPolylines failed: %v", err)
+	}
+	if len(polylines) != 2 {
+		t.Fatalf("Expected a polyline per tolerance, got %v", polylines)
+	}
+
+	fine, err := DecodePolyline(polylines["10"])
+	if err != nil {
+		t.Fatalf("Failed to decode fine polyline: %v", err)
+	}
+	coarse, err := DecodePolyline(polylines["1000"])
+	if err != nil {
+		t.Fatalf("Failed to decode coarse polyline: %v", err)
+	}
+	if len(fine) != len(path) {
+		t.Errorf("Expected the 10m polyline to keep every point, got %d of %d", len(fine), len(path))
+	}
+	if len(coarse) != 2 {
+		t.Errorf("Expected the 1000m polyline to flatten the wiggle to its ends, got %d points", len(coarse))
+	}
+}
+
+func TestPolylineToCirclesCapped(t *testing.T) {
+	encodedPolyline, err := os.ReadFile("polyline.txt")
+	if err != nil {
+		t.Fatalf("Failed to read polyline.txt: %v", err)
+	}
+
+	uncapped, radius, err := PolylineToCirclesCapped(string(encodedPolyline), SuperchargerSearchRadiusMeters, 0, DefaultInterpolationMeters)
+	if err != nil {
+		t.Fatalf("PolylineToCirclesCapped failed: %v", err)
+	}
+	if radius != SuperchargerSearchRadiusMeters {
+		t.Errorf("Expected the radius to be unchanged without a cap, got %v", radius)
+	}
+
+	maxCircles := len(uncapped) / 4
+	capped, radius, err := PolylineToCirclesCapped(string(encodedPolyline), SuperchargerSearchRadiusMeters, maxCircles, DefaultInterpolationMeters)
+	if err != nil {
+		t.Fatalf("PolylineToCirclesCapped failed: %v", err)
+	}
+	if len(capped) > maxCircles {
+		t.Errorf("Expected at most %d circles, got %d", maxCircles, len(capped))
+	}
+	if radius <= SuperchargerSearchRadiusMeters {
+		t.Errorf("Expected the radius to be widened, got %v", radius)
+	}
+	for _, circle := range capped {
+		if circle.Radius != radius {
+			t.Fatalf("Expected every circle to use the effective radius %v, got %v", radius, circle.Radius)
+		}
+	}
+
+	// the radius can't grow past what the Places API accepts
+	_, radius, err = PolylineToCirclesCapped(string(encodedPolyline), SuperchargerSearchRadiusMeters, 1, DefaultInterpolationMeters)
+	if err != nil {
+		t.Fatalf("PolylineToCirclesCapped failed: %v", err)
+	}
+	if radius != MaxSearchRadiusMeters {
+		t.Errorf("Expected the radius to stop at %v, got %v", MaxSearchRadiusMeters, radius)
+	}
+}
+
+func TestGetRouteRoutingPreference(t *testing.T) {
+	var requests []EnhancedRouteRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req EnhancedRouteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requests = append(requests, req)
+		w.Write([]byte(`{"routes":[{"distanceMeters":1000,"duration":"120s","staticDuration":"100s","polyline":{"encodedPolyline":"abc"}}]}`))
+	}))
+	defer server.Close()
+
+	original := routesEndpoint
+	defer func() { routesEndpoint = original }()
+	routesEndpoint = server.URL
+
+	route, err := GetRoute(context.Background(), "key", "here", "there", RouteOptions{})
+	if err != nil {
+		t.Fatalf("GetRoute failed: %v", err)
+	}
+	if requests[0].RoutingPreference != string(RoutingTrafficAwareOptimal) || len(requests[0].ExtraComputations) == 0 {
+		t.Errorf("Expected optimal traffic-aware routing by default, got %+v", requests[0])
+	}
+	if delay, ok := route.TrafficDelay(); !ok || delay != 20*time.Second {
+		t.Errorf("Expected a 20s traffic delay, got %v %v", delay, ok)
+	}
+
+	route, err = GetRoute(context.Background(), "key", "here", "there", RouteOptions{RoutingPreference: RoutingTrafficUnaware})
+	if err != nil {
+		t.Fatalf("GetRoute failed: %v", err)
+	}
+	if requests[1].RoutingPreference != string(RoutingTrafficUnaware) || len(requests[1].ExtraComputations) != 0 || requests[1].DepartureTime != "" {
+		t.Errorf("Expected no traffic computations when traffic unaware, got %+v", requests[1])
+	}
+	if _, ok := route.TrafficDelay(); ok {
+		t.Error("Expected no traffic delay when traffic unaware")
+	}
+}
+
+func TestGetRouteModifiers(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		bodies = append(bodies, body)
+		w.Write([]byte(`{"routes":[{"distanceMeters":1000,"duration":"120s","polyline":{"encodedPolyline":"abc"}}]}`))
+	}))
+	defer server.Close()
+
+	original := routesEndpoint
+	defer func() { routesEndpoint = original }()
+	routesEndpoint = server.URL
+
+	if _, err := GetRoute(context.Background(), "key", "here", "there", RouteOptions{}); err != nil {
+		t.Fatalf("GetRoute failed: %v", err)
+	}
+	if bodies[0]["travelMode"] != "DRIVE" {
+		t.Errorf("Expected driving by default, got %v", bodies[0]["travelMode"])
+	}
+	if _, ok := bodies[0]["routeModifiers"]; ok {
+		t.Errorf("Expected no route modifiers by default, got %v", bodies[0]["routeModifiers"])
+	}
+
+	opts := RouteOptions{TravelMode: TravelModeTwoWheeler, AvoidTolls: true, AvoidFerries: true}
+	if _, err := GetRoute(context.Background(), "key", "here", "there", opts); err != nil {
+		t.Fatalf("GetRoute failed: %v", err)
+	}
+	if bodies[1]["travelMode"] != "TWO_WHEELER" {
+		t.Errorf("Expected two wheeler routing, got %v", bodies[1]["travelMode"])
+	}
+	modifiers, _ := bodies[1]["routeModifiers"].(map[string]interface{})
+	if modifiers["avoidTolls"] != true || modifiers["avoidFerries"] != true {
+		t.Errorf("Expected tolls and ferries avoided, got %v", modifiers)
+	}
+	if _, ok := modifiers["avoidHighways"]; ok {
+		t.Errorf("Expected highways not to be mentioned, got %v", modifiers)
+	}
+}
+
+// trafficUnavailableBody is a Routes API error for a route without traffic coverage, in Google's
+// google.rpc.Status format with an ErrorInfo detail
+const trafficUnavailableBody = `{
+  "error": {
+    "code": 400,
+    "message": "Traffic-aware routing is not available for this route.",
+    "status": "FAILED_PRECONDITION",
+    "details": [
+      {
+        "@type": "type.googleapis.com/google.rpc.ErrorInfo",
+        "reason": "TRAFFIC_DATA_UNAVAILABLE",
+        "domain": "routes.googleapis.com",
+        "metadata": {"service": "routes.googleapis.com"}
+      }
+    ]
+  }
+}`
+
+func TestIsTrafficError(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		want bool
+	}{
+		"traffic unavailable": {trafficUnavailableBody, true},
+		// a bad request that only mentions traffic must not be retried with another preference
+		"invalid routing preference": {`{"error":{"code":400,"message":"Invalid value at 'routing_preference' (traffic aware routing), \"FASTEST\"","status":"INVALID_ARGUMENT","details":[{"@type":"type.googleapis.com/google.rpc.BadRequest","fieldViolations":[{"field":"routing_preference"}]}]}}`, false},
+		"other precondition":         {`{"error":{"code":400,"message":"Route too long.","status":"FAILED_PRECONDITION","details":[{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"ROUTE_TOO_LONG","domain":"routes.googleapis.com"}]}}`, false},
+		"not json":                   {`traffic unavailable`, false},
+	}
+	for name, tt := range tests {
+		if got := isTrafficError([]byte(tt.body)); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", name, tt.want, got)
+		}
+	}
+}
+
+func TestGetRouteFallsBackWhenTrafficUnavailable(t *testing.T) {
+	var preferences []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req EnhancedRouteRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		preferences = append(preferences, req.RoutingPreference)
+		if req.RoutingPreference != string(RoutingTrafficUnaware) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(trafficUnavailableBody))
+			return
+		}
+		w.Write([]byte(`{"routes":[{"distanceMeters":1000,"duration":"120s","staticDuration":"120s","polyline":{"encodedPolyline":"abc"}}]}`))
+	}))
+	defer server.Close()
+
+	original := routesEndpoint
+	defer func() { routesEndpoint = original }()
+	routesEndpoint = server.URL
+
+	route, err := GetRoute(context.Background(), "key", "here", "there", RouteOptions{})
+	if err != nil {
+		t.Fatalf("GetRoute failed: %v", err)
+	}
+	if route.RoutingPreference != RoutingTrafficUnaware {
+		t.Errorf("Expected the route to come from the unaware fallback, got %s", route.RoutingPreference)
+	}
+	if len(preferences) != 3 || preferences[0] != string(RoutingTrafficAwareOptimal) || preferences[1] != string(RoutingTrafficAware) {
+		t.Errorf("Expected optimal, aware then unaware to be tried, got %v", preferences)
+	}
+
+	// a configured chain skips straight to unaware
+	preferences = nil
+	if _, err := GetRoute(context.Background(), "key", "here", "there", RouteOptions{Fallbacks: []RoutingPreference{RoutingTrafficUnaware}}); err != nil {
+		t.Fatalf("GetRoute failed: %v", err)
+	}
+	if len(preferences) != 2 {
+		t.Errorf("Expected optimal then unaware to be tried, got %v", preferences)
+	}
+
+	// with fallback disabled the traffic error is returned
+	preferences = nil
+	_, err = GetRoute(context.Background(), "key", "here", "there", RouteOptions{Fallbacks: []RoutingPreference{}})
+	if !errors.Is(err, ErrTrafficUnavailable) || len(preferences) != 1 {
+		t.Errorf("Expected ErrTrafficUnavailable after a single attempt, got %v after %v", err, preferences)
+	}
+}
+
+func TestGetRouteCancelledDuringRetries(t *testing.T) {
+	original := *retryPolicy.Load()
+	defer SetRetryPolicy(original)
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	originalEndpoint := routesEndpoint
+	defer func() { routesEndpoint = originalEndpoint }()
+	routesEndpoint = server.URL
+
+	// a client that goes away stops the backoff rather than waiting it out
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := GetRoute(ctx, "key", "here", "there", RouteOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context's error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the retries to end with the context, took %v", elapsed)
+	}
+}
+
+func TestGetRoutePolylineEncoding(t *testing.T) {
+	// Google's documented example polyline
+	const encoded = "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req EnhancedRouteRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		encodings = append(encodings, req.PolylineEncoding)
+		if req.PolylineEncoding == string(PolylineEncodingGeoJSON) {
+			w.Write([]byte(`{"routes":[{"distanceMeters":1000,"duration":"120s","polyline":{"geoJsonLinestring":{"type":"LineString","coordinates":[[-120.2,38.5],[-120.95,40.7],[-126.453,43.252]]}}}]}`))
+			return
+		}
+		fmt.Fprintf(w, `{"routes":[{"distanceMeters":1000,"duration":"120s","polyline":{"encodedPolyline":%q}}]}`, encoded)
+	}))
+	defer server.Close()
+
+	original := routesEndpoint
+	defer func() { routesEndpoint = original }()
+	routesEndpoint = server.URL
+
+	encodedRoute, err := GetRoute(context.Background(), "key", "here", "there", RouteOptions{})
+	if err != nil {
+		t.Fatalf("GetRoute failed: %v", err)
+	}
+	geoJSONRoute, err := GetRoute(context.Background(), "key", "here", "there", RouteOptions{PolylineEncoding: PolylineEncodingGeoJSON})
+	if err != nil {
+		t.Fatalf("GetRoute failed: %v", err)
+	}
+	if encodings[0] != string(PolylineEncodingEncoded) || encodings[1] != string(PolylineEncodingGeoJSON) {
+		t.Errorf("Expected encoded then GeoJSON to be requested, got %v", encodings)
+	}
+	if len(encodedRoute.Points) != 0 {
+		t.Error("Expected no raw points for an encoded route")
+	}
+
+	// both encodings describe the same path
+	decoded, err := encodedRoute.Path()
+	if err != nil {
+		t.Fatalf("Path failed: %v", err)
+	}
+	coordinates, err := geoJSONRoute.Path()
+	if err != nil {
+		t.Fatalf("Path failed: %v", err)
+	}
+	if len(decoded) != 3 || len(coordinates) != len(decoded) {
+		t.Fatalf("Expected 3 points from each encoding, got %d and %d", len(decoded), len(coordinates))
+	}
+	for i := range decoded {
+		if haversineDistance(decoded[i], coordinates[i]) > 1 {
+			t.Errorf("Point %d differs: decoded %v, GeoJSON %v", i, decoded[i], coordinates[i])
+		}
+	}
+	if geoJSONRoute.EncodedPolyline != encoded {
+		t.Errorf("Expected the GeoJSON route to be re-encoded as %q, got %q", encoded, geoJSONRoute.EncodedPolyline)
+	}
+}
+
+func TestGetRouteSteps(t *testing.T) {
+	var fieldMasks []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fieldMasks = append(fieldMasks, r.Header.Get("X-Goog-FieldMask"))
+		w.Write([]byte(`{"routes":[{"distanceMeters":1500,"duration":"120s","polyline":{"encodedPolyline":"abc"},"legs":[{"steps":[
+			{"distanceMeters":500,"staticDuration":"40s","navigationInstruction":{"maneuver":"DEPART","instructions":"Head north on Main St"}},
+			{"distanceMeters":1000,"staticDuration":"80s","navigationInstruction":{"maneuver":"TURN_LEFT","instructions":"Turn left onto 1st Ave"}}
+		]}]}]}`))
+	}))
+	defer server.Close()
+
+	original := routesEndpoint
+	defer func() { routesEndpoint = original }()
+	routesEndpoint = server.URL
+
+	route, err := GetRoute(context.Background(), "key", "here", "there", RouteOptions{IncludeSteps: true})
+	if err != nil {
+		t.Fatalf("GetRoute failed: %v", err)
+	}
+	if !strings.Contains(fieldMasks[0], "routes.legs.steps.navigationInstruction") {
+		t.Errorf("Expected navigation instructions in the field mask, got %q", fieldMasks[0])
+	}
+	want := []NavStep{
+		{Instruction: "Head north on Main St", Maneuver: "DEPART", DistanceMeters: 500, DurationSeconds: 40},
+		{Instruction: "Turn left onto 1st Ave", Maneuver: "TURN_LEFT", DistanceMeters: 1000, DurationSeconds: 80},
+	}
+	if len(route.Steps) != len(want) {
+		t.Fatalf("Expected %d steps, got %+v", len(want), route.Steps)
+	}
+	for i := range want {
+		if route.Steps[i] != want[i] {
+			t.Errorf("Step %d: expected %+v, got %+v", i, want[i], route.Steps[i])
+		}
+	}
+
+	// steps are only requested when wanted
+	route, err = GetRoute(context.Background(), "key", "here", "there", RouteOptions{})
+	if err != nil {
+		t.Fatalf("GetRoute failed: %v", err)
+	}
+	if strings.Contains(fieldMasks[1], "steps") || route.Steps != nil {
+		t.Errorf("Expected no steps unless asked for, got mask %q and %d steps", fieldMasks[1], len(route.Steps))
+	}
+}
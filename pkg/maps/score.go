@@ -0,0 +1,106 @@
+package maps
+
+import "math"
+
+// ScoreContext holds the inputs and weights used to score a supercharger
+type ScoreContext struct {
+	// MaxDetourMeters is the distance from the route that scores zero for detour
+	MaxDetourMeters float64
+	// RangeMeters is the vehicle's range. When zero, position is not scored.
+	RangeMeters float64
+	// RangeStartMeters is the distance along the route where the first range interval starts. Each interval
+	// after it starts RangeMeters further on.
+	RangeStartMeters float64
+	// FoodTarget is the number of nearby restaurants that earns a full food score
+	FoodTarget int
+
+	DetourWeight   float64
+	PositionWeight float64
+	FoodWeight     float64
+	// ReliabilityWeight is how much of the score an unreliable charger loses, from 0 to 1. At 1 a charger's
+	// score is scaled by its Reliability, at 0 reliability is ignored.
+	ReliabilityWeight float64
+}
+
+// DefaultScoreContext returns default scoring weights
+func DefaultScoreContext() ScoreContext {
+	return ScoreContext{
+		MaxDetourMeters:   20000, // matches the cutoff used when processing superchargers
+		FoodTarget:        5,
+		DetourWeight:      1,
+		PositionWeight:    1,
+		FoodWeight:        1,
+		ReliabilityWeight: 1,
+	}
+}
+
+// ScoreCharger returns a 0-100 quality score for a supercharger, combining how far it is
+// from the route, how well placed it is within its range interval, and the food nearby,
+// then lowering it for chargers with a history of failed or inconsistent lookups.
+func ScoreCharger(sc SuperchargerWithETA, scoreCtx ScoreContext) float64 {
+	var total, weights float64
+
+	// Detour: chargers right on the route score best
+	if scoreCtx.DetourWeight > 0 && scoreCtx.MaxDetourMeters > 0 {
+		detour := 1 - clamp01(sc.DistanceFromRoute/scoreCtx.MaxDetourMeters)
+		total += scoreCtx.DetourWeight * detour
+		weights += scoreCtx.DetourWeight
+	}
+
+	// Position: stopping mid-way through the range interval leaves margin either side. Chargers behind the
+	// start have been passed, so score zero.
+	if scoreCtx.PositionWeight > 0 && scoreCtx.RangeMeters > 0 {
+		position := 0.0
+		if offset := sc.DistanceAlongRoute - scoreCtx.RangeStartMeters; offset >= 0 {
+			fraction := math.Mod(offset, scoreCtx.RangeMeters) / scoreCtx.RangeMeters
+			position = 1 - math.Abs(fraction-0.5)*2
+		}
+		total += scoreCtx.PositionWeight * position
+		weights += scoreCtx.PositionWeight
+	}
+
+	// Food: more restaurants and better ratings score higher
+	if scoreCtx.FoodWeight > 0 {
+		food := 0.0
+		if len(sc.Restaurants) > 0 {
+			count := 1.0
+			if scoreCtx.FoodTarget > 0 {
+				count = clamp01(float64(len(sc.Restaurants)) / float64(scoreCtx.FoodTarget))
+			}
+
+			var ratingSum float64
+			var rated int
+			for _, r := range sc.Restaurants {
+				if r.Rating > 0 {
+					ratingSum += r.Rating
+					rated++
+				}
+			}
+			// Unrated restaurants count towards quantity only
+			rating := 1.0
+			if rated > 0 {
+				rating = clamp01(ratingSum / float64(rated) / 5)
+			}
+
+			food = count * rating
+		}
+		total += scoreCtx.FoodWeight * food
+		weights += scoreCtx.FoodWeight
+	}
+
+	if weights == 0 {
+		return 0
+	}
+	score := 100 * total / weights
+
+	// Reliability: flaky or defunct sites drop down the ranking. Zero means it was never looked up.
+	if scoreCtx.ReliabilityWeight > 0 && sc.Reliability > 0 {
+		score *= 1 - clamp01(scoreCtx.ReliabilityWeight)*(1-sc.Reliability)
+	}
+	return score
+}
+
+// clamp01 limits v to the range [0, 1]
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,66 @@
+package maps
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestScoreCharger(t *testing.T) {
+	scoreCtx := DefaultScoreContext()
+	scoreCtx.RangeMeters = 100000
+
+	ideal := SuperchargerWithETA{
+		DistanceFromRoute:  0,
+		DistanceAlongRoute: 50000,
+		Restaurants: []db.RestaurantWithDistance{
+			{Restaurant: db.Restaurant{Rating: 5}}, {Restaurant: db.Restaurant{Rating: 5}},
+			{Restaurant: db.Restaurant{Rating: 5}}, {Restaurant: db.Restaurant{Rating: 5}},
+			{Restaurant: db.Restaurant{Rating: 5}},
+		},
+	}
+	if score := ScoreCharger(ideal, scoreCtx); score != 100 {
+		t.Errorf("Expected ideal charger to score 100, got %f", score)
+	}
+
+	worst := SuperchargerWithETA{
+		DistanceFromRoute:  20000,
+		DistanceAlongRoute: 0,
+	}
+	if score := ScoreCharger(worst, scoreCtx); score != 0 {
+		t.Errorf("Expected worst charger to score 0, got %f", score)
+	}
+
+	// later range intervals are scored the same as the first
+	positionOnly := ScoreContext{RangeMeters: 100000, RangeStartMeters: 20000, PositionWeight: 1}
+	for along, want := range map[float64]float64{70000: 100, 170000: 100, 270000: 100, 220000: 0, 245000: 50, 10000: 0} {
+		if score := ScoreCharger(SuperchargerWithETA{DistanceAlongRoute: along}, positionOnly); math.Abs(score-want) > 1e-9 {
+			t.Errorf("Expected a charger %.0fm along to score %.0f for position, got %f", along, want, score)
+		}
+	}
+
+	// Only detour is weighted, so half the max detour scores 50
+	detourOnly := ScoreContext{MaxDetourMeters: 20000, DetourWeight: 1}
+	if score := ScoreCharger(SuperchargerWithETA{DistanceFromRoute: 10000}, detourOnly); score != 50 {
+		t.Errorf("Expected detour-only score of 50, got %f", score)
+	}
+}
+
+func TestScoreChargerReliability(t *testing.T) {
+	scoreCtx := ScoreContext{MaxDetourMeters: 20000, DetourWeight: 1, ReliabilityWeight: 1}
+	onRoute := SuperchargerWithETA{Reliability: 1}
+	if score := ScoreCharger(onRoute, scoreCtx); score != 100 {
+		t.Errorf("Expected a reliable charger to keep its score of 100, got %f", score)
+	}
+
+	onRoute.Reliability = 0.5
+	if score := ScoreCharger(onRoute, scoreCtx); score != 50 {
+		t.Errorf("Expected half reliability to halve the score, got %f", score)
+	}
+
+	scoreCtx.ReliabilityWeight = 0.5
+	if score := ScoreCharger(onRoute, scoreCtx); score != 75 {
+		t.Errorf("Expected a half weight to take a quarter off, got %f", score)
+	}
+}
@@ -0,0 +1,78 @@
+package maps
+
+import (
+	"sort"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+// DefaultMovedThresholdMeters is how far a supercharger has to shift between scrapes to count as moved.
+// Google nudges coordinates by a few meters between lookups, which isn't worth reporting.
+const DefaultMovedThresholdMeters = 50.0
+
+// MovedSupercharger is a supercharger found in both scrapes at different locations
+type MovedSupercharger struct {
+	PlaceID        string  `json:"place_id"`
+	Name           string  `json:"name"`
+	From           Center  `json:"from"`
+	To             Center  `json:"to"`
+	DistanceMeters float64 `json:"distance_meters"`
+}
+
+// ScrapeDiff is what changed between two scrapes of the same area
+type ScrapeDiff struct {
+	Added   []string            `json:"added"`
+	Removed []string            `json:"removed"`
+	Moved   []MovedSupercharger `json:"moved"`
+}
+
+// DiffScrapes compares the confirmed superchargers from an older and a newer scrape, returning the place IDs
+// only in one of them and those that moved more than movedThresholdMeters. Results are sorted by place ID.
+func DiffScrapes(old, new []db.Supercharger, movedThresholdMeters float64) ScrapeDiff {
+	confirmed := func(superchargers []db.Supercharger) map[string]db.Supercharger {
+		byID := make(map[string]db.Supercharger, len(superchargers))
+		for _, sc := range superchargers {
+			if sc.IsSupercharger {
+				byID[sc.PlaceID] = sc
+			}
+		}
+		return byID
+	}
+	before, after := confirmed(old), confirmed(new)
+
+	diff := ScrapeDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Moved:   []MovedSupercharger{},
+	}
+	for placeID, sc := range after {
+		previous, ok := before[placeID]
+		if !ok {
+			diff.Added = append(diff.Added, placeID)
+			continue
+		}
+		from := Center{Latitude: previous.Latitude, Longitude: previous.Longitude}
+		to := Center{Latitude: sc.Latitude, Longitude: sc.Longitude}
+		if distance := haversineDistance(from, to); distance > movedThresholdMeters {
+			diff.Moved = append(diff.Moved, MovedSupercharger{
+				PlaceID:        placeID,
+				Name:           sc.Name,
+				From:           from,
+				To:             to,
+				DistanceMeters: distance,
+			})
+		}
+	}
+	for placeID := range before {
+		if _, ok := after[placeID]; !ok {
+			diff.Removed = append(diff.Removed, placeID)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Moved, func(i, j int) bool {
+		return diff.Moved[i].PlaceID < diff.Moved[j].PlaceID
+	})
+	return diff
+}
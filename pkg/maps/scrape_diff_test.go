@@ -0,0 +1,40 @@
+package maps
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestDiffScrapes(t *testing.T) {
+	old := []db.Supercharger{
+		{PlaceID: "kept", Latitude: 37, Longitude: -122, IsSupercharger: true},
+		{PlaceID: "nudged", Latitude: 38, Longitude: -121, IsSupercharger: true},
+		{PlaceID: "moved", Latitude: 39, Longitude: -120, IsSupercharger: true},
+		{PlaceID: "closed", Latitude: 40, Longitude: -119, IsSupercharger: true},
+	}
+	new := []db.Supercharger{
+		{PlaceID: "kept", Latitude: 37, Longitude: -122, IsSupercharger: true},
+		{PlaceID: "nudged", Latitude: 38.0001, Longitude: -121, IsSupercharger: true},
+		{PlaceID: "moved", Latitude: 39.01, Longitude: -120, IsSupercharger: true},
+		{PlaceID: "opened", Latitude: 41, Longitude: -118, IsSupercharger: true},
+		// places that aren't superchargers are ignored
+		{PlaceID: "gas", Latitude: 42, Longitude: -117},
+	}
+
+	diff := DiffScrapes(old, new, DefaultMovedThresholdMeters)
+
+	if !slices.Equal(diff.Added, []string{"opened"}) {
+		t.Errorf("Expected opened to be added, got %v", diff.Added)
+	}
+	if !slices.Equal(diff.Removed, []string{"closed"}) {
+		t.Errorf("Expected closed to be removed, got %v", diff.Removed)
+	}
+	if len(diff.Moved) != 1 || diff.Moved[0].PlaceID != "moved" {
+		t.Fatalf("Expected only moved to have moved, got %+v", diff.Moved)
+	}
+	if d := diff.Moved[0].DistanceMeters; d < 1100 || d > 1125 {
+		t.Errorf("Expected the move to be about 1112m, got %.0f", d)
+	}
+}
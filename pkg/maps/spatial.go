@@ -0,0 +1,64 @@
+package maps
+
+import "fmt"
+
+// Default spatial heuristics, tuned for routes of a few hundred kilometers
+const (
+	// DefaultGridSizeDegrees is the polyline index cell size, 0.01 degrees is about 1.11km
+	DefaultGridSizeDegrees = 0.01
+	// DefaultInterpolationMeters is the spacing of points added along the route before placing search circles
+	DefaultInterpolationMeters = 100.0
+)
+
+// metersPerDegree is roughly how many meters one degree of latitude spans
+const metersPerDegree = 111000.0
+
+// SpatialConfig tunes the spatial index and search circle placement. The two settings interact, so
+// Validate warns about combinations that waste memory or miss parts of the route.
+// Short routes can afford a finer grid, cross-country routes a coarser one.
+type SpatialConfig struct {
+	// GridSizeDegrees is the cell size of the grid used to find route segments near a point. Zero uses the default.
+	GridSizeDegrees float64
+	// InterpolationMeters is the spacing of points along the route when placing search circles. Zero uses the default.
+	InterpolationMeters float64
+}
+
+// DefaultSpatialConfig returns the default spatial heuristics
+func DefaultSpatialConfig() SpatialConfig {
+	return SpatialConfig{
+		GridSizeDegrees:     DefaultGridSizeDegrees,
+		InterpolationMeters: DefaultInterpolationMeters,
+	}
+}
+
+// withDefaults fills in zero values with the defaults
+func (c SpatialConfig) withDefaults() SpatialConfig {
+	if c.GridSizeDegrees == 0 {
+		c.GridSizeDegrees = DefaultGridSizeDegrees
+	}
+	if c.InterpolationMeters == 0 {
+		c.InterpolationMeters = DefaultInterpolationMeters
+	}
+	return c
+}
+
+// Validate returns an error for values that can't work and logs a warning for combinations
+// that work but perform badly
+func (c SpatialConfig) Validate() error {
+	c = c.withDefaults()
+	if c.GridSizeDegrees < 0 {
+		return fmt.Errorf("grid size must be positive, got %v degrees", c.GridSizeDegrees)
+	}
+	if c.InterpolationMeters < 0 {
+		return fmt.Errorf("interpolation spacing must be positive, got %vm", c.InterpolationMeters)
+	}
+
+	gridMeters := c.GridSizeDegrees * metersPerDegree
+	switch {
+	case gridMeters < c.InterpolationMeters:
+		logf(LogWarn, "Warning: grid cells of %.0fm are smaller than the %.0fm interpolation spacing, parts of the route may be missed", gridMeters, c.InterpolationMeters)
+	case gridMeters > 100*c.InterpolationMeters:
+		logf(LogWarn, "Warning: grid cells of %.0fm are much coarser than the %.0fm interpolation spacing, the extra points waste memory", gridMeters, c.InterpolationMeters)
+	}
+	return nil
+}
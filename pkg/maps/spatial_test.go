@@ -0,0 +1,29 @@
+package maps
+
+import "testing"
+
+func TestSpatialConfigValidate(t *testing.T) {
+	if err := DefaultSpatialConfig().Validate(); err != nil {
+		t.Errorf("Expected the defaults to be valid, got %v", err)
+	}
+
+	// zero values fall back to the defaults
+	if got := (SpatialConfig{}).withDefaults(); got != DefaultSpatialConfig() {
+		t.Errorf("Expected defaults, got %+v", got)
+	}
+	if err := (SpatialConfig{}).Validate(); err != nil {
+		t.Errorf("Expected a zero config to be valid, got %v", err)
+	}
+
+	if err := (SpatialConfig{GridSizeDegrees: -0.01}).Validate(); err == nil {
+		t.Error("Expected an error for a negative grid size")
+	}
+	if err := (SpatialConfig{InterpolationMeters: -100}).Validate(); err == nil {
+		t.Error("Expected an error for a negative interpolation spacing")
+	}
+
+	// pathological but usable combinations only warn
+	if err := (SpatialConfig{GridSizeDegrees: 0.0001, InterpolationMeters: 1000}).Validate(); err != nil {
+		t.Errorf("Expected a fine grid to only warn, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package maps
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultStaticMapSize is the image size StaticMapURL uses when none or an invalid one is given
+	DefaultStaticMapSize = "600x300"
+	// MaxStaticMapURLLength is the longest URL the Static Maps API accepts
+	MaxStaticMapURLLength = 8192
+)
+
+var staticMapEndpoint = "https://maps.googleapis.com/maps/api/staticmap"
+
+var staticMapSizePattern = regexp.MustCompile(`^[1-9][0-9]{0,3}x[1-9][0-9]{0,3}$`)
+
+// staticMapTolerances are the simplification tolerances in meters tried, in order, until the URL fits
+var staticMapTolerances = []float64{0, 10, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// StaticMapURL builds a Static Maps API URL showing the route as a path with a marker at each supercharger,
+// for link previews and emails. size is "WIDTHxHEIGHT" in pixels. The path is simplified, and failing that
+// the furthest markers dropped, to keep the URL within MaxStaticMapURLLength. It returns an empty string
+// if the route has no path or can't fit.
+// The key ends up wherever the URL is shown, so it should be one restricted to the Static Maps API.
+func StaticMapURL(route *RouteInfo, superchargers []SuperchargerWithETA, size string, apiKey string) string {
+	if route == nil {
+		return ""
+	}
+	points, err := route.Path()
+	if err != nil || len(points) < 2 {
+		return ""
+	}
+	if !staticMapSizePattern.MatchString(size) {
+		size = DefaultStaticMapSize
+	}
+
+	markers := make([]string, 0, len(superchargers))
+	for _, sc := range superchargers {
+		if sc.Supercharger == nil {
+			continue
+		}
+		markers = append(markers, fmt.Sprintf("%.5f,%.5f", sc.Supercharger.Latitude, sc.Supercharger.Longitude))
+	}
+
+	var encodedPath string
+	for _, tolerance := range staticMapTolerances {
+		// each pass simplifies the last one's result, which is much smaller than the full path
+		points = simplifyPath(points, tolerance)
+		encodedPath = EncodePolyline(points)
+		if u := staticMapURL(encodedPath, markers, size, apiKey); len(u) <= MaxStaticMapURLLength {
+			return u
+		}
+	}
+
+	// the simplest path is still too long with every marker, so show the nearest chargers only
+	for n := len(markers) - 1; n >= 0; n-- {
+		if u := staticMapURL(encodedPath, markers[:n], size, apiKey); len(u) <= MaxStaticMapURLLength {
+			return u
+		}
+	}
+	return ""
+}
+
+// staticMapURL encodes one candidate Static Maps URL
+func staticMapURL(encodedPath string, markers []string, size, apiKey string) string {
+	query := url.Values{}
+	query.Set("size", size)
+	query.Set("path", "color:0x4285f4cc|weight:4|enc:"+encodedPath)
+	if len(markers) > 0 {
+		query.Set("markers", "size:small|color:red|"+strings.Join(markers, "|"))
+	}
+	query.Set("key", apiKey)
+	return staticMapEndpoint + "?" + query.Encode()
+}
+
+// simplifyPath drops points that lie within tolerance meters of the line through their neighbours
+// (Douglas-Peucker), keeping the first and last points. A tolerance of zero returns the points unchanged.
+func simplifyPath(points []Center, tolerance float64) []Center {
+	if tolerance <= 0 || len(points) < 3 {
+		return points
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		farthest, farthestDist := -1, tolerance
+		a, b := points[span[0]], points[span[1]]
+		// a flat projection around the span is plenty for picking points and avoids trig per point
+		lngScale := metersPerDegree * math.Cos(a.Latitude*math.Pi/180)
+		bx, by := (b.Longitude-a.Longitude)*lngScale, (b.Latitude-a.Latitude)*metersPerDegree
+		length2 := bx*bx + by*by
+		for i := span[0] + 1; i < span[1]; i++ {
+			px, py := (points[i].Longitude-a.Longitude)*lngScale, (points[i].Latitude-a.Latitude)*metersPerDegree
+			t := 0.0
+			if length2 > 0 {
+				t = math.Max(0, math.Min(1, (px*bx+py*by)/length2))
+			}
+			if d := math.Hypot(px-t*bx, py-t*by); d > farthestDist {
+				farthest, farthestDist = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, [2]int{span[0], farthest}, [2]int{farthest, span[1]})
+		}
+	}
+
+	simplified := make([]Center, 0, len(points))
+	for i, p := range points {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
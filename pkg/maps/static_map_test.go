@@ -0,0 +1,77 @@
+package maps
+
+import (
+	"math"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestStaticMapURL(t *testing.T) {
+	path := []Center{{Latitude: 38, Longitude: -121}, {Latitude: 38.5, Longitude: -120.5}, {Latitude: 39, Longitude: -120}}
+	route := &RouteInfo{EncodedPolyline: EncodePolyline(path)}
+	superchargers := []SuperchargerWithETA{
+		{Supercharger: &db.Supercharger{Latitude: 38.25, Longitude: -120.75}},
+		{Supercharger: &db.Supercharger{Latitude: 38.75, Longitude: -120.25}},
+	}
+
+	u, err := url.Parse(StaticMapURL(route, superchargers, "bogus", "static-key"))
+	if err != nil {
+		t.Fatalf("Invalid URL: %v", err)
+	}
+	query := u.Query()
+	if query.Get("size") != DefaultStaticMapSize {
+		t.Errorf("Expected an invalid size to fall back to %s, got %q", DefaultStaticMapSize, query.Get("size"))
+	}
+	if query.Get("key") != "static-key" {
+		t.Errorf("Expected the key to be set, got %q", query.Get("key"))
+	}
+	if !strings.HasSuffix(query.Get("path"), "enc:"+route.EncodedPolyline) {
+		t.Errorf("Expected the route as an encoded path, got %q", query.Get("path"))
+	}
+	if markers := query.Get("markers"); !strings.Contains(markers, "38.25000,-120.75000") || !strings.Contains(markers, "38.75000,-120.25000") {
+		t.Errorf("Expected a marker at each supercharger, got %q", markers)
+	}
+
+	if got := StaticMapURL(&RouteInfo{}, superchargers, "", "static-key"); got != "" {
+		t.Errorf("Expected no URL for a route without a path, got %q", got)
+	}
+}
+
+func TestStaticMapURLFitsLongRoutes(t *testing.T) {
+	// a wiggly coast-to-coast path far too detailed to send whole
+	var path []Center
+	for i := 0; i < 20000; i++ {
+		path = append(path, Center{Latitude: 38 + 0.01*math.Sin(float64(i)/10), Longitude: -122 + float64(i)*0.003})
+	}
+	route := &RouteInfo{EncodedPolyline: EncodePolyline(path)}
+	superchargers := make([]SuperchargerWithETA, 300)
+	for i := range superchargers {
+		superchargers[i].Supercharger = &db.Supercharger{Latitude: 38, Longitude: -122 + float64(i)*0.2}
+	}
+
+	got := StaticMapURL(route, superchargers, "640x640", "static-key")
+	if got == "" || len(got) > MaxStaticMapURLLength {
+		t.Fatalf("Expected a URL within %d characters, got %d", MaxStaticMapURLLength, len(got))
+	}
+}
+
+func TestSimplifyPath(t *testing.T) {
+	// the middle points are within a few meters of a straight line, the last bend is not
+	points := []Center{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0.00001, Longitude: 0.1},
+		{Latitude: 0, Longitude: 0.2},
+		{Latitude: 0.5, Longitude: 0.3},
+	}
+
+	simplified := simplifyPath(points, 50)
+	if len(simplified) != 3 || simplified[0] != points[0] || simplified[1] != points[2] || simplified[2] != points[3] {
+		t.Errorf("Expected the near-straight middle point dropped, got %v", simplified)
+	}
+	if unchanged := simplifyPath(points, 0); len(unchanged) != len(points) {
+		t.Errorf("Expected zero tolerance to keep every point, got %v", unchanged)
+	}
+}
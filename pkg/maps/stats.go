@@ -0,0 +1,71 @@
+package maps
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+// SKU names used to count calls this process makes to each Google API
+const (
+	SKUAutocomplete = "autocomplete"
+	SKUTextSearch   = "places_text_search"
+	SKUNearbySearch = "places_nearby_search"
+	SKUPlaceDetails = "place_details"
+	SKURoutes       = "routes"
+	SKURouteMatrix  = "route_matrix"
+	SKUTimeZone     = "time_zone"
+	SKUGeocoding    = "geocoding"
+)
+
+// callCounts maps an SKU name to an *atomic.Int64 of calls made since the process started.
+// Counters are created on first use and never removed, so counting after that is lock free.
+var callCounts sync.Map
+
+// countCall records one call to the given SKU
+func countCall(sku string) {
+	counter, ok := callCounts.Load(sku)
+	if !ok {
+		counter, _ = callCounts.LoadOrStore(sku, new(atomic.Int64))
+	}
+	counter.(*atomic.Int64).Add(1)
+}
+
+// Stats returns the number of calls this process has made to each SKU since it started.
+// Unlike the MapsCallLog table it costs no database query, and it resets on restart.
+func Stats() map[string]int64 {
+	stats := make(map[string]int64)
+	callCounts.Range(func(sku, counter any) bool {
+		stats[sku.(string)] = counter.(*atomic.Int64).Load()
+		return true
+	})
+	return stats
+}
+
+// callLog buffers an entry for each Places, Routes and autocomplete call, nil when disabled
+var callLog atomic.Pointer[db.MapsCallLogBuffer]
+
+// SetCallLog starts recording a MapsCallLog entry for every Places, Routes and autocomplete call into the
+// buffer, so spend can be analysed later. Passing nil stops logging.
+func SetCallLog(buffer *db.MapsCallLogBuffer) {
+	callLog.Store(buffer)
+}
+
+// logCall records a finished call in the call log if logging is enabled. placeID and details are left
+// empty when they don't apply.
+func logCall(sku, placeID, details string, callErr error) {
+	buffer := callLog.Load()
+	if buffer == nil {
+		return
+	}
+	entry := db.MapsCallLog{SKU: sku, Timestamp: time.Now().UTC(), Details: details}
+	if placeID != "" {
+		entry.PlaceID = &placeID
+	}
+	if callErr != nil {
+		entry.Error = callErr.Error()
+	}
+	buffer.Record(entry)
+}
@@ -0,0 +1,91 @@
+package maps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestCountCallConcurrent(t *testing.T) {
+	const sku = "test_sku"
+	before := Stats()[sku]
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				countCall(sku)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := Stats()[sku] - before; got != 5000 {
+		t.Errorf("Expected 5000 calls counted, got %d", got)
+	}
+}
+
+func TestSetCallLog(t *testing.T) {
+	service := newTestDB(t)
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, `{"error":{"code":500,"status":"INTERNAL"}}`, http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"id":"ChIJtestPlace"}`))
+	}))
+	defer server.Close()
+
+	originalEndpoint := placeDetailsEndpoint
+	defer func() { placeDetailsEndpoint = originalEndpoint }()
+	placeDetailsEndpoint = server.URL
+
+	// calls aren't logged until a log is set
+	if _, err := GetPlaceDetails(context.Background(), "key", "ChIJtestPlace", "id", Locale{}); err != nil {
+		t.Fatalf("GetPlaceDetails failed: %v", err)
+	}
+
+	buffer := db.NewMapsCallLogBuffer(service.MapsCallLog, 100, time.Hour)
+	SetCallLog(buffer)
+	defer SetCallLog(nil)
+
+	if _, err := GetPlaceDetails(context.Background(), "key", "ChIJtestPlace", "id", Locale{}); err != nil {
+		t.Fatalf("GetPlaceDetails failed: %v", err)
+	}
+	fail = true
+	if _, err := GetPlaceDetails(context.Background(), "key", "ChIJtestPlace", "id", Locale{}); err == nil {
+		t.Fatal("Expected the failing call to return an error")
+	}
+
+	if err := buffer.Close(); err != nil {
+		t.Fatalf("Failed to flush call log: %v", err)
+	}
+
+	logs, total, err := service.MapsCallLog.Find(db.MapsCallLogFilter{SKU: SKUPlaceDetails}, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to read call log: %v", err)
+	}
+	if total != 2 {
+		t.Fatalf("Expected the 2 calls made while logging, got %d", total)
+	}
+	var failed int
+	for _, entry := range logs {
+		if entry.PlaceID == nil || *entry.PlaceID != "ChIJtestPlace" {
+			t.Errorf("Expected the place ID logged, got %v", entry.PlaceID)
+		}
+		if entry.Error != "" {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("Expected 1 logged call with an error, got %d", failed)
+	}
+}
@@ -2,22 +2,183 @@ package maps
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math"
-	"strings"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/brensch/passengerprincess/pkg/db"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
 const (
 	// SuperchargerSearchRadiusMeters defines the search radius around each circle to look for superchargers
 	SuperchargerSearchRadiusMeters = 5000
+	// DefaultRestaurantRadiusMeters is how far from a supercharger restaurants are searched for
+	DefaultRestaurantRadiusMeters = 500
+	// DefaultMaxRestaurantsPerSupercharger bounds the restaurants kept for chargers in malls and city centres
+	DefaultMaxRestaurantsPerSupercharger = 50
+	// DefaultMaxRouteDistanceMeters comfortably covers a US coast-to-coast drive
+	DefaultMaxRouteDistanceMeters = 6000000
+	// DefaultCircleSearchTimeout bounds a single circle's search, well inside the API's 30s request budget
+	DefaultCircleSearchTimeout = 8 * time.Second
+	// MaxDistanceFromRouteMeters is how far off the route a supercharger can be and still be returned
+	MaxDistanceFromRouteMeters = 20000
+	// DefaultRestaurantTTL is how long a supercharger's cached restaurants are used before searching again
+	DefaultRestaurantTTL = 30 * 24 * time.Hour
 )
 
+// CacheTTL sets how long each part of a cached supercharger is trusted. Zero never expires.
+type CacheTTL struct {
+	// Supercharger is how long a place's identity and location are used before its details are fetched again.
+	// Chargers rarely move and non-charger classifications rarely change, so by default they never expire.
+	// The row is updated in place, keeping its timezone, rating and restaurants still within their own TTL
+	// unless the charger has moved. If the refetch fails the expired row is served.
+	Supercharger time.Duration
+	// Restaurants is how long a supercharger's restaurants are used before they are searched for again.
+	// Refreshing them keeps the cached supercharger, so it costs no details call.
+	Restaurants time.Duration
+}
+
+// DefaultCacheTTL keeps supercharger details forever and refreshes restaurants every DefaultRestaurantTTL
+func DefaultCacheTTL() CacheTTL {
+	return CacheTTL{Restaurants: DefaultRestaurantTTL}
+}
+
+// expired reports whether data cached at updated is older than ttl
+func expired(updated time.Time, ttl time.Duration) bool {
+	return ttl > 0 && time.Since(updated) > ttl
+}
+
+// ErrRouteTooLong is returned when a route exceeds the configured maximum distance,
+// before any of the per-circle searches are paid for.
+var ErrRouteTooLong = errors.New("route is too long")
+
+// ErrEmptyRoute is returned when Google returns a route without a usable polyline,
+// which happens for unroutable inputs or when origin and destination are the same place.
+var ErrEmptyRoute = errors.New("route has no path")
+
+// SortOrder controls the order superchargers are returned in
+type SortOrder string
+
+const (
+	// SortByDistanceAlongRoute orders superchargers from origin to destination
+	SortByDistanceAlongRoute SortOrder = "distance"
+	// SortByArrivalTime orders superchargers by estimated arrival time
+	SortByArrivalTime SortOrder = "eta"
+	// SortByFoodRating orders superchargers by the average rating of their restaurants, best first.
+	// Superchargers without rated restaurants go last.
+	SortByFoodRating SortOrder = "food"
+)
+
+// SearchConfig holds options for finding superchargers on a route
+type SearchConfig struct {
+	SortBy SortOrder
+	Score  ScoreContext
+	// MaxRouteDistanceMeters rejects longer routes. Zero disables the check.
+	MaxRouteDistanceMeters int
+	// FetchRestaurants looks up restaurants near each supercharger. Skipping it roughly halves API cost.
+	FetchRestaurants bool
+	// RestaurantRadiusMeters is how far from each supercharger to look for restaurants
+	RestaurantRadiusMeters float64
+	// MaxRestaurantsPerSupercharger caps the restaurants stored and returned for each supercharger, keeping the
+	// best rated and closest as db.TopRestaurants ranks them. Zero keeps them all.
+	MaxRestaurantsPerSupercharger int
+	// CurrentPosition measures ETAs from where the driver is now instead of the origin, and drops
+	// superchargers already passed. Nil means the trip hasn't started.
+	CurrentPosition *Center
+	// CircleSearchTimeout abandons a single slow circle search so it can't stall the rest. Zero disables it.
+	CircleSearchTimeout time.Duration
+	// Locale sets the language and region for place searches, so results and names match the area being driven through
+	Locale Locale
+	// FetchReviews adds the charger's own Google rating and review count. The fields are billed at the
+	// Enterprise rate, so it is off by default and cached ratings are reused for ReviewRefreshInterval.
+	FetchReviews bool
+	// RouteOptions customizes the route request, e.g. skipping traffic to use a cheaper routing SKU
+	RouteOptions RouteOptions
+	// Geocoder resolves the origin and destination before routing. Nil lets the Routes API resolve the raw addresses.
+	Geocoder Geocoder
+	// Spatial tunes the route index grid and search circle placement
+	Spatial SpatialConfig
+	// MaxCircles widens the search radius on long routes so no more than this many circle searches are made.
+	// Zero leaves the radius at SuperchargerSearchRadiusMeters.
+	MaxCircles int
+	// WalkingDistanceTopN gets walking distances to this many of the closest restaurants at each supercharger.
+	// It costs a Route Matrix call per supercharger, so zero disables it.
+	WalkingDistanceTopN int
+	// ETAFormat is how arrival times are written. The zero value uses ETAFormatKitchen.
+	ETAFormat ETAFormat
+	// CacheTTL controls when cached superchargers and their restaurants are fetched again
+	CacheTTL CacheTTL
+	// RouteReuseMaxAge reuses a route stored by an earlier search between the same places if it is newer
+	// than this, skipping the Routes API call. Durations and traffic go stale, so zero never reuses them.
+	// Every fetched route is stored either way.
+	RouteReuseMaxAge time.Duration
+	// ExcludePlaceIDs leaves these chargers out of the results, e.g. stops already used on earlier legs of a trip.
+	// They're dropped before their details are fetched so they cost nothing.
+	ExcludePlaceIDs []string
+	// MinMatchConfidence is how confident, from 0 to 1, a newly looked up place must be to count as a
+	// supercharger. Places below it are rejected. Zero uses DefaultMinMatchConfidence.
+	MinMatchConfidence float64
+	// SeparateRejectedPlaces records places that turn out not to be superchargers by ID only in the rejected
+	// place table, instead of as full supercharger rows. Rejected places are recognised either way.
+	SeparateRejectedPlaces bool
+
+	// PreferCached takes superchargers from the cache where it already covers the route well, only running
+	// Google circle searches where fewer than MinCachedPerCircle are cached. Popular routes then cost few
+	// searches, at the risk of missing a charger opened next to cached ones.
+	PreferCached bool
+	// MinCachedPerCircle is how many cached superchargers a circle needs for PreferCached to skip its search.
+	// Zero uses DefaultMinCachedPerCircle.
+	MinCachedPerCircle int
+
+	// CirclesPerPage limits how many circles one request searches, returning a NextContinuation to search the
+	// rest of the route in later requests. Zero searches the whole route at once.
+	CirclesPerPage int
+	// ContinueFrom resumes a paged search this many meters along the route, from ParseContinuation.
+	// The stored route is reused so every page shares the same geometry.
+	ContinueFrom float64
+
+	// AverageSpeedKmh times ETAs along a track given to GetSuperchargersAlongTrack, which has no Google
+	// duration. Zero uses DefaultAverageSpeedKmh.
+	AverageSpeedKmh float64
+
+	// restaurantPool shares restaurant searches between neighbouring superchargers on one route
+	restaurantPool *restaurantPool
+	// cachedRows are the stored superchargers for a route's candidates, loaded in one query before fetching.
+	// IDs missing from it aren't stored, so nil means look each one up separately.
+	cachedRows map[string]*db.Supercharger
+}
+
+// minMatchConfidence returns the confidence a place needs to be a supercharger, defaulting to DefaultMinMatchConfidence
+func (c *SearchConfig) minMatchConfidence() float64 {
+	if c.MinMatchConfidence <= 0 {
+		return DefaultMinMatchConfidence
+	}
+	return c.MinMatchConfidence
+}
+
+// DefaultSearchConfig returns default search configuration
+func DefaultSearchConfig() *SearchConfig {
+	return &SearchConfig{
+		SortBy:                 SortByDistanceAlongRoute,
+		Score:                  DefaultScoreContext(),
+		MaxRouteDistanceMeters: DefaultMaxRouteDistanceMeters,
+		FetchRestaurants:       true,
+		RestaurantRadiusMeters: DefaultRestaurantRadiusMeters,
+		CircleSearchTimeout:    DefaultCircleSearchTimeout,
+		Spatial:                DefaultSpatialConfig(),
+		CacheTTL:               DefaultCacheTTL(),
+
+		MaxRestaurantsPerSupercharger: DefaultMaxRestaurantsPerSupercharger,
+	}
+}
+
 type superchargerResult struct {
 	supercharger *db.Supercharger
 	restaurants  []db.RestaurantWithDistance
@@ -28,10 +189,22 @@ type superchargerResult struct {
 type SuperchargerWithETA struct {
 	Supercharger        *db.Supercharger            `json:"supercharger"`
 	Restaurants         []db.RestaurantWithDistance `json:"restaurants"`
-	ArrivalTime         string                      `json:"arrival_time"`           // Formatted arrival time
+	ArrivalTime         string                      `json:"arrival_time"`           // Arrival time in the format SearchConfig.ETAFormat asked for
+	ArrivalTimestampMS  int64                       `json:"arrival_timestamp_ms"`   // Arrival time in milliseconds since the Unix epoch, for clients to format themselves
 	DistanceFromRoute   float64                     `json:"distance_from_route"`    // Distance from route in meters
 	DistanceAlongRoute  float64                     `json:"distance_along_route"`   // Distance along route in meters
+	DistanceRemaining   float64                     `json:"distance_remaining"`     // Distance along route from the current position in meters
 	ClosestPointOnRoute Center                      `json:"closest_point_on_route"` // Closest point on the route
+	Score               float64                     `json:"score"`                  // 0-100 quality score from ScoreCharger
+	// AvgFoodRating is the review-weighted average rating of the nearby restaurants and BestFoodRating the
+	// highest, both nil when no restaurant has reviews
+	AvgFoodRating  *float64 `json:"avg_food_rating,omitempty"`
+	BestFoodRating *float64 `json:"best_food_rating,omitempty"`
+	// Reliability is the share of lookups of the charger that went cleanly, from 0 to 1, lowered by failed
+	// fetches and by flipping between being a supercharger and not. Chargers without history score 1.
+	Reliability float64 `json:"reliability"`
+
+	arrival time.Time // unformatted arrival time, used for sorting
 }
 
 // CumPoint represents a point on the route with cumulative distance and duration
@@ -68,11 +241,42 @@ type PolylineSegment struct {
 	CumulativeDist float64
 }
 
-// buildPolylineIndex creates a spatial index for the given polyline
+// normalizeLongitude wraps a longitude into [-180, 180)
+func normalizeLongitude(lng float64) float64 {
+	return math.Mod(math.Mod(lng+180, 360)+360, 360) - 180
+}
+
+// unwrapLongitudes shifts longitudes by whole turns so that no step between neighbours is more than 180
+// degrees, making a path that crosses the antimeridian continuous, e.g. 179 then 181 rather than -179.
+// The points are returned as they are when the path doesn't cross it.
+func unwrapLongitudes(points []Center) []Center {
+	crosses := false
+	for i := 1; i < len(points); i++ {
+		if math.Abs(points[i].Longitude-points[i-1].Longitude) > 180 {
+			crosses = true
+			break
+		}
+	}
+	if !crosses {
+		return points
+	}
+
+	unwrapped := make([]Center, len(points))
+	unwrapped[0] = points[0]
+	for i := 1; i < len(points); i++ {
+		step := normalizeLongitude(points[i].Longitude - points[i-1].Longitude)
+		unwrapped[i] = Center{Latitude: points[i].Latitude, Longitude: unwrapped[i-1].Longitude + step}
+	}
+	return unwrapped
+}
+
+// buildPolylineIndex creates a spatial index for the given polyline. A polyline crossing the antimeridian
+// is indexed with its longitudes unwrapped, so the grid covers the route rather than the whole globe.
 func buildPolylineIndex(polyline []Center, gridSize float64) *PolylineIndex {
 	if len(polyline) < 2 {
 		return nil
 	}
+	polyline = unwrapLongitudes(polyline)
 
 	// Find bounds
 	minLat, maxLat := polyline[0].Latitude, polyline[0].Latitude
@@ -182,12 +386,20 @@ func buildPolylineIndex(polyline []Center, gridSize float64) *PolylineIndex {
 	}
 }
 
-// distanceToPolylineWithIndex calculates distance using spatial index for better performance
-func distanceToPolylineWithIndex(point Center, index *PolylineIndex) (float64, float64, Center) {
-	if index == nil || len(index.polyline) < 2 {
-		return distanceToPolyline(point, index.polyline)
+// localize shifts a point's longitude by whole turns to the copy nearest the indexed area, matching the
+// unwrapped longitudes of a polyline that crosses the antimeridian
+func (index *PolylineIndex) localize(point Center) Center {
+	middle := (index.minLng + index.maxLng) / 2
+	if math.Abs(point.Longitude-middle) > 180 {
+		point.Longitude = middle + normalizeLongitude(point.Longitude-middle)
 	}
+	return point
+}
 
+// nearbySegments returns the distinct segments in the grid cells around point.
+// It is empty when the point is outside the indexed area.
+func (index *PolylineIndex) nearbySegments(point Center) []PolylineSegment {
+	point = index.localize(point)
 	// Find candidate segments in nearby grid cells
 	var candidateSegments []PolylineSegment
 
@@ -207,11 +419,6 @@ func distanceToPolylineWithIndex(point Center, index *PolylineIndex) (float64, f
 		}
 	}
 
-	// If no candidates found (point outside bounds), check all segments
-	if len(candidateSegments) == 0 {
-		return distanceToPolyline(point, index.polyline)
-	}
-
 	// Remove duplicates (segments might be in multiple cells)
 	seen := make(map[int]bool)
 	var uniqueSegments []PolylineSegment
@@ -222,6 +429,26 @@ func distanceToPolylineWithIndex(point Center, index *PolylineIndex) (float64, f
 		}
 	}
 
+	return uniqueSegments
+}
+
+// distanceToPolylineWithIndex calculates distance using spatial index for better performance
+func distanceToPolylineWithIndex(point Center, index *PolylineIndex) (float64, float64, Center) {
+	if index == nil {
+		return distanceToPolyline(point, nil)
+	}
+	if len(index.polyline) < 2 {
+		return distanceToPolyline(point, index.polyline)
+	}
+	point = index.localize(point)
+
+	uniqueSegments := index.nearbySegments(point)
+
+	// If no candidates found (point outside bounds), check all segments
+	if len(uniqueSegments) == 0 {
+		return normalizeClosest(distanceToPolyline(point, index.polyline))
+	}
+
 	// Calculate distances only for candidate segments
 	minDist := math.MaxFloat64
 	distAlongRoute := 0.0
@@ -252,7 +479,16 @@ func distanceToPolylineWithIndex(point Center, index *PolylineIndex) (float64, f
 		}
 	}
 
-	return minDist, distAlongRoute, closestPoint
+	return normalizeClosest(minDist, distAlongRoute, closestPoint)
+}
+
+// normalizeClosest passes through the results of a polyline distance, wrapping the closest point back into
+// [-180, 180) if it was found on an unwrapped polyline
+func normalizeClosest(dist, distAlongRoute float64, closestPoint Center) (float64, float64, Center) {
+	if math.Abs(closestPoint.Longitude) > 180 {
+		closestPoint.Longitude = normalizeLongitude(closestPoint.Longitude)
+	}
+	return dist, distAlongRoute, closestPoint
 }
 
 // distanceToPolyline calculates the shortest distance from a point to a polyline.
@@ -306,9 +542,8 @@ func distanceToSegment(p, v, w Center) float64 {
 	return haversineDistance(p, Center{Latitude: closestLat, Longitude: closestLng})
 }
 
-// calculateETA calculates the estimated arrival time at a supercharger
-// based on route duration and distance from route
-func calculateETA(cumulativePoints []CumPoint, distAlongRoute, distFromRoute float64, totalRouteDist float64, totalRouteDur time.Duration) time.Time {
+// routeDurationTo estimates how long it takes to drive distAlongRoute meters from the start of the route
+func routeDurationTo(cumulativePoints []CumPoint, distAlongRoute float64, totalRouteDist float64, totalRouteDur time.Duration) time.Duration {
 	// Find the closest cumulative point for accurate ETA
 	var selectedCumDur int
 	var foundDuration bool
@@ -331,8 +566,17 @@ func calculateETA(cumulativePoints []CumPoint, distAlongRoute, distFromRoute flo
 		}
 	}
 
+	return time.Duration(selectedCumDur) * time.Second
+}
+
+// calculateETA estimates the arrival time at a supercharger for a driver currently startDistance meters
+// along the route. A startDistance of zero means the driver is leaving from the origin now.
+func calculateETA(cumulativePoints []CumPoint, startDistance, distAlongRoute, distFromRoute float64, totalRouteDist float64, totalRouteDur time.Duration) time.Time {
 	// Calculate arrival time
-	durationToSupercharger := time.Duration(selectedCumDur) * time.Second
+	durationToSupercharger := routeDurationTo(cumulativePoints, distAlongRoute, totalRouteDist, totalRouteDur)
+	if startDistance > 0 {
+		durationToSupercharger -= routeDurationTo(cumulativePoints, startDistance, totalRouteDist, totalRouteDur)
+	}
 	arrivalTime := time.Now().Add(durationToSupercharger)
 
 	// Add time to travel from route to supercharger at 50 km/h
@@ -345,13 +589,129 @@ func calculateETA(cumulativePoints []CumPoint, distAlongRoute, distFromRoute flo
 
 // SuperchargersOnRouteResult holds both the route information and the superchargers found along it
 type SuperchargersOnRouteResult struct {
+	Origin        string                `json:"origin"`
+	Destination   string                `json:"destination"`
 	Route         *RouteInfo            `json:"route"`
 	Superchargers []SuperchargerWithETA `json:"superchargers"` // Superchargers with ETA information
 	SearchCircles []Circle              `json:"search_circles"`
+	// SearchRadiusMeters is the circle radius used, larger than the default when MaxCircles widened it
+	SearchRadiusMeters float64 `json:"search_radius_meters"`
+	// TrafficDelaySeconds is how much traffic adds to the typical duration, nil when unknown
+	TrafficDelaySeconds *int `json:"traffic_delay_seconds,omitempty"`
+	// SkippedCircles counts search circles abandoned for taking too long, so results may be incomplete
+	SkippedCircles int `json:"skipped_circles,omitempty"`
+	// NextContinuation resumes the search where this page stopped, empty once the whole route is searched
+	NextContinuation string `json:"next_continuation,omitempty"`
+	// StaticMapURL is a Static Maps image of the route and its superchargers from StaticMapURL, empty unless
+	// the caller sets it
+	StaticMapURL string `json:"static_map_url,omitempty"`
+	// SimplifiedPolylines are the route path simplified for lower zoom levels, encoded and keyed by their
+	// tolerance in meters. Empty unless the caller sets them from RouteInfo.SimplifiedPolylines.
+	SimplifiedPolylines map[string]string `json:"simplified_polylines,omitempty"`
+}
+
+// searchCircles searches every circle for superchargers in parallel and returns the distinct place IDs found,
+// mapped to their locations when the search returned one.
+// Each search gets its own deadline of timeout; circles that miss it are dropped and counted rather than
+// failing the whole search. Any other error cancels the remaining searches and is returned.
+func searchCircles(ctx context.Context, apiKey string, circles []Circle, timeout time.Duration, locale Locale) (map[string]*Center, int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	seenPlaceIDs := make(map[string]*Center)
+	skipped := 0
+
+	// Parallel search for superchargers
+	type searchResult struct {
+		center   Center
+		places   []*PlaceDetails
+		err      error
+		timedOut bool
+	}
+	searchResultsChan := make(chan searchResult, len(circles))
+	var searchWg sync.WaitGroup
+
+	for _, circle := range circles {
+		searchWg.Add(1)
+		go func(c Circle) {
+			defer searchWg.Done()
+			circleCtx := ctx
+			if timeout > 0 {
+				var circleCancel context.CancelFunc
+				circleCtx, circleCancel = context.WithTimeout(ctx, timeout)
+				defer circleCancel()
+			}
+			places, err := GetPlacesViaTextSearch(circleCtx, apiKey, "tesla supercharger", FieldMaskSuperchargerTextSearch, c, locale)
+			// only this circle's deadline passing counts as a skip, not the whole request's
+			timedOut := err != nil && ctx.Err() == nil && circleCtx.Err() == context.DeadlineExceeded
+			searchResultsChan <- searchResult{center: c.Center, places: places, err: err, timedOut: timedOut}
+		}(circle)
+	}
+
+	go func() {
+		searchWg.Wait()
+		close(searchResultsChan)
+	}()
+
+	// Collect results
+	for res := range searchResultsChan {
+		if res.timedOut {
+			logf(LogWarn, "Circle search at %.5f,%.5f exceeded %v, skipping it", res.center.Latitude, res.center.Longitude, timeout)
+			skipped++
+			continue
+		}
+		if res.err != nil {
+			cancel()
+			return nil, 0, res.err
+		}
+		for _, place := range res.places {
+			var location *Center
+			if place.Location != nil {
+				location = &Center{Latitude: place.Location.Latitude, Longitude: place.Location.Longitude}
+			}
+			seenPlaceIDs[place.ID] = location
+		}
+	}
+
+	return seenPlaceIDs, skipped, nil
+}
+
+// filterToCorridor drops places known to be further than maxDistance from the route, so their
+// details are never fetched. Places without a location are kept since they can't be checked yet.
+func filterToCorridor(places map[string]*Center, index *PolylineIndex, maxDistance float64) []string {
+	var ids []string
+	for id, location := range places {
+		if location != nil && index != nil {
+			if distFromRoute, _, _ := distanceToPolylineWithIndex(*location, index); distFromRoute > maxDistance {
+				continue
+			}
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// excludePlaces drops the excluded place IDs, keeping the order of the rest
+func excludePlaces(placeIDs, exclude []string) []string {
+	if len(exclude) == 0 {
+		return placeIDs
+	}
+	excluded := make(map[string]bool, len(exclude))
+	for _, id := range exclude {
+		excluded[id] = true
+	}
+	kept := placeIDs[:0:0]
+	for _, id := range placeIDs {
+		if !excluded[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept
 }
 
 // processSuperchargers processes supercharger results concurrently to calculate ETAs and distances
-func processSuperchargers(resultsChan <-chan superchargerResult, routePoints []Center, cumulativePoints []CumPoint, polylineIndex *PolylineIndex, route *RouteInfo) ([]SuperchargerWithETA, error) {
+// startDistance is how far along the route the driver currently is; superchargers before it have been passed and are skipped.
+func processSuperchargers(ctx context.Context, broker *db.Service, apiKey string, resultsChan <-chan superchargerResult, routePoints []Center, cumulativePoints []CumPoint, polylineIndex *PolylineIndex, route *RouteInfo, startDistance float64, walkingTopN int, etaFormat ETAFormat) ([]SuperchargerWithETA, error) {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var superchargersWithETA []SuperchargerWithETA
@@ -374,7 +734,9 @@ func processSuperchargers(resultsChan <-chan superchargerResult, routePoints []C
 				return
 			}
 
-			sc := res.supercharger
+			// copy since fetches for the same supercharger may be shared between requests
+			scCopy := *res.supercharger
+			sc := &scCopy
 			scLocation := Center{
 				Latitude:  sc.Latitude,
 				Longitude: sc.Longitude,
@@ -384,20 +746,47 @@ func processSuperchargers(resultsChan <-chan superchargerResult, routePoints []C
 			distFromRoute, distAlongRoute, closestPoint := distanceToPolylineWithIndex(scLocation, polylineIndex)
 
 			// don't include superchargers that are too far from the route
-			if distFromRoute > 20000 {
+			if distFromRoute > MaxDistanceFromRouteMeters {
 				return
 			}
 
-			arrivalTime := calculateETA(cumulativePoints, distAlongRoute, distFromRoute, float64(route.DistanceMeters), route.Duration)
+			// already driven past
+			if distAlongRoute < startDistance {
+				return
+			}
+
+			arrivalTime := calculateETA(cumulativePoints, startDistance, distAlongRoute, distFromRoute, float64(route.DistanceMeters), route.Duration)
+
+			restaurants := res.restaurants
+			if heading, ok := routeHeadingAt(closestPoint, polylineIndex); ok {
+				restaurants = orientRestaurants(restaurants, scLocation, heading)
+			}
+			if walkingTopN > 0 {
+				withWalking, err := addWalkingDistances(ctx, apiKey, scLocation, restaurants, walkingTopN)
+				if err != nil {
+					// straight line distances are still useful, so don't fail the request
+					log.Printf("Warning: failed to get walking distances for supercharger %s: %v", sc.PlaceID, err)
+				} else {
+					restaurants = withWalking
+				}
+			}
+			loc := resolveTimeZone(ctx, broker, apiKey, sc)
+			avgFood, bestFood := foodRatings(restaurants)
 
 			eta := SuperchargerWithETA{
 				Supercharger:        sc,
-				ArrivalTime:         arrivalTime.Format(time.Kitchen), // e.g., "3:45PM"
+				ArrivalTime:         etaFormat.format(arrivalTime, loc),
+				ArrivalTimestampMS:  arrivalTime.UnixMilli(),
 				DistanceFromRoute:   distFromRoute,
 				DistanceAlongRoute:  distAlongRoute,
+				DistanceRemaining:   distAlongRoute - startDistance,
 				ClosestPointOnRoute: closestPoint,
-				Restaurants:         res.restaurants,
+				Restaurants:         restaurants,
+				AvgFoodRating:       avgFood,
+				BestFoodRating:      bestFood,
+				arrival:             arrivalTime,
 			}
+			SetOpenAtArrival(&eta, loc)
 
 			mu.Lock()
 			superchargersWithETA = append(superchargersWithETA, eta)
@@ -415,98 +804,221 @@ func processSuperchargers(resultsChan <-chan superchargerResult, routePoints []C
 	}
 }
 
-func GetSuperchargersOnRoute(ctx context.Context, broker *db.Service, apiKey, origin, destination string) (*SuperchargersOnRouteResult, error) {
+// sortSuperchargers orders superchargers deterministically, breaking ties on place ID
+// so identical requests always return the same order.
+func sortSuperchargers(superchargers []SuperchargerWithETA, order SortOrder) {
+	sort.SliceStable(superchargers, func(i, j int) bool {
+		a, b := superchargers[i], superchargers[j]
+		switch order {
+		case SortByArrivalTime:
+			if !a.arrival.Equal(b.arrival) {
+				return a.arrival.Before(b.arrival)
+			}
+		case SortByFoodRating:
+			if (a.AvgFoodRating == nil) != (b.AvgFoodRating == nil) {
+				return a.AvgFoodRating != nil
+			}
+			if a.AvgFoodRating != nil && *a.AvgFoodRating != *b.AvgFoodRating {
+				return *a.AvgFoodRating > *b.AvgFoodRating
+			}
+		default:
+			if a.DistanceAlongRoute != b.DistanceAlongRoute {
+				return a.DistanceAlongRoute < b.DistanceAlongRoute
+			}
+		}
+		return a.Supercharger.PlaceID < b.Supercharger.PlaceID
+	})
+}
+
+// routeForConfig gets the route between origin and destination, geocoding them first if the config has a geocoder
+func routeForConfig(ctx context.Context, apiKey, origin, destination string, config *SearchConfig) (*RouteInfo, error) {
+	if config.Geocoder == nil {
+		route, err := GetRoute(ctx, apiKey, origin, destination, config.RouteOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get route: %w", err)
+		}
+		return route, nil
+	}
+
+	originLocation, _, err := config.Geocoder.Geocode(ctx, origin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to geocode origin: %w", err)
+	}
+	destinationLocation, _, err := config.Geocoder.Geocode(ctx, destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to geocode destination: %w", err)
+	}
+
+	route, err := GetRouteBetweenPoints(ctx, apiKey, originLocation, destinationLocation, config.RouteOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route: %w", err)
+	}
+	return route, nil
+}
+
+// GetSuperchargersOnRoute finds the superchargers along the route between origin and destination.
+// A nil config uses DefaultSearchConfig.
+func GetSuperchargersOnRoute(ctx context.Context, broker *db.Service, apiKey, origin, destination string, config *SearchConfig) (*SuperchargersOnRouteResult, error) {
+	if config == nil {
+		config = DefaultSearchConfig()
+	}
+
+	if err := config.Spatial.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid spatial config: %w", err)
+	}
+	spatial := config.Spatial.withDefaults()
+
 	totalStart := time.Now()
 	defer func() {
-		log.Printf("GetSuperchargersOnRoute total time: %v", time.Since(totalStart))
+		logf(LogDebug, "GetSuperchargersOnRoute total time: %v", time.Since(totalStart))
 	}()
 
-	// Get route data (now enhanced with traffic information when available)
+	// Get route data (now enhanced with traffic information when available), reusing a stored route if allowed
 	routeStart := time.Now()
-	route, err := GetRoute(apiKey, origin, destination)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get route: %w", err)
+	routeKey := routeAnalysisKey(origin, destination, config)
+	var route *RouteInfo
+	reuseMaxAge := config.RouteReuseMaxAge
+	if config.ContinueFrom > 0 {
+		reuseMaxAge = max(reuseMaxAge, continuationRouteMaxAge)
+	}
+	// stored routes don't keep steps, so a first page that wants them is routed afresh
+	if reuseMaxAge > 0 && !(config.RouteOptions.IncludeSteps && config.ContinueFrom == 0) {
+		route = loadRouteAnalysis(broker, routeKey, reuseMaxAge)
+	}
+	reusedRoute := route != nil
+	if !reusedRoute {
+		var err error
+		route, err = routeForConfig(ctx, apiKey, origin, destination, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	logf(LogDebug, "Get route time: %v (reused: %v)", time.Since(routeStart), reusedRoute)
+
+	if config.MaxRouteDistanceMeters > 0 && route.DistanceMeters > config.MaxRouteDistanceMeters {
+		return nil, fmt.Errorf("%w: %d meters exceeds the maximum of %d meters", ErrRouteTooLong, route.DistanceMeters, config.MaxRouteDistanceMeters)
 	}
-	log.Printf("Get route time: %v", time.Since(routeStart))
 
 	// Decode the polyline to get route points
 	decodeStart := time.Now()
-	routePoints, err := DecodePolyline(route.EncodedPolyline)
+	routePoints, err := route.Path()
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode polyline: %w", err)
 	}
-	log.Printf("Decode polyline time: %v", time.Since(decodeStart))
+	// stored routes only keep the encoded polyline, so give them coordinates when those were asked for
+	if config.RouteOptions.PolylineEncoding == PolylineEncodingGeoJSON {
+		route.Points = routePoints
+	}
+	if len(routePoints) < 2 {
+		return nil, fmt.Errorf("%w: from %q to %q", ErrEmptyRoute, origin, destination)
+	}
+	logf(LogDebug, "Decode polyline time: %v", time.Since(decodeStart))
+
+	// Get search circles
+	circlesStart := time.Now()
+	circles, searchRadius, err := PolylineToCirclesCapped(route.EncodedPolyline, SuperchargerSearchRadiusMeters, config.MaxCircles, spatial.InterpolationMeters)
+	if err != nil {
+		return nil, err
+	}
+	if searchRadius != SuperchargerSearchRadiusMeters {
+		logf(LogInfo, "Widened search radius to %.0fm to fit %d circles", searchRadius, len(circles))
+	}
+	logf(LogDebug, "Get search circles time: %v", time.Since(circlesStart))
+
+	// only fetched routes are stored so a reused route still ages out
+	if !reusedRoute {
+		saveRouteAnalysis(broker, routeKey, origin, destination, route, config, spatial, searchRadius, len(circles))
+	}
 
+	result, err := searchRoute(ctx, broker, apiKey, route, routePoints, circles, searchRadius, config, spatial)
+	if err != nil {
+		return nil, err
+	}
+	result.Origin = origin
+	result.Destination = destination
+	return result, nil
+}
+
+// searchRoute finds the superchargers in the circles along an already decoded route and works out their ETAs
+func searchRoute(ctx context.Context, broker *db.Service, apiKey string, route *RouteInfo, routePoints []Center, circles []Circle, searchRadius float64, config *SearchConfig, spatial SpatialConfig) (*SuperchargersOnRouteResult, error) {
 	// Build spatial index for fast distance calculations
 	indexStart := time.Now()
-	polylineIndex := buildPolylineIndex(routePoints, 0.01) // 0.01 degrees ≈ 1.11km grid size
-	log.Printf("Build spatial index time: %v", time.Since(indexStart))
+	polylineIndex := buildPolylineIndex(routePoints, spatial.GridSizeDegrees)
+	logf(LogDebug, "Build spatial index time: %v", time.Since(indexStart))
+
+	// Work out how far along the route the driver already is
+	var startDistance float64
+	scoreCtx := config.Score
+	if config.CurrentPosition != nil {
+		_, startDistance, _ = distanceToPolylineWithIndex(*config.CurrentPosition, polylineIndex)
+		// the remaining range is measured from here unless the caller said otherwise
+		if scoreCtx.RangeStartMeters == 0 {
+			scoreCtx.RangeStartMeters = startDistance
+		}
+	}
 
 	// Build cumulative profile for accurate ETAs if we have enhanced route data
 	cumulativeStart := time.Now()
 	var cumulativePoints []CumPoint
 	// Simplified: no detailed steps available, so cumulativePoints remains empty
 	// ETA will be calculated based on total duration and distance from route
-	log.Printf("Build cumulative profile time: %v", time.Since(cumulativeStart))
+	logf(LogDebug, "Build cumulative profile time: %v", time.Since(cumulativeStart))
 
-	// Get search circles
-	circlesStart := time.Now()
-	circles, err := PolylineToCircles(route.EncodedPolyline, SuperchargerSearchRadiusMeters)
-	if err != nil {
-		return nil, err
+	page := pageCircles(circles, polylineIndex, config.ContinueFrom, config.CirclesPerPage)
+	if len(page.circles) < len(circles) {
+		logf(LogDebug, "Searching %d of %d circles from %.0fm along the route", len(page.circles), len(circles), config.ContinueFrom)
 	}
-	log.Printf("Get search circles time: %v", time.Since(circlesStart))
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	// Get all the ids of superchargers along the route
 	searchStart := time.Now()
-	seenPlaceIDs := make(map[string]struct{})
-
-	// Parallel search for superchargers
-	type searchResult struct {
-		places []*PlaceDetails
-		err    error
+	searched := page.circles
+	var cachedIDs map[string]*Center
+	if config.PreferCached {
+		var err error
+		searched, cachedIDs, err = cachedCoverage(broker, page.circles, searchRadius, config.minCachedPerCircle())
+		if err != nil {
+			return nil, err
+		}
+		logf(LogDebug, "Cache covers %d of %d circles with %d superchargers", len(page.circles)-len(searched), len(page.circles), len(cachedIDs))
 	}
-	searchResultsChan := make(chan searchResult, len(circles))
-	var searchWg sync.WaitGroup
-
-	for _, circle := range circles {
-		searchWg.Add(1)
-		go func(c Circle) {
-			defer searchWg.Done()
-			places, err := GetPlacesViaTextSearch(ctx, apiKey, "tesla supercharger", "places.id", c)
-			searchResultsChan <- searchResult{places: places, err: err}
-		}(circle)
+	seenPlaceIDs, skippedCircles, err := searchCircles(ctx, apiKey, searched, config.CircleSearchTimeout, config.Locale)
+	if err != nil {
+		return nil, err
 	}
-
-	go func() {
-		searchWg.Wait()
-		close(searchResultsChan)
-	}()
-
-	// Collect results
-	for res := range searchResultsChan {
-		if res.err != nil {
-			cancel()
-			return nil, res.err
-		}
-		for _, place := range res.places {
-			seenPlaceIDs[place.ID] = struct{}{}
+	for id, location := range cachedIDs {
+		if _, ok := seenPlaceIDs[id]; !ok {
+			seenPlaceIDs[id] = location
 		}
 	}
-	log.Printf("Get supercharger IDs time: %v", time.Since(searchStart))
+	logf(LogDebug, "Get supercharger IDs time: %v", time.Since(searchStart))
 
-	// Fetch details concurrently
+	// Fetch details concurrently, sharing restaurant searches between chargers at the same site
 	fetchStart := time.Now()
-	resultsChan := make(chan superchargerResult, len(seenPlaceIDs))
+	routeConfig := *config
+	routeConfig.restaurantPool = newRestaurantPool()
+	placeIDs := filterToCorridor(seenPlaceIDs, polylineIndex, MaxDistanceFromRouteMeters)
+	logf(LogDebug, "Skipped %d of %d superchargers outside the route corridor", len(seenPlaceIDs)-len(placeIDs), len(seenPlaceIDs))
+	if len(config.ExcludePlaceIDs) > 0 {
+		inCorridor := len(placeIDs)
+		placeIDs = excludePlaces(placeIDs, config.ExcludePlaceIDs)
+		logf(LogDebug, "Excluded %d already visited superchargers", inCorridor-len(placeIDs))
+	}
+	// one query for every stored candidate instead of one each
+	cachedRows, err := broker.Supercharger.BatchGetByIDs(placeIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query superchargers from database: %w", err)
+	}
+	routeConfig.cachedRows = cachedRows
+	resultsChan := make(chan superchargerResult, len(placeIDs))
 	var wg sync.WaitGroup
-	for id := range seenPlaceIDs {
+	for _, id := range placeIDs {
 		wg.Add(1)
 		go func(id string) {
 			defer wg.Done()
-			superCharger, restaurants, err := GetSuperchargerWithCache(ctx, broker, apiKey, id)
+			superCharger, restaurants, err := GetSuperchargerWithCache(ctx, broker, apiKey, id, &routeConfig)
 			resultsChan <- superchargerResult{supercharger: superCharger, restaurants: restaurants, err: err}
 		}(id)
 	}
@@ -516,38 +1028,209 @@ func GetSuperchargersOnRoute(ctx context.Context, broker *db.Service, apiKey, or
 		close(resultsChan)
 	}()
 
-	log.Printf("Fetch supercharger details time: %v", time.Since(fetchStart))
+	logf(LogDebug, "Fetch supercharger details time: %v", time.Since(fetchStart))
 
 	// Process results and calculate ETAs
 	processStart := time.Now()
-	superchargersWithETA, err := processSuperchargers(resultsChan, routePoints, cumulativePoints, polylineIndex, route)
+	superchargersWithETA, err := processSuperchargers(ctx, broker, apiKey, resultsChan, routePoints, cumulativePoints, polylineIndex, route, startDistance, config.WalkingDistanceTopN, config.ETAFormat)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("process superchargers time: %v", time.Since(processStart))
+	// circles near the page edges find chargers belonging to the neighbouring pages
+	inPage := superchargersWithETA[:0]
+	for _, sc := range superchargersWithETA {
+		if page.contains(sc.DistanceAlongRoute) {
+			inPage = append(inPage, sc)
+		}
+	}
+	superchargersWithETA = inPage
+	setReliability(broker, superchargersWithETA)
+	for i := range superchargersWithETA {
+		superchargersWithETA[i].Score = ScoreCharger(superchargersWithETA[i], scoreCtx)
+	}
+	sortSuperchargers(superchargersWithETA, config.SortBy)
+	logf(LogDebug, "process superchargers time: %v", time.Since(processStart))
+
+	result := &SuperchargersOnRouteResult{
+		Route:              route,
+		Superchargers:      superchargersWithETA, // Superchargers with ETA information
+		SearchCircles:      page.circles,
+		SkippedCircles:     skippedCircles,
+		SearchRadiusMeters: searchRadius,
+		NextContinuation:   page.next,
+	}
+	if delay, ok := route.TrafficDelay(); ok {
+		delaySeconds := int(delay.Seconds())
+		result.TrafficDelaySeconds = &delaySeconds
+	}
 
-	return &SuperchargersOnRouteResult{
-		Route:         route,
-		Superchargers: superchargersWithETA, // Superchargers with ETA information
-		SearchCircles: circles,
-	}, nil
+	return result, nil
 }
 
 const (
-	FieldMaskRestaurantTextSearch = "places.id,places.displayName,places.formattedAddress,places.location,places.primaryType,places.primaryTypeDisplayName"
+	// RestaurantPlaceType is the Places API type used to find food near a supercharger
+	RestaurantPlaceType = "restaurant"
+
+	// rating and userRatingCount feed the food rating summaries, top restaurants and sort=food, and
+	// regularOpeningHours whether each restaurant is open on arrival. They bill the search at the Enterprise
+	// rate, but restaurants are cached for RESTAURANT_CACHE_TTL so this is paid rarely.
+	FieldMaskRestaurantTextSearch = "places.id,places.displayName,places.formattedAddress,places.location,places.primaryType,places.primaryTypeDisplayName,places.types,places.rating,places.userRatingCount,places.regularOpeningHours"
 	// this is pro because of the usage of displayName. Without it we get non superchargers returned.
 	// There is no way to force it to contain the exact text.
-	FieldMaskSuperchargerDetails = "id,name,displayName,formattedAddress,location"
+	FieldMaskSuperchargerDetails = "id,name,displayName,formattedAddress,location,types"
+	// location lets circle search results far from the route be discarded before their details are fetched
+	FieldMaskSuperchargerTextSearch = "places.id,places.location"
+	// FieldMaskSuperchargerRating triggers the Enterprise SKU, only request it when reviews are wanted
+	FieldMaskSuperchargerRating = "rating,userRatingCount"
 )
 
+// superchargerFetches coalesces concurrent fetches of the same supercharger
+var superchargerFetches singleflight.Group
+
+// sharedFetchTimeout bounds a coalesced supercharger fetch. It runs detached from the callers' contexts so
+// one caller giving up doesn't fail the others, and this keeps it from running on forever once they all have.
+const sharedFetchTimeout = 30 * time.Second
+
+// CacheTypeSupercharger is the CacheHit type recorded for supercharger lookups
+const CacheTypeSupercharger = "supercharger"
+
+// cacheHits records whether supercharger lookups were served from the database, nil when disabled
+var cacheHits atomic.Pointer[db.CacheHitBuffer]
+
+// SetCacheHitBuffer starts recording supercharger cache hits and misses into the buffer.
+// Passing nil stops recording.
+func SetCacheHitBuffer(buffer *db.CacheHitBuffer) {
+	cacheHits.Store(buffer)
+}
+
+// recordCacheHit queues a supercharger cache lookup if recording is enabled
+func recordCacheHit(placeID string, hit bool) {
+	if buffer := cacheHits.Load(); buffer != nil {
+		buffer.Record(db.CacheHit{ObjectID: placeID, Hit: hit, Type: CacheTypeSupercharger})
+	}
+}
+
+// cachedSupercharger is the shared result of a coalesced supercharger fetch
+type cachedSupercharger struct {
+	supercharger *db.Supercharger
+	restaurants  []db.RestaurantWithDistance
+}
+
 // GetSuperchargerWithCache retrieves place details with database caching
-// First checks the database, then falls back to API if not found
-func GetSuperchargerWithCache(ctx context.Context, broker *db.Service, apiKey, placeID string) (*db.Supercharger, []db.RestaurantWithDistance, error) {
+// First checks the database, then falls back to API if not found.
+// Concurrent calls for the same place ID and settings share a single lookup, API call and DB write,
+// so callers must treat the returned values as read-only. Each caller stops waiting when its own ctx is
+// done, without cancelling the shared lookup for the others. A nil config uses DefaultSearchConfig.
+func GetSuperchargerWithCache(ctx context.Context, broker *db.Service, apiKey, placeID string, config *SearchConfig) (*db.Supercharger, []db.RestaurantWithDistance, error) {
+	if config == nil {
+		config = DefaultSearchConfig()
+	}
+
+	results := superchargerFetches.DoChan(superchargerFetchKey(placeID, config), func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedFetchTimeout)
+		defer cancel()
+		supercharger, restaurants, err := getSuperchargerWithCache(fetchCtx, broker, apiKey, placeID, config)
+		return cachedSupercharger{supercharger: supercharger, restaurants: restaurants}, err
+	})
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case res := <-results:
+		if res.Err != nil {
+			return nil, nil, res.Err
+		}
+		result := res.Val.(cachedSupercharger)
+		return result.supercharger, result.restaurants, nil
+	}
+}
+
+// superchargerFetchKey identifies the fetches GetSuperchargerWithCache can share: those for the same place
+// with every setting that changes the result, including the route state of fetches made planning a route
+func superchargerFetchKey(placeID string, config *SearchConfig) string {
+	key := fmt.Sprintf("%s|%s|%v|%v|%v|%d|%v|%v|%v|%v", placeID, config.Locale, config.RestaurantRadiusMeters,
+		config.FetchRestaurants, config.FetchReviews, config.MaxRestaurantsPerSupercharger, config.minMatchConfidence(),
+		config.SeparateRejectedPlaces, config.CacheTTL.Supercharger, config.CacheTTL.Restaurants)
+	if config.restaurantPool != nil || config.cachedRows != nil {
+		key += fmt.Sprintf("|%p|%p", config.restaurantPool, config.cachedRows)
+	}
+	return key
+}
+
+// loadRejectedPlace returns a placeholder for a place recorded in the rejected place table, or nil if it
+// isn't there. Records older than ttl are removed so the place is looked up again.
+func loadRejectedPlace(broker *db.Service, placeID string, ttl time.Duration) (*db.Supercharger, error) {
+	rejected, err := broker.RejectedPlace.GetByID(placeID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rejected places from database: %w", err)
+	}
+	if expired(rejected.CheckedAt, ttl) {
+		if err := broker.RejectedPlace.Delete(placeID); err != nil {
+			return nil, fmt.Errorf("failed to expire rejected place: %w", err)
+		}
+		return nil, nil
+	}
+	return &db.Supercharger{PlaceID: rejected.PlaceID, LastUpdated: rejected.CheckedAt, IsSupercharger: false}, nil
+}
+
+// loadSupercharger returns the stored supercharger, from the rows preloaded for the route when there are some
+func loadSupercharger(broker *db.Service, placeID string, config *SearchConfig) (*db.Supercharger, error) {
+	if config.cachedRows == nil {
+		return broker.Supercharger.GetByID(placeID)
+	}
+	supercharger, ok := config.cachedRows[placeID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return supercharger, nil
+}
+
+// getSuperchargerWithCache does the work for GetSuperchargerWithCache
+func getSuperchargerWithCache(ctx context.Context, broker *db.Service, apiKey, placeID string, config *SearchConfig) (*db.Supercharger, []db.RestaurantWithDistance, error) {
 	// First try to get from database
-	supercharger, err := broker.Supercharger.GetByID(placeID)
+	supercharger, err := loadSupercharger(broker, placeID, config)
+	var refreshed bool
+	if err == nil && expired(supercharger.LastUpdated, config.CacheTTL.Supercharger) {
+		// look the place up again, serving the cached row if Google can't be reached
+		fresh, refreshErr := refreshSupercharger(ctx, broker, apiKey, supercharger, config)
+		if refreshErr != nil {
+			log.Printf("Warning: failed to refresh expired supercharger %s, serving the cached row: %v", placeID, refreshErr)
+		} else {
+			supercharger, refreshed = fresh, true
+		}
+	}
 	if err == nil {
+		recordCacheHit(placeID, !refreshed)
+		if config.FetchReviews && supercharger.IsSupercharger && reviewsStale(supercharger) {
+			refreshRating(ctx, broker, apiKey, supercharger, config.Locale)
+		}
+		if !supercharger.IsSupercharger || !config.FetchRestaurants {
+			return supercharger, []db.RestaurantWithDistance{}, nil
+		}
+
+		// cached by a request that skipped restaurants, searched in another locale or a smaller radius,
+		// or too long ago, so look them up again rather than mixing results
+		if restaurantsStale(supercharger, config) {
+			restaurants, err := config.restaurantPool.fetch(ctx, apiKey, placeID, Center{Latitude: supercharger.Latitude, Longitude: supercharger.Longitude}, config.Locale, config.RestaurantRadiusMeters)
+			if err != nil {
+				return nil, nil, err
+			}
+			restaurants = capFetchedRestaurants(placeID, restaurants, config)
+			if err := broker.Supercharger.SetRestaurantsForSupercharger(placeID, restaurants, config.Locale.String(), config.RestaurantRadiusMeters); err != nil {
+				// Log the error but don't fail the request since we already have the data
+				fmt.Printf("Warning: failed to cache restaurants for supercharger %s in database: %v\n", placeID, err)
+			}
+			now := time.Now()
+			supercharger.LastRestaurantUpdate = &now
+			supercharger.RestaurantLocale = config.Locale.String()
+			supercharger.RestaurantRadius = config.RestaurantRadiusMeters
+			return supercharger, restaurants, nil
+		}
+
 		restaurants, err := broker.Supercharger.GetRestaurantsForSupercharger(placeID)
-		return supercharger, restaurants, err
+		return supercharger, db.TopRestaurants(restaurants, config.MaxRestaurantsPerSupercharger), err
 	}
 
 	// Check if error is "not found" (expected when place doesn't exist in DB)
@@ -555,29 +1238,51 @@ func GetSuperchargerWithCache(ctx context.Context, broker *db.Service, apiKey, p
 		return nil, nil, fmt.Errorf("failed to query supercharger from database: %w", err)
 	}
 
+	rejected, err := loadRejectedPlace(broker, placeID, config.CacheTTL.Supercharger)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rejected != nil {
+		recordCacheHit(placeID, true)
+		return rejected, []db.RestaurantWithDistance{}, nil
+	}
+
+	recordCacheHit(placeID, false)
 	log.Println("Supercharger not found in DB, fetching from API:", placeID)
 
 	// Not found in database, fetch from API
-	// this field map ensure the essentials tier
-	superchargerDetails, err := GetPlaceDetails(ctx, apiKey, placeID, FieldMaskSuperchargerDetails)
+	// this field map ensure the essentials tier unless the rating is wanted too
+	fieldMask := FieldMaskSuperchargerDetails
+	if config.FetchReviews {
+		fieldMask += "," + FieldMaskSuperchargerRating
+	}
+	superchargerDetails, err := GetPlaceDetails(ctx, apiKey, placeID, fieldMask, config.Locale)
+	recordFetchOutcome(broker, placeID, superchargerDetails, true, err)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// exit early if site not a supercharger
-	if !strings.Contains(strings.ToLower(superchargerDetails.DisplayName.Text), "supercharger") {
-		log.Printf("Warning: Place ID %s does not appear to be a supercharger (name: %s). Recording without restaurants", placeID, superchargerDetails.DisplayName.Text)
-		// Store in database for future use
+	confidence := superchargerConfidence(superchargerDetails)
+	if confidence < config.minMatchConfidence() {
+		log.Printf("Warning: Place ID %s does not appear to be a supercharger (name: %s, confidence: %.2f). Recording without restaurants", placeID, derefDisplayName(superchargerDetails.DisplayName), confidence)
 		supercharger = &db.Supercharger{
-			PlaceID:        superchargerDetails.ID,
-			Name:           derefDisplayName(superchargerDetails.DisplayName),
-			Address:        derefString(superchargerDetails.FormattedAddress),
-			Latitude:       superchargerDetails.Location.Latitude,
-			Longitude:      superchargerDetails.Location.Longitude,
-			IsSupercharger: false,
+			PlaceID:         superchargerDetails.ID,
+			Name:            derefDisplayName(superchargerDetails.DisplayName),
+			Address:         derefString(superchargerDetails.FormattedAddress),
+			Latitude:        superchargerDetails.Location.Latitude,
+			Longitude:       superchargerDetails.Location.Longitude,
+			Types:           superchargerDetails.Types,
+			MatchConfidence: confidence,
+			IsSupercharger:  false,
 		}
 
-		err = broker.Supercharger.Create(supercharger)
+		// Store in database for future use
+		if config.SeparateRejectedPlaces {
+			err = broker.RejectedPlace.Add(placeID)
+		} else {
+			err = broker.Supercharger.Create(supercharger)
+		}
 		if err != nil {
 			// Log the error but don't fail the request since we already have the data
 			fmt.Printf("Warning: failed to cache supercharger %s in database: %v\n", placeID, err)
@@ -585,31 +1290,189 @@ func GetSuperchargerWithCache(ctx context.Context, broker *db.Service, apiKey, p
 		return supercharger, []db.RestaurantWithDistance{}, nil
 	}
 
-	restaurants, err := GetPlacesViaTextSearch(ctx, apiKey, "restaurant", FieldMaskRestaurantTextSearch, Circle{
-		Center: Center{
-			Latitude:  superchargerDetails.Location.Latitude,
-			Longitude: superchargerDetails.Location.Longitude,
-		},
-		Radius: 500, // 500 meter radius
-	})
+	// Store in database for future use
+	supercharger = &db.Supercharger{
+		PlaceID:         superchargerDetails.ID,
+		Name:            derefDisplayName(superchargerDetails.DisplayName),
+		Address:         derefString(superchargerDetails.FormattedAddress),
+		Latitude:        superchargerDetails.Location.Latitude,
+		Longitude:       superchargerDetails.Location.Longitude,
+		Types:           superchargerDetails.Types,
+		MatchConfidence: confidence,
+		IsSupercharger:  true,
+	}
+	if config.FetchReviews {
+		setRating(supercharger, superchargerDetails)
+	}
+
+	// skip the restaurant search entirely, it can be filled in by a later request that wants it
+	if !config.FetchRestaurants {
+		if err := broker.Supercharger.Create(supercharger); err != nil {
+			// Log the error but don't fail the request since we already have the data
+			fmt.Printf("Warning: failed to cache supercharger %s in database: %v\n", placeID, err)
+		}
+		return supercharger, []db.RestaurantWithDistance{}, nil
+	}
+
+	dbRestaurants, err := config.restaurantPool.fetch(ctx, apiKey, placeID, Center{
+		Latitude:  superchargerDetails.Location.Latitude,
+		Longitude: superchargerDetails.Location.Longitude,
+	}, config.Locale, config.RestaurantRadiusMeters)
 	if err != nil {
 		return nil, nil, err
 	}
+	dbRestaurants = capFetchedRestaurants(placeID, dbRestaurants, config)
+
+	now := time.Now()
+	supercharger.LastRestaurantUpdate = &now
+	supercharger.RestaurantLocale = config.Locale.String()
+	supercharger.RestaurantRadius = config.RestaurantRadiusMeters
+	err = broker.Supercharger.AddSuperchargerWithRestaurants(supercharger, dbRestaurants)
+	if err != nil {
+		// Log the error but don't fail the request since we already have the data
+		fmt.Printf("Warning: failed to cache supercharger %s in database: %v\n", placeID, err)
+	}
+
+	return supercharger, dbRestaurants, nil
+}
+
+// refreshSupercharger looks an expired supercharger up again and updates its row in place. What the
+// details call doesn't return is kept: the timezone, the rating unless it was fetched too, and the
+// restaurants unless the charger has moved or is no longer a supercharger.
+func refreshSupercharger(ctx context.Context, broker *db.Service, apiKey string, stale *db.Supercharger, config *SearchConfig) (*db.Supercharger, error) {
+	fieldMask := FieldMaskSuperchargerDetails
+	if config.FetchReviews {
+		fieldMask += "," + FieldMaskSuperchargerRating
+	}
+	details, err := GetPlaceDetails(ctx, apiKey, stale.PlaceID, fieldMask, config.Locale)
+	recordFetchOutcome(broker, stale.PlaceID, details, true, err)
+	if err != nil {
+		return nil, err
+	}
+
+	// copy so rows preloaded for the route aren't changed under other lookups
+	supercharger := *stale
+	supercharger.Name = derefDisplayName(details.DisplayName)
+	supercharger.Address = derefString(details.FormattedAddress)
+	supercharger.Latitude = details.Location.Latitude
+	supercharger.Longitude = details.Location.Longitude
+	supercharger.Types = details.Types
+	supercharger.MatchConfidence = superchargerConfidence(details)
+	supercharger.IsSupercharger = supercharger.MatchConfidence >= config.minMatchConfidence()
+	supercharger.LastUpdated = time.Now()
+	if config.FetchReviews {
+		setRating(&supercharger, details)
+	}
+
+	moved := haversineDistance(Center{Latitude: stale.Latitude, Longitude: stale.Longitude}, Center{Latitude: supercharger.Latitude, Longitude: supercharger.Longitude}) > DefaultMovedThresholdMeters
+	dropRestaurants := moved || !supercharger.IsSupercharger
+	if dropRestaurants {
+		// restaurants found around the old row no longer apply
+		supercharger.LastRestaurantUpdate = nil
+		supercharger.RestaurantLocale = ""
+		supercharger.RestaurantRadius = 0
+	}
+
+	if !supercharger.IsSupercharger {
+		log.Printf("Warning: Place ID %s no longer appears to be a supercharger (name: %s, confidence: %.2f)", stale.PlaceID, supercharger.Name, supercharger.MatchConfidence)
+		if config.SeparateRejectedPlaces {
+			// rejected places are recorded by ID only
+			if err := broker.InvalidateSupercharger(stale.PlaceID); err != nil {
+				fmt.Printf("Warning: failed to remove rejected supercharger %s from database: %v\n", stale.PlaceID, err)
+			}
+			if err := broker.RejectedPlace.Add(stale.PlaceID); err != nil {
+				fmt.Printf("Warning: failed to record rejected place %s in database: %v\n", stale.PlaceID, err)
+			}
+			return &supercharger, nil
+		}
+	}
+
+	if err := broker.Supercharger.Refresh(&supercharger, dropRestaurants); err != nil {
+		// Log the error but don't fail the request since we already have the data
+		fmt.Printf("Warning: failed to update supercharger %s in database: %v\n", stale.PlaceID, err)
+	}
+	return &supercharger, nil
+}
+
+// capFetchedRestaurants keeps the best MaxRestaurantsPerSupercharger of a supercharger's freshly fetched
+// restaurants, warning when some are dropped since it means the cap is limiting a dense location
+func capFetchedRestaurants(placeID string, restaurants []db.RestaurantWithDistance, config *SearchConfig) []db.RestaurantWithDistance {
+	capped := db.TopRestaurants(restaurants, config.MaxRestaurantsPerSupercharger)
+	if len(capped) < len(restaurants) {
+		log.Printf("Warning: supercharger %s has %d restaurants, keeping the best %d", placeID, len(restaurants), len(capped))
+	}
+	return capped
+}
+
+// ReviewRefreshInterval is how long a cached supercharger rating is used before it is fetched again
+const ReviewRefreshInterval = 30 * 24 * time.Hour
+
+// reviewsStale reports whether a cached supercharger's rating is missing or too old to use
+func reviewsStale(supercharger *db.Supercharger) bool {
+	return supercharger.LastReviewUpdate == nil || time.Since(*supercharger.LastReviewUpdate) > ReviewRefreshInterval
+}
+
+// setRating copies the rating from place details onto the supercharger
+func setRating(supercharger *db.Supercharger, details *PlaceDetails) {
+	if details.Rating != nil {
+		supercharger.Rating = *details.Rating
+	}
+	if details.UserRatingCount != nil {
+		supercharger.ReviewCount = *details.UserRatingCount
+	}
+	now := time.Now()
+	supercharger.LastReviewUpdate = &now
+}
+
+// refreshRating fetches and caches the rating of a supercharger that was cached without one.
+// Failures are logged rather than returned since the rest of the supercharger is still usable.
+func refreshRating(ctx context.Context, broker *db.Service, apiKey string, supercharger *db.Supercharger, locale Locale) {
+	details, err := GetPlaceDetails(ctx, apiKey, supercharger.PlaceID, FieldMaskSuperchargerRating, locale)
+	if err != nil {
+		recordFetchOutcome(broker, supercharger.PlaceID, nil, false, err)
+		log.Printf("Warning: failed to fetch rating for supercharger %s: %v", supercharger.PlaceID, err)
+		return
+	}
+	setRating(supercharger, details)
+	if err := broker.Supercharger.UpdateRating(supercharger.PlaceID, supercharger.Rating, supercharger.ReviewCount); err != nil {
+		// Log the error but don't fail the request since we already have the data
+		log.Printf("Warning: failed to cache rating for supercharger %s: %v", supercharger.PlaceID, err)
+	}
+}
+
+// restaurantsStale reports whether a cached supercharger's restaurants can't be used for this search
+func restaurantsStale(supercharger *db.Supercharger, config *SearchConfig) bool {
+	if supercharger.LastRestaurantUpdate == nil || expired(*supercharger.LastRestaurantUpdate, config.CacheTTL.Restaurants) {
+		return true
+	}
+	// rows from before the radius was stored were searched with the default
+	radius := supercharger.RestaurantRadius
+	if radius == 0 {
+		radius = DefaultRestaurantRadiusMeters
+	}
+	return supercharger.RestaurantLocale != config.Locale.String() || radius != config.RestaurantRadiusMeters
+}
+
+// fetchRestaurantsNear searches for restaurants within radius meters of a supercharger
+func fetchRestaurantsNear(ctx context.Context, apiKey, placeID string, location Center, locale Locale, radius float64) ([]db.RestaurantWithDistance, error) {
+	// searching by type is cheaper and more precise than a "restaurant" text search
+	logf(LogDebug, "Fetching restaurants near %s via places.searchNearby", placeID)
+	restaurants, err := GetPlacesNearby(ctx, apiKey, location, radius, []string{RestaurantPlaceType}, FieldMaskRestaurantTextSearch, locale)
+	if err != nil {
+		return nil, err
+	}
 
 	var dbRestaurants []db.RestaurantWithDistance
 	for _, restaurant := range restaurants {
-		// check if restaurant is within 500m of supercharger
+		// check if restaurant is within the radius of the supercharger
 		if restaurant.Location == nil {
 			continue
 		}
-		dist := haversineDistance(Center{
-			Latitude:  superchargerDetails.Location.Latitude,
-			Longitude: superchargerDetails.Location.Longitude,
-		}, Center{
+		dist := haversineDistance(location, Center{
 			Latitude:  restaurant.Location.Latitude,
 			Longitude: restaurant.Location.Longitude,
 		})
-		if dist > 500 {
+		if dist > radius {
 			continue
 		}
 		dbRestaurant := db.Restaurant{
@@ -620,6 +1483,14 @@ func GetSuperchargerWithCache(ctx context.Context, broker *db.Service, apiKey, p
 			Longitude:          restaurant.Location.Longitude,
 			PrimaryType:        derefString(restaurant.PrimaryType),
 			PrimaryTypeDisplay: derefDisplayName(restaurant.PrimaryTypeDisplayName),
+			Types:              restaurant.Types,
+			OpeningHours:       restaurant.RegularOpeningHours,
+		}
+		if restaurant.Rating != nil {
+			dbRestaurant.Rating = *restaurant.Rating
+		}
+		if restaurant.UserRatingCount != nil {
+			dbRestaurant.UserRatingsTotal = *restaurant.UserRatingCount
 		}
 		dbRestaurants = append(dbRestaurants, db.RestaurantWithDistance{
 			Restaurant: dbRestaurant,
@@ -627,23 +1498,7 @@ func GetSuperchargerWithCache(ctx context.Context, broker *db.Service, apiKey, p
 		})
 	}
 
-	// Store in database for future use
-	supercharger = &db.Supercharger{
-		PlaceID:        superchargerDetails.ID,
-		Name:           derefDisplayName(superchargerDetails.DisplayName),
-		Address:        derefString(superchargerDetails.FormattedAddress),
-		Latitude:       superchargerDetails.Location.Latitude,
-		Longitude:      superchargerDetails.Location.Longitude,
-		IsSupercharger: true,
-	}
-
-	err = broker.Supercharger.AddSuperchargerWithRestaurants(supercharger, dbRestaurants)
-	if err != nil {
-		// Log the error but don't fail the request since we already have the data
-		fmt.Printf("Warning: failed to cache supercharger %s in database: %v\n", placeID, err)
-	}
-
-	return supercharger, dbRestaurants, nil
+	return dbRestaurants, nil
 }
 
 func derefString(s *string) string {
@@ -3,10 +3,18 @@ package maps
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -14,38 +22,58 @@ import (
 	"gorm.io/gorm/logger"
 )
 
-func TestGetSuperchargersOnRoute(t *testing.T) {
-	apiKey := os.Getenv("MAPS_API_KEY")
-	if apiKey == "" {
-		t.Skip("MAPS_API_KEY not set")
+// newTestDB opens an isolated in-memory database, closed when the test ends
+func newTestDB(t *testing.T) *db.Service {
+	t.Helper()
+	service, err := db.NewInMemoryService()
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
 	}
+	t.Cleanup(func() { service.Close() })
+	return service
+}
 
-	// Create database file in test-databases directory
-	timestamp := time.Now().Format("20060102_150405")
-	dbFile := filepath.Join("test-databases", fmt.Sprintf("TestGetSuperchargersOnRoute_%s.db", timestamp))
-
-	// Ensure the directory exists
-	os.MkdirAll("test-databases", 0755)
-
-	// Initialize file-based database
+// newGlobalTestDB initializes the global database in a temporary directory, for tests that edit rows
+// through db.DB directly. It is closed and removed when the test ends.
+func newGlobalTestDB(t *testing.T) *db.Service {
+	t.Helper()
 	err := db.Initialize(&db.Config{
-		DatabasePath: dbFile,
-		LogLevel:     logger.Error,
+		DatabasePath: filepath.Join(t.TempDir(), "test.db"),
+		LogLevel:     logger.Silent,
 	})
 	if err != nil {
 		t.Fatalf("Failed to initialize database: %v", err)
 	}
+	t.Cleanup(func() { db.Close() })
+	return db.GetDefaultService()
+}
 
-	t.Logf("Database created at: %s", dbFile)
+// stubPlacesAPI serves place details and nearby searches from handler for the rest of the test
+func stubPlacesAPI(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	originalDetails, originalNearby := placeDetailsEndpoint, placesNearbyEndpoint
+	placeDetailsEndpoint, placesNearbyEndpoint = server.URL, server.URL
+	t.Cleanup(func() {
+		placeDetailsEndpoint, placesNearbyEndpoint = originalDetails, originalNearby
+		server.Close()
+	})
+}
+
+func TestGetSuperchargersOnRoute(t *testing.T) {
+	apiKey := os.Getenv("MAPS_API_KEY")
+	if apiKey == "" {
+		t.Skip("MAPS_API_KEY not set")
+	}
 
-	broker := db.GetDefaultService()
+	broker := newTestDB(t)
 
 	start := "mountain view, california"
 	end := "morgan hill, california"
 
 	t.Logf("Finding superchargers on route from %s to %s", start, end)
 
-	result, err := GetSuperchargersOnRoute(context.Background(), broker, apiKey, start, end)
+	result, err := GetSuperchargersOnRoute(context.Background(), broker, apiKey, start, end, nil)
 	if err != nil {
 		t.Fatalf("GetSuperchargersOnRoute failed: %v", err)
 	}
@@ -67,7 +95,7 @@ func TestGetSuperchargersOnRoute(t *testing.T) {
 	t.Logf("Successfully generated supercharger_route_visualization.html")
 
 	t.Logf("running again to check caching...")
-	resultCached, err := GetSuperchargersOnRoute(context.Background(), broker, apiKey, start, end)
+	resultCached, err := GetSuperchargersOnRoute(context.Background(), broker, apiKey, start, end, nil)
 	if err != nil {
 		t.Fatalf("GetSuperchargersOnRoute failed: %v", err)
 	}
@@ -96,9 +124,763 @@ func TestGetSuperchargersOnRoute(t *testing.T) {
 	if len(superchargers) == 0 {
 		t.Error("Expected to find at least one supercharger on the route")
 	}
+}
+
+func TestSortSuperchargers(t *testing.T) {
+	now := time.Now()
+	superchargers := []SuperchargerWithETA{
+		{Supercharger: &db.Supercharger{PlaceID: "c"}, DistanceAlongRoute: 3000, arrival: now.Add(1 * time.Minute)},
+		{Supercharger: &db.Supercharger{PlaceID: "b"}, DistanceAlongRoute: 1000, arrival: now.Add(10 * time.Minute)},
+		{Supercharger: &db.Supercharger{PlaceID: "a"}, DistanceAlongRoute: 1000, arrival: now.Add(5 * time.Minute)},
+	}
+
+	sortSuperchargers(superchargers, SortByDistanceAlongRoute)
+	for i, want := range []string{"a", "b", "c"} {
+		if got := superchargers[i].Supercharger.PlaceID; got != want {
+			t.Errorf("distance order[%d]: expected %s, got %s", i, want, got)
+		}
+	}
+
+	sortSuperchargers(superchargers, SortByArrivalTime)
+	for i, want := range []string{"c", "a", "b"} {
+		if got := superchargers[i].Supercharger.PlaceID; got != want {
+			t.Errorf("eta order[%d]: expected %s, got %s", i, want, got)
+		}
+	}
+
+	good, great := 4.2, 4.8
+	superchargers[0].AvgFoodRating = &good  // c
+	superchargers[2].AvgFoodRating = &great // b
+	sortSuperchargers(superchargers, SortByFoodRating)
+	for i, want := range []string{"b", "c", "a"} {
+		if got := superchargers[i].Supercharger.PlaceID; got != want {
+			t.Errorf("food order[%d]: expected %s, got %s", i, want, got)
+		}
+	}
+}
+
+func TestGetSuperchargerWithCacheCoalescesConcurrentFetches(t *testing.T) {
+	var detailCalls int32
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&detailCalls, 1)
+			// hold the request open so every caller arrives while it's in flight
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte(`{"id":"ChIJcoalesce","displayName":{"text":"Tesla Supercharger"},"location":{"latitude":37.4,"longitude":-122.1}}`))
+			return
+		}
+		w.Write([]byte(`{"places":[]}`))
+	})
+
+	broker := newTestDB(t)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJcoalesce", nil)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetSuperchargerWithCache failed: %v", err)
+		}
+	}
+	if calls := atomic.LoadInt32(&detailCalls); calls != 1 {
+		t.Errorf("Expected exactly 1 details API call, got %d", calls)
+	}
+}
+
+func TestGetSuperchargerWithCacheCallerCancellation(t *testing.T) {
+	var detailCalls int32
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&detailCalls, 1)
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte(`{"id":"ChIJsharedFetch","displayName":{"text":"Tesla Supercharger"},"location":{"latitude":37.4,"longitude":-122.1}}`))
+			return
+		}
+		w.Write([]byte(`{"places":[]}`))
+	})
+
+	broker := newTestDB(t)
+
+	// the first caller gives up while the lookup is in flight
+	leaderCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, _, err := GetSuperchargerWithCache(leaderCtx, broker, "key", "ChIJsharedFetch", nil)
+		leaderErr <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	supercharger, _, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJsharedFetch", nil)
+	if err != nil || supercharger.PlaceID != "ChIJsharedFetch" {
+		t.Fatalf("Expected the waiting caller to get the supercharger, got %v: %v", supercharger, err)
+	}
+	if err := <-leaderErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the first caller to stop at its own deadline, got %v", err)
+	}
+	if calls := atomic.LoadInt32(&detailCalls); calls != 1 {
+		t.Errorf("Expected the callers to share 1 details call, got %d", calls)
+	}
+
+	// settings that change the result aren't shared
+	strict, loose := DefaultSearchConfig(), DefaultSearchConfig()
+	strict.MinMatchConfidence = 0.9
+	loose.MaxRestaurantsPerSupercharger = 1
+	if superchargerFetchKey("ChIJsharedFetch", strict) == superchargerFetchKey("ChIJsharedFetch", loose) {
+		t.Error("Expected fetches with different settings to have different keys")
+	}
+	routeA, routeB := *loose, *loose
+	routeA.restaurantPool, routeB.restaurantPool = newRestaurantPool(), newRestaurantPool()
+	if superchargerFetchKey("ChIJsharedFetch", &routeA) == superchargerFetchKey("ChIJsharedFetch", &routeB) {
+		t.Error("Expected fetches for different routes not to share route state")
+	}
+}
+
+func TestGetSuperchargerWithCacheSkipsRestaurants(t *testing.T) {
+	var nearbyCalls int32
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"id":"ChIJskipRestaurants","displayName":{"text":"Tesla Supercharger"},"location":{"latitude":37.4,"longitude":-122.1}}`))
+			return
+		}
+		atomic.AddInt32(&nearbyCalls, 1)
+		w.Write([]byte(`{"places":[{"id":"ChIJfood","displayName":{"text":"Diner"},"location":{"latitude":37.4001,"longitude":-122.1}}]}`))
+	})
+
+	broker := newTestDB(t)
+
+	config := DefaultSearchConfig()
+	config.FetchRestaurants = false
+	_, restaurants, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJskipRestaurants", config)
+	if err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+	if len(restaurants) != 0 || atomic.LoadInt32(&nearbyCalls) != 0 {
+		t.Fatalf("Expected no restaurant lookup, got %d restaurants and %d calls", len(restaurants), nearbyCalls)
+	}
 
-	// Close the database after all operations are complete
-	defer db.Close()
+	// a later request that wants restaurants fills them in for the cached charger
+	_, restaurants, err = GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJskipRestaurants", nil)
+	if err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+	if len(restaurants) != 1 || atomic.LoadInt32(&nearbyCalls) != 1 {
+		t.Fatalf("Expected 1 restaurant from 1 lookup, got %d restaurants and %d calls", len(restaurants), nearbyCalls)
+	}
+
+	supercharger, err := broker.Supercharger.GetByID("ChIJskipRestaurants")
+	if err != nil {
+		t.Fatalf("Failed to load supercharger: %v", err)
+	}
+	if supercharger.LastRestaurantUpdate == nil {
+		t.Error("Expected last restaurant update to be recorded")
+	}
+}
+
+func TestGetSuperchargerWithCacheCapsRestaurants(t *testing.T) {
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"id":"ChIJcapRestaurants","displayName":{"text":"Tesla Supercharger"},"location":{"latitude":37.4,"longitude":-122.1}}`))
+			return
+		}
+		w.Write([]byte(`{"places":[
+			{"id":"ChIJnear","displayName":{"text":"Near"},"location":{"latitude":37.4001,"longitude":-122.1}},
+			{"id":"ChIJmiddle","displayName":{"text":"Middle"},"location":{"latitude":37.4010,"longitude":-122.1}},
+			{"id":"ChIJfar","displayName":{"text":"Far"},"location":{"latitude":37.4030,"longitude":-122.1}}
+		]}`))
+	})
+
+	broker := newTestDB(t)
+
+	config := DefaultSearchConfig()
+	config.MaxRestaurantsPerSupercharger = 2
+	_, restaurants, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJcapRestaurants", config)
+	if err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+	if len(restaurants) != 2 || restaurants[0].PlaceID != "ChIJnear" || restaurants[1].PlaceID != "ChIJmiddle" {
+		t.Fatalf("Expected the two closest restaurants, got %v", restaurants)
+	}
+	stored, err := broker.Supercharger.GetRestaurantsForSupercharger("ChIJcapRestaurants")
+	if err != nil || len(stored) != 2 {
+		t.Fatalf("Expected 2 restaurants stored, got %d: %v", len(stored), err)
+	}
+
+	// a lower cap also applies to restaurants already cached
+	config.MaxRestaurantsPerSupercharger = 1
+	_, restaurants, err = GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJcapRestaurants", config)
+	if err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+	if len(restaurants) != 1 || restaurants[0].PlaceID != "ChIJnear" {
+		t.Errorf("Expected only the closest cached restaurant, got %v", restaurants)
+	}
+}
+
+func TestGetSuperchargerWithCacheFetchesRestaurantRatingsAndHours(t *testing.T) {
+	var nearbyFieldMask string
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"id":"ChIJratedFood","displayName":{"text":"Tesla Supercharger"},"location":{"latitude":37.4,"longitude":-122.1}}`))
+			return
+		}
+		nearbyFieldMask = r.Header.Get("X-Goog-FieldMask")
+		w.Write([]byte(`{"places":[
+			{"id":"ChIJrated","displayName":{"text":"Rated"},"location":{"latitude":37.4001,"longitude":-122.1},"rating":4.6,"userRatingCount":312,
+			 "regularOpeningHours":{"periods":[{"open":{"day":1,"hour":7,"minute":0},"close":{"day":1,"hour":22,"minute":0}}]}},
+			{"id":"ChIJunrated","displayName":{"text":"Unrated"},"location":{"latitude":37.4002,"longitude":-122.1}}
+		]}`))
+	})
+
+	broker := newTestDB(t)
+
+	_, restaurants, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJratedFood", nil)
+	if err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+	for _, field := range []string{"places.rating", "places.userRatingCount", "places.regularOpeningHours"} {
+		if !strings.Contains(nearbyFieldMask, field) {
+			t.Errorf("Expected the nearby search to ask for %s, got field mask %q", field, nearbyFieldMask)
+		}
+	}
+	if len(restaurants) != 2 || restaurants[0].Rating != 4.6 || restaurants[0].UserRatingsTotal != 312 || restaurants[1].Rating != 0 {
+		t.Fatalf("Expected the fetched ratings on the restaurants, got %+v", restaurants)
+	}
+
+	stored, err := broker.Supercharger.GetRestaurantsForSupercharger("ChIJratedFood")
+	if err != nil || len(stored) != 2 || stored[0].Rating != 4.6 || stored[0].UserRatingsTotal != 312 {
+		t.Fatalf("Expected the ratings to be stored, got %+v: %v", stored, err)
+	}
+	if hours := stored[0].OpeningHours; hours == nil || len(hours.Periods) != 1 || hours.Periods[0].Close.Hour != 22 || stored[1].OpeningHours != nil {
+		t.Errorf("Expected the opening hours to be stored, got %+v and %+v", stored[0].OpeningHours, stored[1].OpeningHours)
+	}
+}
+
+func TestGetTopRestaurantsAfterFetch(t *testing.T) {
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"id":"ChIJtopFood","displayName":{"text":"Tesla Supercharger"},"types":["electric_vehicle_charging_station"],"location":{"latitude":37.4,"longitude":-122.1}}`))
+			return
+		}
+		w.Write([]byte(`{"places":[
+			{"id":"ChIJclosest","displayName":{"text":"Closest"},"location":{"latitude":37.4001,"longitude":-122.1}},
+			{"id":"ChIJgood","displayName":{"text":"Good"},"location":{"latitude":37.40015,"longitude":-122.1},"rating":4.8,"userRatingCount":950},
+			{"id":"ChIJpoor","displayName":{"text":"Poor"},"location":{"latitude":37.4003,"longitude":-122.1},"rating":2.1,"userRatingCount":40}
+		]}`))
+	})
+
+	broker := newTestDB(t)
+	if _, _, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJtopFood", nil); err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+
+	// the well rated restaurant a little further away outranks the unrated closest one
+	top, err := broker.Supercharger.GetTopRestaurants("ChIJtopFood", 2, 0)
+	if err != nil {
+		t.Fatalf("GetTopRestaurants failed: %v", err)
+	}
+	if len(top) != 2 || top[0].PlaceID != "ChIJgood" || top[1].PlaceID != "ChIJclosest" {
+		t.Errorf("Expected the rated restaurant first, got %+v", top)
+	}
+
+	rated, err := broker.Supercharger.GetTopRestaurants("ChIJtopFood", 0, 4)
+	if err != nil {
+		t.Fatalf("GetTopRestaurants failed: %v", err)
+	}
+	if len(rated) != 1 || rated[0].PlaceID != "ChIJgood" {
+		t.Errorf("Expected only the restaurant rated 4 or more, got %+v", rated)
+	}
+}
+
+func TestGetSuperchargerWithCacheFetchesReviews(t *testing.T) {
+	var fieldMasks []string
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fieldMasks = append(fieldMasks, r.Header.Get("X-Goog-FieldMask"))
+		w.Write([]byte(`{"id":"ChIJreviewCharger","displayName":{"text":"Tesla Supercharger"},"location":{"latitude":37.4,"longitude":-122.1},"rating":4.2,"userRatingCount":130}`))
+	})
+
+	broker := newTestDB(t)
+
+	config := DefaultSearchConfig()
+	config.FetchRestaurants = false
+	supercharger, _, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJreviewCharger", config)
+	if err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+	if supercharger.Rating != 0 || strings.Contains(fieldMasks[0], "rating") {
+		t.Errorf("Expected no rating without FetchReviews, got %v with mask %q", supercharger.Rating, fieldMasks[0])
+	}
+
+	// the cached charger only has its rating fetched
+	config.FetchReviews = true
+	supercharger, _, err = GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJreviewCharger", config)
+	if err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+	if len(fieldMasks) != 2 || fieldMasks[1] != FieldMaskSuperchargerRating {
+		t.Fatalf("Expected a rating only fetch, got masks %v", fieldMasks)
+	}
+	if supercharger.Rating != 4.2 || supercharger.ReviewCount != 130 {
+		t.Errorf("Expected 4.2 from 130 reviews, got %v from %d", supercharger.Rating, supercharger.ReviewCount)
+	}
+
+	// and then reused from the cache
+	supercharger, _, err = GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJreviewCharger", config)
+	if err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+	if len(fieldMasks) != 2 || supercharger.ReviewCount != 130 {
+		t.Errorf("Expected the cached rating to be reused, got %d calls and %d reviews", len(fieldMasks), supercharger.ReviewCount)
+	}
+}
+
+func TestGetSuperchargerWithCacheRefetchesForNewLocale(t *testing.T) {
+	var nearbyRadii []float64
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"id":"ChIJlocaleCharger","displayName":{"text":"Tesla Supercharger"},"location":{"latitude":37.4,"longitude":-122.1}}`))
+			return
+		}
+		var body nearbyRequestBody
+		json.NewDecoder(r.Body).Decode(&body)
+		nearbyRadii = append(nearbyRadii, body.LocationRestriction.Circle.Radius)
+		w.Write([]byte(`{"places":[{"id":"ChIJfood","displayName":{"text":"Diner"},"location":{"latitude":37.4001,"longitude":-122.1}}]}`))
+	})
+
+	broker := newTestDB(t)
+
+	get := func(config *SearchConfig) {
+		t.Helper()
+		_, restaurants, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJlocaleCharger", config)
+		if err != nil {
+			t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+		}
+		if len(restaurants) != 1 {
+			t.Fatalf("Expected 1 restaurant, got %d", len(restaurants))
+		}
+	}
+
+	get(nil)
+	get(nil) // served from the cache
+	german := DefaultSearchConfig()
+	german.Locale = Locale{LanguageCode: "de", RegionCode: "DE"}
+	get(german)
+	wider := DefaultSearchConfig()
+	wider.Locale = german.Locale
+	wider.RestaurantRadiusMeters = 1000
+	get(wider)
+
+	if len(nearbyRadii) != 3 || nearbyRadii[0] != DefaultRestaurantRadiusMeters || nearbyRadii[2] != 1000 {
+		t.Errorf("Expected searches for default, German and wider configs, got radii %v", nearbyRadii)
+	}
+
+	supercharger, err := broker.Supercharger.GetByID("ChIJlocaleCharger")
+	if err != nil {
+		t.Fatalf("Failed to load supercharger: %v", err)
+	}
+	if supercharger.RestaurantLocale != "de-DE" || supercharger.RestaurantRadius != 1000 {
+		t.Errorf("Expected de-DE within 1000m recorded, got %q within %v", supercharger.RestaurantLocale, supercharger.RestaurantRadius)
+	}
+	if count, _ := broker.Supercharger.CountRestaurantMappings(); count != 1 {
+		t.Errorf("Expected refetches to replace mappings, got %d", count)
+	}
+}
+
+func TestGetSuperchargerWithCacheExpiry(t *testing.T) {
+	var detailCalls, nearbyCalls int
+	var detailsDown bool
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			detailCalls++
+			if detailsDown {
+				http.Error(w, `{"error":{"code":400,"status":"INVALID_ARGUMENT"}}`, http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"id":"ChIJexpiryCharger","displayName":{"text":"Tesla Supercharger"},"location":{"latitude":37.4,"longitude":-122.1}}`))
+			return
+		}
+		nearbyCalls++
+		w.Write([]byte(`{"places":[{"id":"ChIJfood","displayName":{"text":"Diner"},"location":{"latitude":37.4001,"longitude":-122.1}}]}`))
+	})
+
+	broker := newGlobalTestDB(t)
+	config := DefaultSearchConfig()
+	config.CacheTTL = CacheTTL{Supercharger: 90 * 24 * time.Hour, Restaurants: 7 * 24 * time.Hour}
+
+	get := func() {
+		t.Helper()
+		if _, _, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJexpiryCharger", config); err != nil {
+			t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+		}
+	}
+	age := func(column string, by time.Duration) {
+		t.Helper()
+		if err := db.DB.Exec("UPDATE superchargers SET "+column+" = ?", time.Now().Add(-by)).Error; err != nil {
+			t.Fatalf("Failed to age %s: %v", column, err)
+		}
+	}
+
+	get()
+	get() // served from the cache
+	if detailCalls != 1 || nearbyCalls != 1 {
+		t.Fatalf("Expected one details and one nearby call, got %d and %d", detailCalls, nearbyCalls)
+	}
+
+	// old restaurants are searched again without re-confirming the charger
+	age("last_restaurant_update", 8*24*time.Hour)
+	get()
+	if detailCalls != 1 || nearbyCalls != 2 {
+		t.Errorf("Expected only restaurants to be refetched, got %d details and %d nearby calls", detailCalls, nearbyCalls)
+	}
+
+	// an old charger is looked up again, keeping restaurants that are still fresh and columns the
+	// details call doesn't return
+	if err := broker.Supercharger.UpdateTimeZone("ChIJexpiryCharger", "America/Los_Angeles"); err != nil {
+		t.Fatalf("Failed to set timezone: %v", err)
+	}
+	if err := broker.Supercharger.UpdateRating("ChIJexpiryCharger", 4.5, 120); err != nil {
+		t.Fatalf("Failed to set rating: %v", err)
+	}
+	age("last_updated", 91*24*time.Hour)
+	get()
+	if detailCalls != 2 || nearbyCalls != 2 {
+		t.Errorf("Expected only the charger to be refetched, got %d details and %d nearby calls", detailCalls, nearbyCalls)
+	}
+	if count, _ := broker.Supercharger.CountRestaurantMappings(); count != 1 {
+		t.Errorf("Expected the kept restaurants to stay mapped, got %d", count)
+	}
+	stored, err := broker.Supercharger.GetByID("ChIJexpiryCharger")
+	if err != nil {
+		t.Fatalf("Failed to get refreshed supercharger: %v", err)
+	}
+	if stored.TimeZone != "America/Los_Angeles" || stored.Rating != 4.5 || stored.ReviewCount != 120 || stored.LastReviewUpdate == nil {
+		t.Errorf("Expected the timezone and rating kept, got %q, %v and %d", stored.TimeZone, stored.Rating, stored.ReviewCount)
+	}
+	if time.Since(stored.LastUpdated) > time.Minute {
+		t.Errorf("Expected the refresh to be recorded, last updated %v", stored.LastUpdated)
+	}
+	get()
+	if detailCalls != 2 || nearbyCalls != 2 {
+		t.Errorf("Expected the refreshed charger to be cached with its restaurants, got %d details and %d nearby calls", detailCalls, nearbyCalls)
+	}
+
+	// when both are old, everything is looked up again
+	age("last_updated", 91*24*time.Hour)
+	age("last_restaurant_update", 8*24*time.Hour)
+	get()
+	if detailCalls != 3 || nearbyCalls != 3 {
+		t.Errorf("Expected the charger and restaurants to be refetched, got %d details and %d nearby calls", detailCalls, nearbyCalls)
+	}
+	if count, _ := broker.Supercharger.CountRestaurantMappings(); count != 1 {
+		t.Errorf("Expected the refetch to replace mappings, got %d", count)
+	}
+
+	// a failed refresh serves the expired row rather than dropping it
+	detailsDown = true
+	age("last_updated", 91*24*time.Hour)
+	sc, restaurants, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJexpiryCharger", config)
+	if err != nil {
+		t.Fatalf("Expected the cached row when the refresh fails, got %v", err)
+	}
+	if sc.PlaceID != "ChIJexpiryCharger" || len(restaurants) != 1 {
+		t.Errorf("Expected the cached charger and its restaurant, got %v and %d restaurants", sc.PlaceID, len(restaurants))
+	}
+	if count, _ := broker.Supercharger.CountRestaurantMappings(); count != 1 {
+		t.Errorf("Expected the failed refresh to keep the mappings, got %d", count)
+	}
+}
+
+func TestGetSuperchargerWithCacheSeparatesRejectedPlaces(t *testing.T) {
+	var detailCalls int
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		detailCalls++
+		w.Write([]byte(`{"id":"ChIJgasStation","displayName":{"text":"Gas Station"},"location":{"latitude":37.4,"longitude":-122.1}}`))
+	})
+
+	broker := newGlobalTestDB(t)
+	config := DefaultSearchConfig()
+	config.SeparateRejectedPlaces = true
+	config.CacheTTL = CacheTTL{Supercharger: 90 * 24 * time.Hour}
+
+	get := func() {
+		t.Helper()
+		sc, _, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJgasStation", config)
+		if err != nil {
+			t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+		}
+		if sc.IsSupercharger {
+			t.Error("Expected the gas station not to be a supercharger")
+		}
+	}
+
+	get()
+	get() // served from the rejected place table
+	if detailCalls != 1 {
+		t.Fatalf("Expected one details call, got %d", detailCalls)
+	}
+	if _, err := broker.Supercharger.GetByID("ChIJgasStation"); err == nil {
+		t.Error("Expected no supercharger row for the rejected place")
+	}
+
+	// an old rejection is checked again
+	if err := db.DB.Exec("UPDATE rejected_place_ids SET checked_at = ?", time.Now().Add(-91*24*time.Hour)).Error; err != nil {
+		t.Fatalf("Failed to age rejected place: %v", err)
+	}
+	get()
+	if detailCalls != 2 {
+		t.Errorf("Expected the rejected place to be looked up again, got %d details calls", detailCalls)
+	}
+}
+
+func TestGetSuperchargerWithCacheMinMatchConfidence(t *testing.T) {
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		// named like a supercharger but not tagged as a charging station
+		w.Write([]byte(`{"id":"ChIJlooseMatch","displayName":{"text":"Tesla Supercharger Car Wash"},"location":{"latitude":37.4,"longitude":-122.1}}`))
+	})
+
+	broker := newTestDB(t)
+	config := DefaultSearchConfig()
+	config.FetchRestaurants = false
+	config.MinMatchConfidence = 0.9
+
+	sc, _, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJlooseMatch", config)
+	if err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+	if sc.IsSupercharger {
+		t.Error("Expected a match below the threshold to be rejected")
+	}
+
+	stored, err := broker.Supercharger.GetByID("ChIJlooseMatch")
+	if err != nil {
+		t.Fatalf("Failed to get stored place: %v", err)
+	}
+	if stored.IsSupercharger || stored.MatchConfidence != 0.75 {
+		t.Errorf("Expected a rejected row with confidence 0.75, got %v and %v", stored.IsSupercharger, stored.MatchConfidence)
+	}
+}
+
+func TestGetSuperchargerWithCacheRecordsFetchOutcomes(t *testing.T) {
+	var calls int
+	stubPlacesAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "ChIJstrictSite") {
+			w.Write([]byte(`{"id":"ChIJstrictSite","displayName":{"text":"Tesla Supercharger"},"types":["electric_vehicle_charging_station"],"location":{"latitude":37.5,"longitude":-122.1}}`))
+			return
+		}
+		calls++
+		switch calls {
+		case 1:
+			http.Error(w, `{"error":{"code":500,"status":"INTERNAL"}}`, http.StatusInternalServerError)
+		case 2:
+			http.Error(w, `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED"}}`, http.StatusTooManyRequests)
+		case 3:
+			http.Error(w, `{"error":{"code":404,"status":"NOT_FOUND"}}`, http.StatusNotFound)
+		default:
+			w.Write([]byte(`{"id":"ChIJflakySite","displayName":{"text":"Tesla Supercharger"},"types":["electric_vehicle_charging_station"],"location":{"latitude":37.4,"longitude":-122.1}}`))
+		}
+	})
+	// the failures have to reach the caller rather than be retried away
+	originalRetry := *retryPolicy.Load()
+	defer SetRetryPolicy(originalRetry)
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+	broker := newTestDB(t)
+	config := DefaultSearchConfig()
+	config.FetchRestaurants = false
+
+	// an outage and a rate limit say nothing about the site, a missing place does
+	for i := 0; i < 3; i++ {
+		if _, _, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJflakySite", config); err == nil {
+			t.Fatalf("Expected lookup %d to fail", i+1)
+		}
+	}
+	if _, _, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJflakySite", config); err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+
+	// a request's stricter threshold doesn't count as the site being something else
+	strict := *config
+	strict.MinMatchConfidence = 0.99
+	if _, _, err := GetSuperchargerWithCache(context.Background(), broker, "key", "ChIJstrictSite", &strict); err != nil {
+		t.Fatalf("GetSuperchargerWithCache failed: %v", err)
+	}
+
+	stats, err := broker.FetchStats.GetByIDs([]string{"ChIJflakySite", "ChIJstrictSite"})
+	if err != nil {
+		t.Fatalf("Failed to get fetch stats: %v", err)
+	}
+	got := stats["ChIJflakySite"]
+	if got.Failures != 1 || got.Successes != 1 || got.LastIsSupercharger == nil || !*got.LastIsSupercharger {
+		t.Errorf("Expected one not found failure then one supercharger lookup, got %+v", got)
+	}
+	if strictStats := stats["ChIJstrictSite"]; strictStats.LastIsSupercharger == nil || !*strictStats.LastIsSupercharger {
+		t.Errorf("Expected the site classified by the default threshold, got %+v", strictStats)
+	}
+
+	results := []SuperchargerWithETA{{Supercharger: &db.Supercharger{PlaceID: "ChIJflakySite"}}, {Supercharger: &db.Supercharger{PlaceID: "ChIJnew"}}}
+	setReliability(broker, results)
+	if results[0].Reliability != 2.0/3 || results[1].Reliability != 1 {
+		t.Errorf("Expected reliabilities of 2/3 and 1, got %v and %v", results[0].Reliability, results[1].Reliability)
+	}
+}
+
+func TestSearchCirclesDropsSlowCircles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body requestBody
+		json.NewDecoder(r.Body).Decode(&body)
+		// the circle at latitude 1 never answers in time
+		if body.LocationBias.Circle.Center.Latitude == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		fmt.Fprintf(w, `{"places":[{"id":"ChIJfast%v"}]}`, body.LocationBias.Circle.Center.Latitude)
+	}))
+	defer server.Close()
+
+	original := placesAPIEndpoint
+	defer func() { placesAPIEndpoint = original }()
+	placesAPIEndpoint = server.URL
+
+	circles := []Circle{
+		{Center: Center{Latitude: 0}, Radius: 5000},
+		{Center: Center{Latitude: 1}, Radius: 5000},
+		{Center: Center{Latitude: 2}, Radius: 5000},
+	}
+
+	start := time.Now()
+	placeIDs, skipped, err := searchCircles(context.Background(), "key", circles, 100*time.Millisecond, Locale{})
+	if err != nil {
+		t.Fatalf("searchCircles failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Slow circle stalled the search for %v", elapsed)
+	}
+	if skipped != 1 {
+		t.Errorf("Expected 1 skipped circle, got %d", skipped)
+	}
+	if len(placeIDs) != 2 {
+		t.Errorf("Expected 2 place IDs from the fast circles, got %d", len(placeIDs))
+	}
+
+	// the request's own deadline is still an error, not a skip
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := searchCircles(ctx, "key", circles, time.Second, Locale{}); err == nil {
+		t.Error("Expected an error when the overall request times out")
+	}
+}
+
+func TestFilterToCorridor(t *testing.T) {
+	index := buildPolylineIndex([]Center{{Latitude: 37.0, Longitude: -122.0}, {Latitude: 37.5, Longitude: -122.0}}, 0.01)
+	places := map[string]*Center{
+		"near":    {Latitude: 37.2, Longitude: -122.01},
+		"far":     {Latitude: 37.2, Longitude: -121.5},
+		"unknown": nil,
+	}
+
+	ids := filterToCorridor(places, index, MaxDistanceFromRouteMeters)
+	sort.Strings(ids)
+	if len(ids) != 2 || ids[0] != "near" || ids[1] != "unknown" {
+		t.Errorf("Expected near and unknown to be kept, got %v", ids)
+	}
+}
+
+func TestPolylineIndexAcrossAntimeridian(t *testing.T) {
+	// a ferry route through Fiji, crossing from 179E to 179W
+	route := []Center{
+		{Latitude: -17.0, Longitude: 179.0},
+		{Latitude: -17.0, Longitude: 179.5},
+		{Latitude: -17.0, Longitude: -179.5},
+		{Latitude: -17.0, Longitude: -179.0},
+	}
+	index := buildPolylineIndex(route, 0.1)
+	if index.gridWidth > 50 {
+		t.Fatalf("Expected the grid to cover the route, not the globe, got %d columns", index.gridWidth)
+	}
+
+	west, westAlong, westClosest := distanceToPolylineWithIndex(Center{Latitude: -17.01, Longitude: 179.9}, index)
+	east, eastAlong, eastClosest := distanceToPolylineWithIndex(Center{Latitude: -17.01, Longitude: -179.9}, index)
+	if west > 2000 || east > 2000 {
+		t.Errorf("Expected points either side of the antimeridian to be next to the route, got %.0fm and %.0fm", west, east)
+	}
+	if eastAlong <= westAlong || eastAlong-westAlong > 25000 {
+		t.Errorf("Expected the east point about 21km further along, got %.0fm then %.0fm", westAlong, eastAlong)
+	}
+	for _, closest := range []Center{westClosest, eastClosest} {
+		if math.Abs(closest.Longitude) > 180 {
+			t.Errorf("Expected closest points within [-180, 180], got %v", closest)
+		}
+	}
+
+	dense := interpolatePoints(route[1:3], 10000)
+	for _, p := range dense {
+		if math.Abs(p.Longitude) < 179.5 {
+			t.Errorf("Expected interpolation the short way across the antimeridian, got %v", p)
+		}
+	}
+}
+
+func TestExcludePlaces(t *testing.T) {
+	ids := excludePlaces([]string{"a", "b", "c", "d"}, []string{"c", "a", "unseen"})
+	if len(ids) != 2 || ids[0] != "b" || ids[1] != "d" {
+		t.Errorf("Expected b and d to remain in order, got %v", ids)
+	}
+
+	all := []string{"a", "b"}
+	if ids := excludePlaces(all, nil); len(ids) != 2 {
+		t.Errorf("Expected nothing excluded without an exclude list, got %v", ids)
+	}
+}
+
+func TestCalculateETAFromCurrentPosition(t *testing.T) {
+	// 100km route taking 1 hour
+	totalDist, totalDur := 100000.0, time.Hour
+
+	fromOrigin := time.Until(calculateETA(nil, 0, 75000, 0, totalDist, totalDur))
+	if fromOrigin < 44*time.Minute || fromOrigin > 45*time.Minute {
+		t.Errorf("Expected ~45m from origin, got %v", fromOrigin)
+	}
+
+	// a quarter of the way along, the charger is half the route away
+	fromCurrent := time.Until(calculateETA(nil, 25000, 75000, 0, totalDist, totalDur))
+	if fromCurrent < 29*time.Minute || fromCurrent > 30*time.Minute {
+		t.Errorf("Expected ~30m from current position, got %v", fromCurrent)
+	}
+}
+
+func TestGetSuperchargersOnRouteEmptyPolyline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"routes":[{"distanceMeters":0,"duration":"0s","polyline":{"encodedPolyline":""}}]}`))
+	}))
+	defer server.Close()
+
+	original := routesEndpoint
+	defer func() { routesEndpoint = original }()
+	routesEndpoint = server.URL
+
+	_, err := GetSuperchargersOnRoute(context.Background(), nil, "key", "here", "here", nil)
+	if !errors.Is(err, ErrEmptyRoute) {
+		t.Errorf("Expected ErrEmptyRoute, got %v", err)
+	}
+
+	// a nil index must not panic
+	if dist, _, _ := distanceToPolylineWithIndex(Center{Latitude: 1, Longitude: 1}, nil); dist != math.MaxFloat64 {
+		t.Errorf("Expected infinite distance from an empty route, got %v", dist)
+	}
 }
 
 // generateSuperchargerHTMLMapWithETA creates an HTML file with a map visualizing the route and superchargers with ETA information.
@@ -310,3 +1092,41 @@ const superchargerMapTemplate = `
   </body>
 </html>
 `
+
+func TestGetSuperchargersAlongTrackUsesStoredRows(t *testing.T) {
+	places := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"places":[{"id":"ChIJstored","location":{"latitude":38.5,"longitude":-121}}]}`))
+	}))
+	defer places.Close()
+	var detailCalls atomic.Int32
+	details := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		detailCalls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer details.Close()
+
+	originalPlaces, originalDetails := placesAPIEndpoint, placeDetailsEndpoint
+	defer func() { placesAPIEndpoint, placeDetailsEndpoint = originalPlaces, originalDetails }()
+	placesAPIEndpoint, placeDetailsEndpoint = places.URL, details.URL
+
+	broker := newTestDB(t)
+	stored := &db.Supercharger{PlaceID: "ChIJstored", Name: "Tesla Supercharger", Latitude: 38.5, Longitude: -121, TimeZone: "America/Los_Angeles", IsSupercharger: true}
+	if err := broker.Supercharger.Create(stored); err != nil {
+		t.Fatalf("Failed to store supercharger: %v", err)
+	}
+
+	config := DefaultSearchConfig()
+	config.FetchRestaurants = false
+	track := []Center{{Latitude: 38, Longitude: -121}, {Latitude: 39, Longitude: -121}}
+	result, err := GetSuperchargersAlongTrack(context.Background(), broker, "key", track, config)
+	if err != nil {
+		t.Fatalf("GetSuperchargersAlongTrack failed: %v", err)
+	}
+
+	if len(result.Superchargers) != 1 || result.Superchargers[0].Supercharger.Name != "Tesla Supercharger" {
+		t.Fatalf("Expected the stored supercharger, got %+v", result.Superchargers)
+	}
+	if n := detailCalls.Load(); n != 0 {
+		t.Errorf("Expected the stored supercharger to need no details call, got %d", n)
+	}
+}
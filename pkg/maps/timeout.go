@@ -0,0 +1,32 @@
+package maps
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCallTimeout bounds each outbound Google call whose context has no deadline of its own
+const DefaultCallTimeout = 20 * time.Second
+
+var callTimeout atomic.Int64
+
+func init() {
+	callTimeout.Store(int64(DefaultCallTimeout))
+}
+
+// SetCallTimeout sets the timeout applied to outbound Google calls made without a context deadline.
+// Zero or less disables it, leaving such calls unbounded.
+func SetCallTimeout(timeout time.Duration) {
+	callTimeout.Store(int64(timeout))
+}
+
+// withCallTimeout applies the call timeout to ctx if it has no deadline, so a stalled connection
+// can't hang a caller that forgot to set one. Deadlines already on ctx are left alone.
+func withCallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(callTimeout.Load())
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
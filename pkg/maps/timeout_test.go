@@ -0,0 +1,36 @@
+package maps
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithCallTimeout(t *testing.T) {
+	original := time.Duration(callTimeout.Load())
+	defer SetCallTimeout(original)
+	SetCallTimeout(time.Minute)
+
+	ctx, cancel := withCallTimeout(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("Expected a deadline within a minute, got %v %v", deadline, ok)
+	}
+
+	// a caller's own deadline wins, even when it is longer
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Hour)
+	defer parentCancel()
+	ctx, cancel = withCallTimeout(parent)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) < 59*time.Minute {
+		t.Errorf("Expected the caller's deadline to be kept, got %v", deadline)
+	}
+
+	SetCallTimeout(0)
+	ctx, cancel = withCallTimeout(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("Expected no deadline when the call timeout is disabled")
+	}
+}
@@ -0,0 +1,124 @@
+package maps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+var timeZoneEndpoint = "https://maps.googleapis.com/maps/api/timezone/json"
+
+// ArrivalTimeFormat is how arrival times are shown, in the supercharger's local time with its zone
+const ArrivalTimeFormat = "3:04PM MST"
+
+// ETAFormat controls how SuperchargerWithETA.ArrivalTime is written
+type ETAFormat string
+
+const (
+	// ETAFormatKitchen is a clock time in the supercharger's local zone using ArrivalTimeFormat, the default
+	ETAFormatKitchen ETAFormat = "kitchen"
+	// ETAFormatRFC3339 is a full RFC 3339 timestamp with the supercharger's local offset
+	ETAFormatRFC3339 ETAFormat = "rfc3339"
+	// ETAFormatEpochMS is milliseconds since the Unix epoch, as a string
+	ETAFormatEpochMS ETAFormat = "epoch_ms"
+)
+
+// format writes an arrival time at a supercharger in loc. Unknown formats, including the zero value,
+// use ETAFormatKitchen.
+func (f ETAFormat) format(arrival time.Time, loc *time.Location) string {
+	switch f {
+	case ETAFormatRFC3339:
+		return arrival.In(loc).Format(time.RFC3339)
+	case ETAFormatEpochMS:
+		return strconv.FormatInt(arrival.UnixMilli(), 10)
+	default:
+		return arrival.In(loc).Format(ArrivalTimeFormat) // e.g., "3:45PM PDT"
+	}
+}
+
+// timeZoneResponse is the subset of the Time Zone API response we use
+type timeZoneResponse struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+	TimeZoneID   string `json:"timeZoneId"`
+}
+
+// GetTimeZone looks up the IANA timezone ID (e.g. "America/Los_Angeles") for a location
+// using the Google Time Zone API.
+func GetTimeZone(ctx context.Context, apiKey string, location Center, at time.Time) (string, error) {
+	params := url.Values{}
+	params.Set("location", fmt.Sprintf("%f,%f", location.Latitude, location.Longitude))
+	params.Set("timestamp", fmt.Sprintf("%d", at.Unix()))
+	params.Set("key", apiKey)
+
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", timeZoneEndpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create http request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	countCall(SKUTimeZone)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request to Google Time Zone API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("google time zone api returned an error. status: %s, body: %s", resp.Status, string(bodyBytes))
+	}
+
+	var tzResp timeZoneResponse
+	if err := json.Unmarshal(bodyBytes, &tzResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response json: %w", err)
+	}
+
+	// The Time Zone API reports failures in the body with a 200
+	if tzResp.Status != "OK" {
+		return "", fmt.Errorf("google time zone api returned status %s: %s", tzResp.Status, tzResp.ErrorMessage)
+	}
+
+	return tzResp.TimeZoneID, nil
+}
+
+// resolveTimeZone returns the supercharger's local timezone, looking it up and caching it
+// on the supercharger row if it isn't already known. It falls back to the server's local
+// timezone if the lookup fails.
+func resolveTimeZone(ctx context.Context, broker *db.Service, apiKey string, sc *db.Supercharger) *time.Location {
+	if sc.TimeZone == "" {
+		tz, err := GetTimeZone(ctx, apiKey, Center{Latitude: sc.Latitude, Longitude: sc.Longitude}, time.Now())
+		if err != nil {
+			log.Printf("Warning: failed to resolve timezone for supercharger %s: %v", sc.PlaceID, err)
+			return time.Local
+		}
+
+		if err := broker.Supercharger.UpdateTimeZone(sc.PlaceID, tz); err != nil {
+			// Log the error but don't fail the request since we already have the timezone
+			log.Printf("Warning: failed to cache timezone for supercharger %s: %v", sc.PlaceID, err)
+		}
+		sc.TimeZone = tz
+	}
+
+	loc, err := time.LoadLocation(sc.TimeZone)
+	if err != nil {
+		log.Printf("Warning: unknown timezone %s for supercharger %s: %v", sc.TimeZone, sc.PlaceID, err)
+		return time.Local
+	}
+	return loc
+}
@@ -0,0 +1,61 @@
+package maps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetTimeZone(t *testing.T) {
+	status := "OK"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("location"); got != "37.400000,-122.100000" {
+			t.Errorf("Unexpected location parameter %q", got)
+		}
+		w.Write([]byte(`{"status":"` + status + `","timeZoneId":"America/Los_Angeles"}`))
+	}))
+	defer server.Close()
+
+	originalEndpoint := timeZoneEndpoint
+	defer func() { timeZoneEndpoint = originalEndpoint }()
+	timeZoneEndpoint = server.URL
+
+	tz, err := GetTimeZone(context.Background(), "key", Center{Latitude: 37.4, Longitude: -122.1}, time.Now())
+	if err != nil {
+		t.Fatalf("GetTimeZone failed: %v", err)
+	}
+	if tz != "America/Los_Angeles" {
+		t.Errorf("Expected America/Los_Angeles, got %s", tz)
+	}
+
+	// errors are reported in the body with a 200
+	status = "ZERO_RESULTS"
+	if _, err := GetTimeZone(context.Background(), "key", Center{Latitude: 37.4, Longitude: -122.1}, time.Now()); err == nil {
+		t.Error("Expected an error for a non-OK status")
+	}
+}
+
+func TestETAFormat(t *testing.T) {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("Timezone data unavailable: %v", err)
+	}
+	arrival := time.Date(2025, 7, 1, 22, 45, 0, 0, time.UTC)
+
+	tests := []struct {
+		format ETAFormat
+		want   string
+	}{
+		{"", "3:45PM PDT"},
+		{ETAFormatKitchen, "3:45PM PDT"},
+		{ETAFormatRFC3339, "2025-07-01T15:45:00-07:00"},
+		{ETAFormatEpochMS, "1751409900000"},
+	}
+	for _, tt := range tests {
+		if got := tt.format.format(arrival, loc); got != tt.want {
+			t.Errorf("ETAFormat(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
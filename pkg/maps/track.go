@@ -0,0 +1,123 @@
+package maps
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+// DefaultAverageSpeedKmh is the speed ETAs assume along a track, which has no Google duration
+const DefaultAverageSpeedKmh = 90
+
+// gpxDocument holds the parts of a GPX file that describe a path
+type gpxDocument struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+type gpxPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+// ParseGPX reads the points of a GPX file. Track segments are joined in order, and route points are
+// used when the file has no track.
+func ParseGPX(r io.Reader) ([]Center, error) {
+	var doc gpxDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse GPX: %w", err)
+	}
+
+	var points []Center
+	for _, trk := range doc.Tracks {
+		for _, seg := range trk.Segments {
+			for _, p := range seg.Points {
+				points = append(points, Center{Latitude: p.Lat, Longitude: p.Lon})
+			}
+		}
+	}
+	if len(points) == 0 {
+		for _, rte := range doc.Routes {
+			for _, p := range rte.Points {
+				points = append(points, Center{Latitude: p.Lat, Longitude: p.Lon})
+			}
+		}
+	}
+
+	for _, p := range points {
+		if math.Abs(p.Latitude) > 90 || math.Abs(p.Longitude) > 180 {
+			return nil, fmt.Errorf("GPX point %v,%v is out of range", p.Latitude, p.Longitude)
+		}
+	}
+	return points, nil
+}
+
+// trackRoute stands in for a Routes API response, measuring the track itself and timing it at averageSpeedKmh
+func trackRoute(track []Center, averageSpeedKmh float64) *RouteInfo {
+	var distance float64
+	for i := 1; i < len(track); i++ {
+		distance += haversineDistance(track[i-1], track[i])
+	}
+
+	return &RouteInfo{
+		DistanceMeters:  int(distance),
+		Duration:        time.Duration(distance / (averageSpeedKmh * 1000) * float64(time.Hour)),
+		EncodedPolyline: EncodePolyline(track),
+		FetchedAt:       time.Now(),
+	}
+}
+
+// GetSuperchargersAlongTrack finds the superchargers along a recorded or planned track, such as one from
+// ParseGPX, without calling the Routes API. ETAs assume the driver averages config.AverageSpeedKmh.
+// A nil config uses DefaultSearchConfig.
+func GetSuperchargersAlongTrack(ctx context.Context, broker *db.Service, apiKey string, track []Center, config *SearchConfig) (*SuperchargersOnRouteResult, error) {
+	if config == nil {
+		config = DefaultSearchConfig()
+	}
+
+	if err := config.Spatial.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid spatial config: %w", err)
+	}
+	spatial := config.Spatial.withDefaults()
+
+	if len(track) < 2 {
+		return nil, fmt.Errorf("%w: track has %d points", ErrEmptyRoute, len(track))
+	}
+	speed := config.AverageSpeedKmh
+	if speed < 0 {
+		return nil, errors.New("average speed must not be negative")
+	}
+	if speed == 0 {
+		speed = DefaultAverageSpeedKmh
+	}
+
+	route := trackRoute(track, speed)
+	if config.MaxRouteDistanceMeters > 0 && route.DistanceMeters > config.MaxRouteDistanceMeters {
+		return nil, fmt.Errorf("%w: %d meters exceeds the maximum of %d meters", ErrRouteTooLong, route.DistanceMeters, config.MaxRouteDistanceMeters)
+	}
+	if config.RouteOptions.PolylineEncoding == PolylineEncodingGeoJSON {
+		route.Points = track
+	}
+
+	circles, searchRadius, err := PolylineToCirclesCapped(route.EncodedPolyline, SuperchargerSearchRadiusMeters, config.MaxCircles, spatial.InterpolationMeters)
+	if err != nil {
+		return nil, err
+	}
+	if searchRadius != SuperchargerSearchRadiusMeters {
+		logf(LogInfo, "Widened search radius to %.0fm to fit %d circles", searchRadius, len(circles))
+	}
+
+	return searchRoute(ctx, broker, apiKey, route, track, circles, searchRadius, config, spatial)
+}
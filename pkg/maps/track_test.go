@@ -0,0 +1,173 @@
+package maps
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestParseGPX(t *testing.T) {
+	tests := []struct {
+		name    string
+		gpx     string
+		want    []Center
+		wantErr bool
+	}{
+		{
+			name: "track segments are joined",
+			gpx: `<?xml version="1.0"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk><name>Drive</name>
+    <trkseg><trkpt lat="38.5" lon="-121.5"><ele>10</ele></trkpt><trkpt lat="38.6" lon="-121.0"></trkpt></trkseg>
+    <trkseg><trkpt lat="39.5" lon="-119.8"></trkpt></trkseg>
+  </trk>
+</gpx>`,
+			want: []Center{{Latitude: 38.5, Longitude: -121.5}, {Latitude: 38.6, Longitude: -121.0}, {Latitude: 39.5, Longitude: -119.8}},
+		},
+		{
+			name: "route points without a track",
+			gpx:  `<gpx><rte><rtept lat="1" lon="2"/><rtept lat="3" lon="4"/></rte></gpx>`,
+			want: []Center{{Latitude: 1, Longitude: 2}, {Latitude: 3, Longitude: 4}},
+		},
+		{
+			name:    "out of range",
+			gpx:     `<gpx><trk><trkseg><trkpt lat="91" lon="0"/></trkseg></trk></gpx>`,
+			wantErr: true,
+		},
+		{
+			name:    "not xml",
+			gpx:     `{"lat": 1}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGPX(strings.NewReader(tt.gpx))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseGPX failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d points, got %d", len(tt.want), len(got))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Point %d: expected %v, got %v", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetSuperchargersAlongTrack(t *testing.T) {
+	routes := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Tracks should not call the Routes API")
+	}))
+	defer routes.Close()
+	var circleSearches int
+	places := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		circleSearches++
+		w.Write([]byte(`{"places":[]}`))
+	}))
+	defer places.Close()
+
+	originalRoutes, originalPlaces := routesEndpoint, placesAPIEndpoint
+	defer func() { routesEndpoint, placesAPIEndpoint = originalRoutes, originalPlaces }()
+	routesEndpoint, placesAPIEndpoint = routes.URL, places.URL
+
+	// roughly 111km due north
+	track := []Center{{Latitude: 38, Longitude: -121}, {Latitude: 38.5, Longitude: -121}, {Latitude: 39, Longitude: -121}}
+	config := DefaultSearchConfig()
+	config.AverageSpeedKmh = 100
+	broker := newTestDB(t)
+
+	result, err := GetSuperchargersAlongTrack(context.Background(), broker, "key", track, config)
+	if err != nil {
+		t.Fatalf("GetSuperchargersAlongTrack failed: %v", err)
+	}
+	if result.Route.DistanceMeters < 110000 || result.Route.DistanceMeters > 112000 {
+		t.Errorf("Expected about 111km, got %dm", result.Route.DistanceMeters)
+	}
+	wantDuration := time.Duration(float64(result.Route.DistanceMeters) / 100000 * float64(time.Hour))
+	if diff := result.Route.Duration - wantDuration; diff < -time.Second || diff > time.Second {
+		t.Errorf("Expected a duration of %v at 100km/h, got %v", wantDuration, result.Route.Duration)
+	}
+	if len(result.SearchCircles) == 0 || circleSearches != len(result.SearchCircles) {
+		t.Errorf("Expected one search per circle, got %d searches for %d circles", circleSearches, len(result.SearchCircles))
+	}
+
+	if _, err := GetSuperchargersAlongTrack(context.Background(), broker, "key", track[:1], config); !errors.Is(err, ErrEmptyRoute) {
+		t.Errorf("Expected ErrEmptyRoute for a single point, got %v", err)
+	}
+}
+
+func TestGetSuperchargersAlongTrackPrefersCached(t *testing.T) {
+	var circleSearches int
+	places := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			t.Errorf("Cached superchargers should not be looked up again, got %s", r.URL.Path)
+		}
+		circleSearches++
+		w.Write([]byte(`{"places":[]}`))
+	}))
+	defer places.Close()
+
+	originalPlaces, originalDetails := placesAPIEndpoint, placeDetailsEndpoint
+	defer func() { placesAPIEndpoint, placeDetailsEndpoint = originalPlaces, originalDetails }()
+	placesAPIEndpoint, placeDetailsEndpoint = places.URL, places.URL
+
+	broker := newTestDB(t)
+	// cached near the start of the track only
+	if err := broker.Supercharger.Create(&db.Supercharger{PlaceID: "ChIJcachedSupercharger", Latitude: 38.05, Longitude: -121, IsSupercharger: true}); err != nil {
+		t.Fatalf("Failed to create supercharger: %v", err)
+	}
+
+	track := []Center{{Latitude: 38, Longitude: -121}, {Latitude: 39, Longitude: -121}}
+	config := DefaultSearchConfig()
+	config.FetchRestaurants = false
+	config.PreferCached = true
+
+	// by default one cached supercharger isn't enough to skip a circle's search
+	if _, err := GetSuperchargersAlongTrack(context.Background(), broker, "key", track, config); err != nil {
+		t.Fatalf("GetSuperchargersAlongTrack failed: %v", err)
+	}
+	allCircles := circleSearches
+	circleSearches = 0
+	config.MinCachedPerCircle = 1
+
+	result, err := GetSuperchargersAlongTrack(context.Background(), broker, "key", track, config)
+	if err != nil {
+		t.Fatalf("GetSuperchargersAlongTrack failed: %v", err)
+	}
+	if len(result.Superchargers) != 1 || result.Superchargers[0].Supercharger.PlaceID != "ChIJcachedSupercharger" {
+		t.Fatalf("Expected the cached supercharger, got %v", result.Superchargers)
+	}
+	if allCircles != len(result.SearchCircles) {
+		t.Errorf("Expected all %d circles searched with the default threshold, got %d", len(result.SearchCircles), allCircles)
+	}
+	if circleSearches == 0 || circleSearches >= len(result.SearchCircles) {
+		t.Errorf("Expected only the uncovered circles searched, got %d searches for %d circles", circleSearches, len(result.SearchCircles))
+	}
+
+	// a threshold the cache can't meet searches everything
+	circleSearches = 0
+	config.MinCachedPerCircle = 2
+	if _, err := GetSuperchargersAlongTrack(context.Background(), broker, "key", track, config); err != nil {
+		t.Fatalf("GetSuperchargersAlongTrack failed: %v", err)
+	}
+	if circleSearches != len(result.SearchCircles) {
+		t.Errorf("Expected all %d circles searched, got %d", len(result.SearchCircles), circleSearches)
+	}
+}
@@ -0,0 +1,154 @@
+package maps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+// routeMatrixEndpoint is a package-level variable so tests can point it at a mock server.
+var routeMatrixEndpoint = "https://routes.googleapis.com/distanceMatrix/v2:computeRouteMatrix"
+
+// routeMatrixWaypoint is an origin or destination in a route matrix request
+type routeMatrixWaypoint struct {
+	Waypoint LocationRequest `json:"waypoint"`
+}
+
+type routeMatrixRequest struct {
+	Origins      []routeMatrixWaypoint `json:"origins"`
+	Destinations []routeMatrixWaypoint `json:"destinations"`
+	TravelMode   string                `json:"travelMode"`
+}
+
+type routeMatrixElement struct {
+	OriginIndex      int    `json:"originIndex"`
+	DestinationIndex int    `json:"destinationIndex"`
+	DistanceMeters   int    `json:"distanceMeters"`
+	Duration         string `json:"duration"`
+	Condition        string `json:"condition"`
+}
+
+// WalkingLeg is the walking distance and time from an origin to one destination
+type WalkingLeg struct {
+	DistanceMeters  float64
+	DurationSeconds int
+	// Found is false when Google couldn't find a walking route
+	Found bool
+}
+
+// GetWalkingDistances gets the walking distance and time from origin to each destination with a
+// single Route Matrix call. The returned legs are in the same order as destinations.
+func GetWalkingDistances(ctx context.Context, apiKey string, origin Center, destinations []Center) ([]WalkingLeg, error) {
+	legs := make([]WalkingLeg, len(destinations))
+	if len(destinations) == 0 {
+		return legs, nil
+	}
+
+	matrixRequest := routeMatrixRequest{
+		Origins:    []routeMatrixWaypoint{{Waypoint: pointWaypoint(origin)}},
+		TravelMode: "WALK",
+	}
+	for _, destination := range destinations {
+		matrixRequest.Destinations = append(matrixRequest.Destinations, routeMatrixWaypoint{Waypoint: pointWaypoint(destination)})
+	}
+
+	jsonData, err := json.Marshal(matrixRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", routeMatrixEndpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Goog-Api-Key", apiKey)
+	req.Header.Set("X-Goog-FieldMask", "originIndex,destinationIndex,distanceMeters,duration,condition")
+
+	countCall(SKURouteMatrix)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("route matrix API error: %s", string(body))
+	}
+
+	var elements []routeMatrixElement
+	if err := json.Unmarshal(body, &elements); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	for _, element := range elements {
+		if element.DestinationIndex < 0 || element.DestinationIndex >= len(legs) || element.Condition != "ROUTE_EXISTS" {
+			continue
+		}
+		legs[element.DestinationIndex] = WalkingLeg{
+			DistanceMeters:  float64(element.DistanceMeters),
+			DurationSeconds: parseDurationString(element.Duration),
+			Found:           true,
+		}
+	}
+
+	return legs, nil
+}
+
+// addWalkingDistances sets the walking distance and time from the supercharger on its topN closest
+// restaurants. It returns a copy so shared restaurant slices aren't modified.
+func addWalkingDistances(ctx context.Context, apiKey string, origin Center, restaurants []db.RestaurantWithDistance, topN int) ([]db.RestaurantWithDistance, error) {
+	if topN <= 0 || len(restaurants) == 0 {
+		return restaurants, nil
+	}
+
+	withWalking := make([]db.RestaurantWithDistance, len(restaurants))
+	copy(withWalking, restaurants)
+
+	closest := make([]int, len(withWalking))
+	for i := range closest {
+		closest[i] = i
+	}
+	sort.SliceStable(closest, func(i, j int) bool {
+		return withWalking[closest[i]].Distance < withWalking[closest[j]].Distance
+	})
+	if len(closest) > topN {
+		closest = closest[:topN]
+	}
+
+	destinations := make([]Center, len(closest))
+	for i, idx := range closest {
+		destinations[i] = Center{Latitude: withWalking[idx].Latitude, Longitude: withWalking[idx].Longitude}
+	}
+
+	legs, err := GetWalkingDistances(ctx, apiKey, origin, destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, idx := range closest {
+		if !legs[i].Found {
+			continue
+		}
+		distance, duration := legs[i].DistanceMeters, legs[i].DurationSeconds
+		withWalking[idx].WalkingDistance = &distance
+		withWalking[idx].WalkingDuration = &duration
+	}
+
+	return withWalking, nil
+}
@@ -0,0 +1,61 @@
+package maps
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brensch/passengerprincess/pkg/db"
+)
+
+func TestAddWalkingDistances(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req routeMatrixRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if req.TravelMode != "WALK" {
+			t.Errorf("Expected WALK travel mode, got %s", req.TravelMode)
+		}
+		if len(req.Destinations) != 2 {
+			t.Errorf("Expected only the 2 closest restaurants to be requested, got %d", len(req.Destinations))
+		}
+		// the second destination has no walking route, e.g. it is across a freeway
+		w.Write([]byte(`[
+			{"originIndex":0,"destinationIndex":0,"distanceMeters":400,"duration":"300s","condition":"ROUTE_EXISTS"},
+			{"originIndex":0,"destinationIndex":1,"condition":"ROUTE_NOT_FOUND"}
+		]`))
+	}))
+	defer server.Close()
+
+	originalEndpoint := routeMatrixEndpoint
+	defer func() { routeMatrixEndpoint = originalEndpoint }()
+	routeMatrixEndpoint = server.URL
+
+	restaurants := []db.RestaurantWithDistance{
+		{Restaurant: db.Restaurant{PlaceID: "far"}, Distance: 450},
+		{Restaurant: db.Restaurant{PlaceID: "closest"}, Distance: 100},
+		{Restaurant: db.Restaurant{PlaceID: "across_freeway"}, Distance: 250},
+	}
+
+	withWalking, err := addWalkingDistances(context.Background(), "key", Center{}, restaurants, 2)
+	if err != nil {
+		t.Fatalf("addWalkingDistances failed: %v", err)
+	}
+
+	if restaurants[1].WalkingDistance != nil {
+		t.Error("Expected the input restaurants to be left unchanged")
+	}
+	closest := withWalking[1]
+	if closest.WalkingDistance == nil || *closest.WalkingDistance != 400 || *closest.WalkingDuration != 300 {
+		t.Errorf("Unexpected walking distance for closest restaurant: %+v", closest)
+	}
+	if withWalking[2].WalkingDistance != nil {
+		t.Error("Expected no walking distance when no route was found")
+	}
+	if withWalking[0].WalkingDistance != nil {
+		t.Error("Expected no walking distance for restaurants outside the top N")
+	}
+}